@@ -2,6 +2,7 @@ package components
 
 import (
 	"errors"
+	"time"
 
 	"github.com/FimGroup/fim/components/internal/connector/source"
 	"github.com/FimGroup/fim/components/internal/connector/target"
@@ -30,3 +31,8 @@ func InitFunctions(c basicapi.BasicContainer) error {
 	}
 	return nil
 }
+
+// SetClock replaces the clock of builtin time functions for deterministic tests, nil restores the system clock
+func SetClock(now func() time.Time) {
+	fn.SetClock(now)
+}
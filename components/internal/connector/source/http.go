@@ -15,6 +15,7 @@ import (
 	"github.com/FimGroup/fim/fimapi/pluginapi"
 	"github.com/FimGroup/fim/fimapi/providers"
 	"github.com/FimGroup/fim/fimapi/rule"
+	"github.com/FimGroup/fim/fimapi/xmlcodec"
 
 	"github.com/FimGroup/logging"
 
@@ -352,8 +353,7 @@ func (h *HttpRestServerGenerator) addTemplateHandler(req pluginapi.SourceConnect
 
 		if len(body) > 0 {
 			//FIXME need support more content-types
-			contentType := request.Header.Get("Content-Type")
-			if !strings.HasPrefix(contentType, "application/json") {
+			if !isSupportedBodyContentType(request.Header.Get("Content-Type")) {
 				writer.WriteHeader(http.StatusBadRequest)
 				return
 			}
@@ -361,7 +361,11 @@ func (h *HttpRestServerGenerator) addTemplateHandler(req pluginapi.SourceConnect
 
 		// convert request
 		contextModel := req.Container.NewModel()
+		defer req.Container.ReleaseModel(contextModel)
 		if err := h.convertQueryStringAndJsonRequestModel(request, body, contextModel, def, req.Container); err != nil {
+			if flowErr, ok := err.(*pluginapi.FlowError); ok && h.respondErrSimple(writer, flowErr, errSimpleMapping) {
+				return
+			}
 			h._logger.Error("convert http request model failed:", err)
 			writer.WriteHeader(http.StatusInternalServerError)
 			return
@@ -369,57 +373,9 @@ func (h *HttpRestServerGenerator) addTemplateHandler(req pluginapi.SourceConnect
 
 		// run process
 		if err := fn(contextModel); err != nil {
-			//FIXME handling error simple
 			//FIXME need support template error rendering
-			if flowErr, ok := err.(*pluginapi.FlowError); ok {
-				errMapping, ok := errSimpleMapping[flowErr.Key]
-				if ok {
-					r := map[string]interface{}{}
-					messagePath, ok := errMapping["error_message"]
-					if ok {
-						if strings.HasPrefix(messagePath, ParamHttpBodyPrefix) {
-							destPaths := rule.SplitFullPath(messagePath[len(ParamHttpBodyPrefix):])
-							m := r
-							for _, p := range destPaths[:len(destPaths)-1] {
-								//FIXME need support the following data types: array
-								nm, ok := m[p]
-								if !ok {
-									nm = map[string]interface{}{}
-									m[p] = nm
-								}
-								m = nm.(map[string]interface{})
-							}
-							lastPath := destPaths[len(destPaths)-1]
-							m[lastPath] = flowErr.Message
-						} else {
-							//FIXME support more data access, e.g. headers
-						}
-					}
-					status, ok := errMapping["http/status"]
-					if ok {
-						code, err := strconv.Atoi(status)
-						if err != nil {
-							h._logger.Error("error processing error simple status code:", err)
-							writer.WriteHeader(http.StatusInternalServerError)
-							return
-						}
-						writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-						writer.WriteHeader(code)
-					} else {
-						writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-						writer.WriteHeader(http.StatusInternalServerError)
-					}
-					data, err := json.Marshal(r)
-					if err == nil {
-						_, err := writer.Write(data)
-						if err != nil {
-							h._logger.Error("write response error:", err)
-						}
-						return
-					} else {
-						h._logger.Error("json marshal failed:", err)
-					}
-				}
+			if flowErr, ok := err.(*pluginapi.FlowError); ok && h.respondErrSimple(writer, flowErr, errSimpleMapping) {
+				return
 			}
 			h._logger.Error("error processing:", err)
 			writer.WriteHeader(http.StatusInternalServerError)
@@ -472,8 +428,7 @@ func (h *HttpRestServerGenerator) GenerateSourceConnectorInstance(req pluginapi.
 				}
 
 				if len(body) > 0 {
-					contentType := request.Header.Get("Content-Type")
-					if !strings.HasPrefix(contentType, "application/json") {
+					if !isSupportedBodyContentType(request.Header.Get("Content-Type")) {
 						writer.WriteHeader(http.StatusBadRequest)
 						return
 					}
@@ -481,7 +436,11 @@ func (h *HttpRestServerGenerator) GenerateSourceConnectorInstance(req pluginapi.
 
 				// convert request
 				contextModel := req.Container.NewModel()
+				defer req.Container.ReleaseModel(contextModel)
 				if err := h.convertQueryStringAndJsonRequestModel(request, body, contextModel, mappingDef, req.Container); err != nil {
+					if flowErr, ok := err.(*pluginapi.FlowError); ok && h.respondErrSimple(writer, flowErr, errSimpleMapping) {
+						return
+					}
 					h._logger.Error("convert http request model failed:", err)
 					writer.WriteHeader(http.StatusInternalServerError)
 					return
@@ -490,55 +449,8 @@ func (h *HttpRestServerGenerator) GenerateSourceConnectorInstance(req pluginapi.
 				// run process
 				if err := fn(contextModel); err != nil {
 					// handling error simple
-					if flowErr, ok := err.(*pluginapi.FlowError); ok {
-						errMapping, ok := errSimpleMapping[flowErr.Key]
-						if ok {
-							r := map[string]interface{}{}
-							messagePath, ok := errMapping["error_message"]
-							if ok {
-								if strings.HasPrefix(messagePath, ParamHttpBodyPrefix) {
-									destPaths := rule.SplitFullPath(messagePath[len(ParamHttpBodyPrefix):])
-									m := r
-									for _, p := range destPaths[:len(destPaths)-1] {
-										//FIXME need support the following data types: array
-										nm, ok := m[p]
-										if !ok {
-											nm = map[string]interface{}{}
-											m[p] = nm
-										}
-										m = nm.(map[string]interface{})
-									}
-									lastPath := destPaths[len(destPaths)-1]
-									m[lastPath] = flowErr.Message
-								} else {
-									//FIXME support more data access, e.g. headers
-								}
-							}
-							status, ok := errMapping["http/status"]
-							if ok {
-								code, err := strconv.Atoi(status)
-								if err != nil {
-									h._logger.Error("error processing error simple status code:", err)
-									writer.WriteHeader(http.StatusInternalServerError)
-									return
-								}
-								writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-								writer.WriteHeader(code)
-							} else {
-								writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-								writer.WriteHeader(http.StatusInternalServerError)
-							}
-							data, err := json.Marshal(r)
-							if err == nil {
-								_, err := writer.Write(data)
-								if err != nil {
-									h._logger.Error("write response error:", err)
-								}
-								return
-							} else {
-								h._logger.Error("json marshal failed:", err)
-							}
-						}
+					if flowErr, ok := err.(*pluginapi.FlowError); ok && h.respondErrSimple(writer, flowErr, errSimpleMapping) {
+						return
 					}
 					h._logger.Error("error processing:", err)
 					writer.WriteHeader(http.StatusInternalServerError)
@@ -546,11 +458,16 @@ func (h *HttpRestServerGenerator) GenerateSourceConnectorInstance(req pluginapi.
 				}
 
 				// convert response
-				if data, err := h.convertJsonResponseModel(contextModel, mappingDef, req.Container); err != nil {
+				convertResponse, contentType := h.convertJsonResponseModel, "application/json; charset=utf-8"
+				if respondXml(request) {
+					convertResponse, contentType = h.convertXmlResponseModel, "application/xml; charset=utf-8"
+				}
+				if data, err := convertResponse(contextModel, mappingDef, req.Container); err != nil {
+					h._logger.Error("convert http response model failed:", err)
 					writer.WriteHeader(http.StatusInternalServerError)
 					return
 				} else {
-					writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+					writer.Header().Set("Content-Type", contentType)
 					writer.WriteHeader(http.StatusOK)
 					_, err := writer.Write(data)
 					if err != nil {
@@ -579,8 +496,109 @@ func (h *HttpRestServerGenerator) GenerateSourceConnectorInstance(req pluginapi.
 	}, nil
 }
 
+// respondErrSimple writes error response according to error simple mapping
+// returns false if no mapping matches the FlowError
+func (h *HttpRestServerGenerator) respondErrSimple(writer http.ResponseWriter, flowErr *pluginapi.FlowError, errSimpleMapping map[string]map[string]string) bool {
+	errMapping, ok := errSimpleMapping[flowErr.Key]
+	if !ok {
+		return false
+	}
+	r := map[string]interface{}{}
+	messagePath, ok := errMapping["error_message"]
+	if ok {
+		if strings.HasPrefix(messagePath, ParamHttpBodyPrefix) {
+			putHttpBodyValue(r, messagePath[len(ParamHttpBodyPrefix):], flowErr.Message)
+		} else {
+			//FIXME support more data access, e.g. headers
+		}
+	}
+	detailsPath, ok := errMapping["error_details"]
+	if ok && len(flowErr.Details) > 0 {
+		if strings.HasPrefix(detailsPath, ParamHttpBodyPrefix) {
+			var details []interface{}
+			for _, v := range flowErr.Details {
+				details = append(details, map[string]interface{}{
+					"path":    v.Path,
+					"rule":    v.Rule,
+					"message": v.Message,
+				})
+			}
+			putHttpBodyValue(r, detailsPath[len(ParamHttpBodyPrefix):], details)
+		} else {
+			//FIXME support more data access, e.g. headers
+		}
+	}
+	status, ok := errMapping["http/status"]
+	if ok {
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			h._logger.Error("error processing error simple status code:", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		writer.WriteHeader(code)
+	} else {
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		writer.WriteHeader(http.StatusInternalServerError)
+	}
+	data, err := json.Marshal(r)
+	if err == nil {
+		_, err := writer.Write(data)
+		if err != nil {
+			h._logger.Error("write response error:", err)
+		}
+	} else {
+		h._logger.Error("json marshal failed:", err)
+	}
+	return true
+}
+
+// isJsonContentType accepts json and json merge patch(RFC 7396) bodies
+func isJsonContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json") || isMergePatchContentType(contentType)
+}
+
+// isMergePatchContentType checks json merge patch bodies
+// Merge patch bodies are mapped as json objects where null members are not mapped, so the document is also
+// mapped as string in http/merge_patch to be applied by @merge_patch
+func isMergePatchContentType(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/merge-patch+json")
+}
+
+// isSupportedBodyContentType accepts json and xml bodies
+func isSupportedBodyContentType(contentType string) bool {
+	return isJsonContentType(contentType) || xmlcodec.IsXmlContentType(contentType)
+}
+
+// respondXml responds xml for xml requests or requests accepting xml
+func respondXml(request *http.Request) bool {
+	if xmlcodec.IsXmlContentType(request.Header.Get("Content-Type")) {
+		return true
+	}
+	accept := request.Header.Get("Accept")
+	return strings.HasPrefix(accept, xmlcodec.ContentTypeXml) || strings.HasPrefix(accept, xmlcodec.ContentTypeTextXml)
+}
+
+func putHttpBodyValue(r map[string]interface{}, path string, value interface{}) {
+	destPaths := rule.SplitFullPath(path)
+	m := r
+	for _, p := range destPaths[:len(destPaths)-1] {
+		//FIXME need support the following data types: array
+		nm, ok := m[p]
+		if !ok {
+			nm = map[string]interface{}{}
+			m[p] = nm
+		}
+		m = nm.(map[string]interface{})
+	}
+	lastPath := destPaths[len(destPaths)-1]
+	m[lastPath] = value
+}
+
 func (h *HttpRestServerGenerator) convertTemplateObjectModel(m pluginapi.Model, def *pluginapi.MappingDefinition, container pluginapi.Container) (interface{}, error) {
 	res := container.NewModel()
+	defer container.ReleaseModel(res)
 	if err := def.ResConverter(m, res); err != nil {
 		return nil, err
 	}
@@ -609,6 +627,7 @@ func (h *HttpRestServerGenerator) convertTemplateObjectModel(m pluginapi.Model,
 
 func (h *HttpRestServerGenerator) convertJsonResponseModel(m pluginapi.Model, def *pluginapi.MappingDefinition, container pluginapi.Container) ([]byte, error) {
 	res := container.NewModel()
+	defer container.ReleaseModel(res)
 	if err := def.ResConverter(m, res); err != nil {
 		return nil, err
 	}
@@ -635,6 +654,22 @@ func (h *HttpRestServerGenerator) convertJsonResponseModel(m pluginapi.Model, de
 	return json.Marshal(bodyObject)
 }
 
+// convertXmlResponseModel encodes http/body as xml document, http/body should contain exactly one field as the root element
+func (h *HttpRestServerGenerator) convertXmlResponseModel(m pluginapi.Model, def *pluginapi.MappingDefinition, container pluginapi.Container) ([]byte, error) {
+	bodyObject, err := h.convertTemplateObjectModel(m, def, container)
+	if err != nil {
+		return nil, err
+	}
+	if bodyObject == nil {
+		return nil, nil
+	}
+	bodyMap, ok := bodyObject.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("body object is not a map[string]interface{}")
+	}
+	return xmlcodec.Marshal(bodyMap)
+}
+
 func (h *HttpRestServerGenerator) convertQueryStringAndJsonRequestModel(request *http.Request, body []byte, m pluginapi.Model, def *pluginapi.MappingDefinition, container pluginapi.Container) error {
 	httpObj := map[string]interface{}{}
 	src := map[string]interface{}{
@@ -645,8 +680,19 @@ func (h *HttpRestServerGenerator) convertQueryStringAndJsonRequestModel(request
 	{
 		var b interface{}
 		if len(body) > 0 {
-			if err := json.Unmarshal(body, &b); err != nil {
+			if xmlcodec.IsXmlContentType(request.Header.Get("Content-Type")) {
+				// arrays and data types are decided by the mapping definitions since xml has neither of them
+				arrayPaths := xmlcodec.TrimPathPrefix(ParamHttpBodyPrefix, def.ReqConnectorPaths)
+				types := xmlcodec.TrimTypePathPrefix(ParamHttpBodyPrefix, def.ReqConnectorTypes)
+				if r, err := xmlcodec.UnmarshalWithTypes(body, arrayPaths, types); err != nil {
+					h._logger.Error(err)
+				} else {
+					b = r
+				}
+			} else if err := json.Unmarshal(body, &b); err != nil {
 				h._logger.Error(err)
+			} else if isMergePatchContentType(request.Header.Get("Content-Type")) {
+				httpObj["merge_patch"] = string(body)
 			}
 			httpObj["body"] = b
 		}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/xmlcodec"
 
 	"github.com/FimGroup/logging"
 
@@ -16,6 +17,7 @@ const (
 	HeaderContentType = "Content-Type"
 
 	ContentTypeJson = "application/json"
+	ContentTypeXml  = xmlcodec.ContentTypeXml
 )
 
 type NatsMessagingSourceConnectorGenereator struct {
@@ -117,6 +119,13 @@ func (n *NatsMessagingSourceConnector) Start() error {
 			if err := json.Unmarshal(msg.Data, &obj); err != nil {
 				panic(err)
 			}
+		case ContentTypeXml:
+			// xml type, arrays and data types are decided by the mapping definitions
+			r, err := xmlcodec.UnmarshalWithTypes(msg.Data, xmlcodec.TrimPathPrefix("body/", n.mapping.ReqConnectorPaths), xmlcodec.TrimTypePathPrefix("body/", n.mapping.ReqConnectorTypes))
+			if err != nil {
+				panic(err)
+			}
+			obj = r
 		default:
 			panic(errors.New("unknown content type:" + contentType))
 		}
@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/xmlcodec"
 
 	"github.com/nats-io/nats.go"
 )
@@ -13,6 +14,7 @@ const (
 	HeaderContentType = "Content-Type"
 
 	ContentTypeJson = "application/json"
+	ContentTypeXml  = xmlcodec.ContentTypeXml
 )
 
 type NatsMessagingTargetConnectorGenerator struct {
@@ -35,14 +37,22 @@ func (n *NatsMessagingTargetConnectorGenerator) GenerateTargetConnectorInstance(
 	if !ok {
 		return nil, errors.New("nats.topic is empty")
 	}
+	contentType, ok := req.Options["nats.content_type"]
+	if !ok {
+		contentType = ContentTypeJson
+	}
+	if contentType != ContentTypeJson && contentType != ContentTypeXml {
+		return nil, errors.New("unsupported nats.content_type:" + contentType)
+	}
 	container := req.Container
 	mapping := req.Definition
 
 	return &NatsMessagingTargetConnector{
-		url:       url,
-		topic:     topic,
-		container: container,
-		mapping:   mapping,
+		url:         url,
+		topic:       topic,
+		contentType: contentType,
+		container:   container,
+		mapping:     mapping,
 	}, nil
 }
 
@@ -59,10 +69,11 @@ func (n *NatsMessagingTargetConnectorGenerator) Stop() error {
 }
 
 type NatsMessagingTargetConnector struct {
-	url       string
-	topic     string
-	container pluginapi.Container
-	mapping   *pluginapi.MappingDefinition
+	url         string
+	topic       string
+	contentType string
+	container   pluginapi.Container
+	mapping     *pluginapi.MappingDefinition
 
 	conn *nats.Conn
 }
@@ -104,14 +115,26 @@ func (n *NatsMessagingTargetConnector) InvokeFlow(s, d pluginapi.Model) error {
 		bodyMap = map[string]interface{}{}
 	}
 
-	// json serialization
-	data, err := json.Marshal(bodyMap)
+	var data []byte
+	var err error
+	switch n.contentType {
+	case ContentTypeXml:
+		// xml serialization, body should contain exactly one field as the root element
+		xmlBody, ok := bodyMap.(map[string]interface{})
+		if !ok {
+			return errors.New("body is not map[string]interface{}")
+		}
+		data, err = xmlcodec.Marshal(xmlBody)
+	default:
+		// json serialization
+		data, err = json.Marshal(bodyMap)
+	}
 	if err != nil {
 		return err
 	}
 
 	msg := nats.NewMsg(n.topic)
-	msg.Header.Add(HeaderContentType, ContentTypeJson)
+	msg.Header.Add(HeaderContentType, n.contentType)
 	msg.Data = data
 	return n.conn.PublishMsg(msg)
 }
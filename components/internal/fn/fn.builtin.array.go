@@ -0,0 +1,612 @@
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+// FnCollect collects primitive values matching the query into the primitive array, empty array if nothing matches
+// e.g. ["items[*]/price", "result/prices[]"]
+func FnCollect(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@collect"
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	queryString, err := stringParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	query, err := basicapi.ParseQuery(queryString)
+	if err != nil {
+		return nil, err
+	}
+	targetPaths, err := arrayPathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		values, err := query.Values(m)
+		if err != nil {
+			return err
+		}
+		return setArray(m, targetPaths, values)
+	}, nil
+}
+
+// Array functions
+// Array parameters are array definitions, e.g. order/items[] for object array and order/tags[] for primitive array
+// Field parameters select values of object array elements, e.g. price or sku/code, empty string selects elements of
+// primitive array. Missing source arrays are regarded as empty arrays.
+
+func arrayFieldParam(name string, params []interface{}, idx int) ([]string, error) {
+	field, err := stringParam(name, params, idx)
+	if err != nil {
+		return nil, err
+	}
+	if field == "" {
+		return nil, nil
+	}
+	if !rule.ValidateFullPath(field) {
+		return nil, errors.New(fmt.Sprintf("%s parameter %d invalid field:%s", name, idx, field))
+	}
+	return rule.SplitFullPath(field), nil
+}
+
+// getGeneralArray returns elements of object array or primitive array, empty if the array does not exist
+func getGeneralArray(name string, m pluginapi.Model, path []string) ([]interface{}, error) {
+	v, ok, err := getGeneralObject(m, path)
+	if err != nil || !ok {
+		return nil, err
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return nil, typeMismatchError(name, path, v, "array")
+	}
+	return arr, nil
+}
+
+// elementValue returns value of the field in the element, nil if not exists
+func elementValue(elem interface{}, field []string) interface{} {
+	v := elem
+	for _, lv := range field {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[lv]
+	}
+	return v
+}
+
+// compareValues compares primitive values of the same type, int and float are compared as numbers
+func compareValues(a, b interface{}) (int, bool) {
+	switch av := a.(type) {
+	case int64:
+		if bv, ok := b.(int64); ok {
+			switch {
+			case av < bv:
+				return -1, true
+			case av > bv:
+				return 1, true
+			}
+			return 0, true
+		}
+		if bv, ok := b.(float64); ok {
+			return compareValues(float64(av), bv)
+		}
+	case float64:
+		bv, ok := b.(float64)
+		if bi, isInt := b.(int64); isInt {
+			bv, ok = float64(bi), true
+		}
+		if ok {
+			switch {
+			case av < bv:
+				return -1, true
+			case av > bv:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0, true
+			case !av:
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// arrayTransformFn generates function transforming [src[], target[]]
+func arrayTransformFn(name string, params []interface{}, srcIdx, targetIdx int, transform func(m pluginapi.Model, arr []interface{}) ([]interface{}, error)) (pluginapi.Fn, error) {
+	src, err := arrayPathParam(name, params, srcIdx)
+	if err != nil {
+		return nil, err
+	}
+	target, err := arrayPathParam(name, params, targetIdx)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		arr, err := getGeneralArray(name, m, src)
+		if err != nil {
+			return err
+		}
+		r, err := transform(m, arr)
+		if err != nil {
+			return err
+		}
+		return setGeneralObject(m, target, r)
+	}, nil
+}
+
+// FnArrayLength sets number of elements as int, 0 if the array does not exist, e.g. ["order/items[]", "order/item_count"]
+func FnArrayLength(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_length"
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	src, err := arrayPathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		n, err := m.ArrayLength(src)
+		if err != nil {
+			return err
+		}
+		return target.Set(m, int64(n))
+	}, nil
+}
+
+func arrayInsertFn(name string, params []interface{}, insert func(arr []interface{}, v interface{}) []interface{}) (pluginapi.Fn, error) {
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	value, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return arrayTransformFn(name, params, 0, 2, func(m pluginapi.Model, arr []interface{}) ([]interface{}, error) {
+		v, ok, err := getGeneralObject(m, value.Path())
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return arr, nil
+		}
+		return insert(arr, v), nil
+	})
+}
+
+// FnArrayAppend appends the primitive value or object to the array, nothing is appended if the value does not exist
+// e.g. ["order/tags[]", "input/tag", "order/tags[]"]
+func FnArrayAppend(params []interface{}) (pluginapi.Fn, error) {
+	return arrayInsertFn("@array_append", params, func(arr []interface{}, v interface{}) []interface{} {
+		return append(arr, v)
+	})
+}
+
+// FnArrayPrepend inserts the primitive value or object before the first element, e.g. ["order/items[]", "input/item", "order/items[]"]
+func FnArrayPrepend(params []interface{}) (pluginapi.Fn, error) {
+	return arrayInsertFn("@array_prepend", params, func(arr []interface{}, v interface{}) []interface{} {
+		return append([]interface{}{v}, arr...)
+	})
+}
+
+// FnArrayRemoveAt removes the element at the index, negative index counts from the end, out of range removes nothing
+// Index is int literal or path, e.g. ["order/items[]", 0, "order/items[]"]
+func FnArrayRemoveAt(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_remove_at"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	index, err := numberOperandParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return arrayTransformFn(name, params, 0, 2, func(m pluginapi.Model, arr []interface{}) ([]interface{}, error) {
+		v, err := index.value(name, m)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := v.(int64)
+		if !ok {
+			return nil, errors.New(name + " index should be int")
+		}
+		if i < 0 {
+			i += int64(len(arr))
+		}
+		if i < 0 || i >= int64(len(arr)) {
+			return arr, nil
+		}
+		return append(arr[:i:i], arr[i+1:]...), nil
+	})
+}
+
+func arraySearchFn(name string, params []interface{}, result func(idx int) interface{}) (pluginapi.Fn, error) {
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	src, err := arrayPathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	value, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		arr, err := getGeneralArray(name, m, src)
+		if err != nil {
+			return err
+		}
+		v, ok, err := getGeneralObject(m, value.Path())
+		if err != nil {
+			return err
+		}
+		idx := -1
+		if ok {
+			for i, elem := range arr {
+				if valuesEqual(elem, v) {
+					idx = i
+					break
+				}
+			}
+		}
+		return target.Set(m, result(idx))
+	}, nil
+}
+
+// FnArrayContains sets true if any element equals to the value, int and float are compared as numbers
+// e.g. ["user/roles[]", "input/role", "result/has_role"]
+func FnArrayContains(params []interface{}) (pluginapi.Fn, error) {
+	return arraySearchFn("@array_contains", params, func(idx int) interface{} {
+		return idx >= 0
+	})
+}
+
+// FnArrayIndexOf sets index of the first element equal to the value as int, -1 if not found
+// e.g. ["user/roles[]", "input/role", "result/role_index"]
+func FnArrayIndexOf(params []interface{}) (pluginapi.Fn, error) {
+	return arraySearchFn("@array_index_of", params, func(idx int) interface{} {
+		return int64(idx)
+	})
+}
+
+// FnArrayDistinct removes duplicated elements and keeps the first ones, object elements are compared by the field
+// e.g. ["order/items[]", "sku", "order/items[]"], ["user/tags[]", "", "user/tags[]"]
+func FnArrayDistinct(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_distinct"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	field, err := arrayFieldParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return arrayTransformFn(name, params, 0, 2, func(m pluginapi.Model, arr []interface{}) ([]interface{}, error) {
+		var r []interface{}
+		var keys []interface{}
+		for _, elem := range arr {
+			key := elementValue(elem, field)
+			duplicated := false
+			for _, k := range keys {
+				if valuesEqual(k, key) {
+					duplicated = true
+					break
+				}
+			}
+			if !duplicated {
+				keys = append(keys, key)
+				r = append(r, elem)
+			}
+		}
+		return r, nil
+	})
+}
+
+// FnArraySort sorts elements by the field in asc or desc direction, the sort is stable and missing values are the last
+// e.g. ["order/items[]", "price", "desc", "order/items[]"], ["user/tags[]", "", "asc", "user/tags[]"]
+func FnArraySort(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_sort"
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	field, err := arrayFieldParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	direction, err := stringParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	if direction != "asc" && direction != "desc" {
+		return nil, errors.New(name + " direction should be asc or desc:" + direction)
+	}
+	return arrayTransformFn(name, params, 0, 3, func(m pluginapi.Model, arr []interface{}) ([]interface{}, error) {
+		r := append([]interface{}{}, arr...)
+		var sortErr error
+		sort.SliceStable(r, func(i, j int) bool {
+			a, b := elementValue(r[i], field), elementValue(r[j], field)
+			if a == nil || b == nil {
+				return a != nil
+			}
+			c, ok := compareValues(a, b)
+			if !ok {
+				sortErr = errors.New(fmt.Sprintf("%s values are not comparable: [%v] and [%v]", name, a, b))
+			}
+			if direction == "desc" {
+				return c > 0
+			}
+			return c < 0
+		})
+		return r, sortErr
+	})
+}
+
+// FnArrayReverse e.g. ["order/items[]", "order/items[]"]
+func FnArrayReverse(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_reverse"
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	return arrayTransformFn(name, params, 0, 1, func(m pluginapi.Model, arr []interface{}) ([]interface{}, error) {
+		r := make([]interface{}, len(arr))
+		for i, elem := range arr {
+			r[len(arr)-1-i] = elem
+		}
+		return r, nil
+	})
+}
+
+// FnArraySlice keeps elements in [start, end), end -1 means the end, e.g. ["order/items[]", 0, 10, "page/items[]"]
+func FnArraySlice(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_slice"
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	start, err := intParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	end, err := intParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || (end != -1 && end < start) {
+		return nil, errors.New(name + " invalid range")
+	}
+	return arrayTransformFn(name, params, 0, 3, func(m pluginapi.Model, arr []interface{}) ([]interface{}, error) {
+		s, e := int(start), int(end)
+		if e == -1 || e > len(arr) {
+			e = len(arr)
+		}
+		if s > e {
+			s = e
+		}
+		return arr[s:e], nil
+	})
+}
+
+// FnArrayFlatten concatenates the array field of elements, field is array definition in the element
+// e.g. ["order/packages[]", "items[]", "order/items[]"]
+func FnArrayFlatten(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_flatten"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	field, err := arrayPathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return arrayTransformFn(name, params, 0, 2, func(m pluginapi.Model, arr []interface{}) ([]interface{}, error) {
+		var r []interface{}
+		for _, elem := range arr {
+			v := elementValue(elem, field)
+			if v == nil {
+				continue
+			}
+			sub, ok := v.([]interface{})
+			if !ok {
+				return nil, typeMismatchError(name, field, v, "array")
+			}
+			r = append(r, sub...)
+		}
+		return r, nil
+	})
+}
+
+func arrayAggregateFn(name string, params []interface{}, aggregate func(values []interface{}) (interface{}, error)) (pluginapi.Fn, error) {
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	src, err := arrayPathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	field, err := arrayFieldParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		arr, err := getGeneralArray(name, m, src)
+		if err != nil {
+			return err
+		}
+		var values []interface{}
+		for _, elem := range arr {
+			v := elementValue(elem, field)
+			switch v.(type) {
+			case nil:
+			case int64, float64:
+				values = append(values, v)
+			default:
+				return typeMismatchError(name, append(append([]string{}, src...), field...), v, "int or float")
+			}
+		}
+		r, err := aggregate(values)
+		if err != nil || r == nil {
+			return err
+		}
+		return target.Set(m, r)
+	}, nil
+}
+
+func sumValues(name string, values []interface{}) (interface{}, error) {
+	var sumInt int64
+	var sumFloat float64
+	allInt := true
+	for _, v := range values {
+		if i, ok := v.(int64); ok {
+			r := sumInt + i
+			if (r > sumInt) != (i > 0) {
+				return nil, mathError(pluginapi.FlowErrorKeyNumericOverflow, name, "int overflow")
+			}
+			sumInt = r
+		} else {
+			allInt = false
+		}
+		sumFloat += toFloat(v)
+	}
+	if allInt {
+		return sumInt, nil
+	}
+	return checkFloatResult(name, sumFloat)
+}
+
+// FnArraySum sums the numeric field, result is int if all values are int, e.g. ["order/items[]", "amount", "order/total"]
+// Missing values are skipped and the sum of empty array is int 0
+func FnArraySum(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_sum"
+	return arrayAggregateFn(name, params, func(values []interface{}) (interface{}, error) {
+		return sumValues(name, values)
+	})
+}
+
+// FnArrayAvg sets average as float, nothing is set for empty array, e.g. ["order/items[]", "price", "order/avg_price"]
+func FnArrayAvg(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_avg"
+	return arrayAggregateFn(name, params, func(values []interface{}) (interface{}, error) {
+		if len(values) == 0 {
+			return nil, nil
+		}
+		var sum float64
+		for _, v := range values {
+			sum += toFloat(v)
+		}
+		return checkFloatResult(name, sum/float64(len(values)))
+	})
+}
+
+func extremeValue(values []interface{}, better func(c int) bool) (interface{}, error) {
+	var r interface{}
+	for _, v := range values {
+		if r == nil {
+			r = v
+		} else if c, _ := compareValues(v, r); better(c) {
+			r = v
+		}
+	}
+	return r, nil
+}
+
+// FnArrayMin nothing is set for empty array, e.g. ["order/items[]", "price", "order/min_price"]
+func FnArrayMin(params []interface{}) (pluginapi.Fn, error) {
+	return arrayAggregateFn("@array_min", params, func(values []interface{}) (interface{}, error) {
+		return extremeValue(values, func(c int) bool {
+			return c < 0
+		})
+	})
+}
+
+// FnArrayMax nothing is set for empty array, e.g. ["order/items[]", "price", "order/max_price"]
+func FnArrayMax(params []interface{}) (pluginapi.Fn, error) {
+	return arrayAggregateFn("@array_max", params, func(values []interface{}) (interface{}, error) {
+		return extremeValue(values, func(c int) bool {
+			return c > 0
+		})
+	})
+}
+
+// FnArrayGroupBy groups elements of object array into the object by the field
+// Field values are names of the object, elements are in object arrays of the names, missing values are skipped
+// e.g. ["order/items[]", "category", "order/items_by_category"] -> order/items_by_category/<category>[]
+func FnArrayGroupBy(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@array_group_by"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	src, err := arrayPathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	field, err := arrayFieldParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(field) == 0 {
+		return nil, errors.New(name + " field should not be empty")
+	}
+	target, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		arr, err := getGeneralArray(name, m, src)
+		if err != nil {
+			return err
+		}
+		groups := map[string]interface{}{}
+		for _, elem := range arr {
+			v := elementValue(elem, field)
+			switch v.(type) {
+			case nil:
+				continue
+			case map[string]interface{}, []interface{}:
+				return typeMismatchError(name, append(append([]string{}, src...), field...), v, "primitive")
+			}
+			key := stringOfPrimitive(v)
+			if key == "" || strings.ContainsAny(key, "/[]") {
+				return errors.New(name + " invalid group name:" + key)
+			}
+			group, _ := groups[key].([]interface{})
+			groups[key] = append(group, elem)
+		}
+		return setGeneralObject(m, target.Path(), groups)
+	}, nil
+}
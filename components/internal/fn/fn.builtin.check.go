@@ -2,15 +2,40 @@ package fn
 
 import (
 	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/FimGroup/fim/fimapi/basicapi"
 	"github.com/FimGroup/fim/fimapi/pluginapi"
-	"github.com/FimGroup/fim/fimapi/rule"
 )
 
+// flowErrorParams returns key and message of FlowError from the parameters at idx and idx+1
+func flowErrorParams(name string, params []interface{}, idx int) (string, string, error) {
+	errorKey, err := stringParam(name, params, idx)
+	if err != nil {
+		return "", "", err
+	}
+	errorMessage, err := stringParam(name, params, idx+1)
+	if err != nil {
+		return "", "", err
+	}
+	return errorKey, errorMessage, nil
+}
+
 func CheckAlwaysBreak(params []interface{}) (pluginapi.Fn, error) {
-	errorKey := params[0].(string)
-	errorMessage := params[1].(string)
+	const name = "@check_always_break"
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	errorKey, errorMessage, err := flowErrorParams(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
 	return func(m pluginapi.Model) error {
 		return &pluginapi.FlowError{
 			Key:     errorKey,
@@ -20,15 +45,20 @@ func CheckAlwaysBreak(params []interface{}) (pluginapi.Fn, error) {
 }
 
 func CheckNotExistBreak(params []interface{}) (pluginapi.Fn, error) {
-	var field string = params[0].(string)
-	if !rule.ValidateFullPath(field) {
-		return nil, errors.New("path invalid:" + field)
+	const name = "@check_not_exist_break"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	accessor, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	errorKey, errorMessage, err := flowErrorParams(name, params, 1)
+	if err != nil {
+		return nil, err
 	}
-	fieldPaths := rule.SplitFullPath(field)
-	errorKey := params[1].(string)
-	errorMessage := params[2].(string)
 	return func(m pluginapi.Model) error {
-		val := m.GetFieldUnsafe0(fieldPaths)
+		val := accessor.Get(m)
 		if val == nil {
 			return nil
 		}
@@ -40,15 +70,20 @@ func CheckNotExistBreak(params []interface{}) (pluginapi.Fn, error) {
 }
 
 func CheckExistBreak(params []interface{}) (pluginapi.Fn, error) {
-	var field string = params[0].(string)
-	if !rule.ValidateFullPath(field) {
-		return nil, errors.New("path invalid:" + field)
+	const name = "@check_exist_break"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	accessor, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	errorKey, errorMessage, err := flowErrorParams(name, params, 1)
+	if err != nil {
+		return nil, err
 	}
-	fieldPaths := rule.SplitFullPath(field)
-	errorKey := params[1].(string)
-	errorMessage := params[2].(string)
 	return func(m pluginapi.Model) error {
-		val := m.GetFieldUnsafe0(fieldPaths)
+		val := accessor.Get(m)
 		if val != nil {
 			return nil
 		}
@@ -60,15 +95,20 @@ func CheckExistBreak(params []interface{}) (pluginapi.Fn, error) {
 }
 
 func CheckEmptyBreak(params []interface{}) (pluginapi.Fn, error) {
-	var field string = params[0].(string)
-	if !rule.ValidateFullPath(field) {
-		return nil, errors.New("path invalid:" + field)
+	const name = "@check_empty_break"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	accessor, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	errorKey, errorMessage, err := flowErrorParams(name, params, 1)
+	if err != nil {
+		return nil, err
 	}
-	fieldPaths := rule.SplitFullPath(field)
-	errorKey := params[1].(string)
-	errorMessage := params[2].(string)
 	return func(m pluginapi.Model) error {
-		val := m.GetFieldUnsafe0(fieldPaths)
+		val := accessor.Get(m)
 		if val == nil {
 			return nil
 		}
@@ -87,15 +127,20 @@ func CheckEmptyBreak(params []interface{}) (pluginapi.Fn, error) {
 }
 
 func CheckNotBlankBreak(params []interface{}) (pluginapi.Fn, error) {
-	var field string = params[0].(string)
-	if !rule.ValidateFullPath(field) {
-		return nil, errors.New("path invalid:" + field)
+	const name = "@check_not_blank_break"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	accessor, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	errorKey, errorMessage, err := flowErrorParams(name, params, 1)
+	if err != nil {
+		return nil, err
 	}
-	fieldPaths := rule.SplitFullPath(field)
-	errorKey := params[1].(string)
-	errorMessage := params[2].(string)
 	return func(m pluginapi.Model) error {
-		val := m.GetFieldUnsafe0(fieldPaths)
+		val := accessor.Get(m)
 		if val == nil {
 			return &pluginapi.FlowError{
 				Key:     errorKey,
@@ -115,3 +160,389 @@ func CheckNotBlankBreak(params []interface{}) (pluginapi.Fn, error) {
 		}
 	}, nil
 }
+
+// Validation checks
+// Every rule has two functions:
+// * check_XXX_break - [field, rule parameters..., error key, error message], breaks the flow with *FlowError
+// * check_XXX - [field, rule parameters..., error message, errors[]], appends {path, rule, message} to the object array
+//   and continues so that all violations are collected, see CheckErrorsBreak
+// Missing fields pass the checks, check_exist_break should be used for required fields
+
+// fieldCheck returns false if the field violates the rule
+type fieldCheck func(m pluginapi.Model) (bool, error)
+
+// fieldCheckGen generates check from the field and rule parameters
+type fieldCheckGen func(name string, params []interface{}) (fieldCheck, error)
+
+type validationCheck struct {
+	rule        string
+	description string
+	// field is the first parameter, nil for path of field
+	field *pluginapi.ParamSchema
+	// ruleParams are parameters after the field
+	ruleParams []pluginapi.ParamSchema
+	gen        fieldCheckGen
+}
+
+func (c validationCheck) schemaParams() []pluginapi.ParamSchema {
+	field := pluginapi.ParamSchema{Name: "field", Kind: pluginapi.ParamKindPath, Description: "field to check"}
+	if c.field != nil {
+		field = *c.field
+	}
+	return append([]pluginapi.ParamSchema{field}, c.ruleParams...)
+}
+
+func (c validationCheck) breakSchema() pluginapi.FnSchema {
+	return breakSchema(c.description+", breaks the flow if violated", c.schemaParams()...)
+}
+
+func (c validationCheck) collectSchema() pluginapi.FnSchema {
+	return pluginapi.FnSchema{
+		Description: c.description + ", appends {path, rule, message} to the errors if violated",
+		Params: append(c.schemaParams(),
+			pluginapi.ParamSchema{Name: "error_message", Kind: pluginapi.ParamKindString, Description: "message of the error"},
+			pluginapi.ParamSchema{Name: "errors", Kind: pluginapi.ParamKindPath, Description: "object array of errors, e.g. result/errors[]"},
+		),
+	}
+}
+
+func (c validationCheck) breakFn() pluginapi.FnGen {
+	name := "@check_" + c.rule + "_break"
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		if err := requireParams(name, params, len(c.ruleParams)+3); err != nil {
+			return nil, err
+		}
+		check, err := c.gen(name, params[:len(c.ruleParams)+1])
+		if err != nil {
+			return nil, err
+		}
+		errorKey, errorMessage, err := flowErrorParams(name, params, len(c.ruleParams)+1)
+		if err != nil {
+			return nil, err
+		}
+		return func(m pluginapi.Model) error {
+			ok, err := check(m)
+			if err != nil || ok {
+				return err
+			}
+			return &pluginapi.FlowError{
+				Key:     errorKey,
+				Message: errorMessage,
+			}
+		}, nil
+	}
+}
+
+func (c validationCheck) collectFn() pluginapi.FnGen {
+	name := "@check_" + c.rule
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		if err := requireParams(name, params, len(c.ruleParams)+3); err != nil {
+			return nil, err
+		}
+		check, err := c.gen(name, params[:len(c.ruleParams)+1])
+		if err != nil {
+			return nil, err
+		}
+		field, _ := stringParam(name, params, 0)
+		errorMessage, err := stringParam(name, params, len(c.ruleParams)+1)
+		if err != nil {
+			return nil, err
+		}
+		target, err := arrayPathParam(name, params, len(c.ruleParams)+2)
+		if err != nil {
+			return nil, err
+		}
+		return func(m pluginapi.Model) error {
+			ok, err := check(m)
+			if err != nil || ok {
+				return err
+			}
+			return basicapi.PutGeneralObject(m, target, []interface{}{map[string]interface{}{
+				"path":    field,
+				"rule":    c.rule,
+				"message": errorMessage,
+			}})
+		}, nil
+	}
+}
+
+// stringCheck generates check of string field, non-string values violate the rule
+func stringCheck(name string, params []interface{}, check func(s string) bool) (fieldCheck, error) {
+	field, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) (bool, error) {
+		val := field.Get(m)
+		if val == nil {
+			return true, nil
+		}
+		s, ok := val.(string)
+		return ok && check(s), nil
+	}, nil
+}
+
+// optionalNumberParam returns nil for empty string which means unbounded
+func optionalNumberParam(name string, params []interface{}, idx int) (*float64, error) {
+	if s, ok := params[idx].(string); ok && s == "" {
+		return nil, nil
+	}
+	v, err := basicapi.ConvertPrimitive(params[idx])
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s parameter %d should be number or empty string", name, idx))
+	}
+	switch n := v.(type) {
+	case int64:
+		f := float64(n)
+		return &f, nil
+	case float64:
+		return &n, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("%s parameter %d should be number or empty string", name, idx))
+	}
+}
+
+func optionalRangeParams(name string, params []interface{}, minIdx, maxIdx int) (*float64, *float64, error) {
+	lower, err := optionalNumberParam(name, params, minIdx)
+	if err != nil {
+		return nil, nil, err
+	}
+	upper, err := optionalNumberParam(name, params, maxIdx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if lower != nil && upper != nil && *lower > *upper {
+		return nil, nil, errors.New(name + " min should not be greater than max")
+	}
+	return lower, upper, nil
+}
+
+func inRange(v float64, lower, upper *float64) bool {
+	return (lower == nil || v >= *lower) && (upper == nil || v <= *upper)
+}
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var validationChecks = []validationCheck{
+	// [field, pattern]
+	{rule: "regex", description: "Checks the string field matches the pattern", ruleParams: []pluginapi.ParamSchema{
+		{Name: "pattern", Kind: pluginapi.ParamKindString, Description: "regular expression"},
+	}, gen: func(name string, params []interface{}) (fieldCheck, error) {
+		pattern, err := stringParam(name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.New(name + " invalid pattern:" + err.Error())
+		}
+		return stringCheck(name, params, re.MatchString)
+	}},
+	// [field, min, max], number or empty string for unbounded
+	{rule: "range", description: "Checks the number field is in range [min, max]", ruleParams: []pluginapi.ParamSchema{
+		{Name: "min", Kind: pluginapi.ParamKindAny, Description: "number or empty string for unbounded"},
+		{Name: "max", Kind: pluginapi.ParamKindAny, Description: "number or empty string for unbounded"},
+	}, gen: func(name string, params []interface{}) (fieldCheck, error) {
+		field, err := pathParam(name, params, 0)
+		if err != nil {
+			return nil, err
+		}
+		lower, upper, err := optionalRangeParams(name, params, 1, 2)
+		if err != nil {
+			return nil, err
+		}
+		return func(m pluginapi.Model) (bool, error) {
+			switch v := field.Get(m).(type) {
+			case nil:
+				return true, nil
+			case int64, float64:
+				return inRange(toFloat(v), lower, upper), nil
+			default:
+				return false, nil
+			}
+		}, nil
+	}},
+	// [field, min, max], number of characters
+	{rule: "length", description: "Checks number of characters of the string field is in range [min, max]", ruleParams: []pluginapi.ParamSchema{
+		{Name: "min", Kind: pluginapi.ParamKindAny, Description: "int or empty string for unbounded"},
+		{Name: "max", Kind: pluginapi.ParamKindAny, Description: "int or empty string for unbounded"},
+	}, gen: func(name string, params []interface{}) (fieldCheck, error) {
+		lower, upper, err := optionalRangeParams(name, params, 1, 2)
+		if err != nil {
+			return nil, err
+		}
+		return stringCheck(name, params, func(s string) bool {
+			return inRange(float64(utf8.RuneCountInString(s)), lower, upper)
+		})
+	}},
+	// [field, [values...]]
+	{rule: "enum", description: "Checks the field equals to one of the values", ruleParams: []pluginapi.ParamSchema{
+		{Name: "values", Kind: pluginapi.ParamKindArray, Description: "non-empty array of primitive values"},
+	}, gen: func(name string, params []interface{}) (fieldCheck, error) {
+		field, err := pathParam(name, params, 0)
+		if err != nil {
+			return nil, err
+		}
+		list, ok := params[1].([]interface{})
+		if !ok || len(list) == 0 {
+			return nil, errors.New(name + " parameter 1 should be non-empty array of values")
+		}
+		var values []interface{}
+		for _, v := range list {
+			val, err := basicapi.ConvertPrimitive(v)
+			if err != nil {
+				return nil, errors.New(name + " enum values should be primitive")
+			}
+			values = append(values, val)
+		}
+		return func(m pluginapi.Model) (bool, error) {
+			val := field.Get(m)
+			if val == nil {
+				return true, nil
+			}
+			for _, v := range values {
+				if valuesEqual(v, val) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}, nil
+	}},
+	// [field]
+	{rule: "email", description: "Checks the string field is email address", gen: func(name string, params []interface{}) (fieldCheck, error) {
+		return stringCheck(name, params, func(s string) bool {
+			addr, err := mail.ParseAddress(s)
+			return err == nil && addr.Address == s
+		})
+	}},
+	// [field], absolute URL with scheme and host
+	{rule: "url", description: "Checks the string field is absolute URL with scheme and host", gen: func(name string, params []interface{}) (fieldCheck, error) {
+		return stringCheck(name, params, func(s string) bool {
+			u, err := url.ParseRequestURI(s)
+			return err == nil && u.Scheme != "" && u.Host != ""
+		})
+	}},
+	// [field]
+	{rule: "uuid", description: "Checks the string field is UUID", gen: func(name string, params []interface{}) (fieldCheck, error) {
+		return stringCheck(name, params, uuidRegexp.MatchString)
+	}},
+	// [field, format, min, max], bounds are RFC 3339, now or empty string for unbounded
+	{rule: "date_range", description: "Checks the time field is in range [min, max]", ruleParams: []pluginapi.ParamSchema{
+		{Name: "format", Kind: pluginapi.ParamKindString, Description: "time format"},
+		{Name: "min", Kind: pluginapi.ParamKindString, Description: "RFC 3339, now or empty string for unbounded"},
+		{Name: "max", Kind: pluginapi.ParamKindString, Description: "RFC 3339, now or empty string for unbounded"},
+	}, gen: func(name string, params []interface{}) (fieldCheck, error) {
+		field, err := pathParam(name, params, 0)
+		if err != nil {
+			return nil, err
+		}
+		format, err := timeFormatParam(name, params, 1, -1)
+		if err != nil {
+			return nil, err
+		}
+		var bounds [2]func() (time.Time, bool)
+		for i := range bounds {
+			s, err := stringParam(name, params, i+2)
+			if err != nil {
+				return nil, err
+			}
+			switch s {
+			case "":
+				bounds[i] = func() (time.Time, bool) {
+					return time.Time{}, false
+				}
+			case "now":
+				bounds[i] = func() (time.Time, bool) {
+					return clock(), true
+				}
+			default:
+				t, err := time.Parse(time.RFC3339, s)
+				if err != nil {
+					return nil, errors.New(fmt.Sprintf("%s parameter %d should be RFC 3339, now or empty string", name, i+2))
+				}
+				bounds[i] = func() (time.Time, bool) {
+					return t, true
+				}
+			}
+		}
+		return func(m pluginapi.Model) (bool, error) {
+			t, ok, err := format.parse(name, field, m)
+			if err != nil {
+				// unparsable values violate the rule
+				return false, nil
+			} else if !ok {
+				return true, nil
+			}
+			if lower, ok := bounds[0](); ok && t.Before(lower) {
+				return false, nil
+			}
+			if upper, ok := bounds[1](); ok && t.After(upper) {
+				return false, nil
+			}
+			return true, nil
+		}, nil
+	}},
+	// [array[], min, max], number of elements
+	{rule: "array_size", description: "Checks number of elements of the array is in range [min, max]", field: &pluginapi.ParamSchema{
+		Name: "field", Kind: pluginapi.ParamKindPath, Description: "array to check, e.g. order/items[]",
+	}, ruleParams: []pluginapi.ParamSchema{
+		{Name: "min", Kind: pluginapi.ParamKindAny, Description: "int or empty string for unbounded"},
+		{Name: "max", Kind: pluginapi.ParamKindAny, Description: "int or empty string for unbounded"},
+	}, gen: func(name string, params []interface{}) (fieldCheck, error) {
+		field, err := arrayPathParam(name, params, 0)
+		if err != nil {
+			return nil, err
+		}
+		lower, upper, err := optionalRangeParams(name, params, 1, 2)
+		if err != nil {
+			return nil, err
+		}
+		return func(m pluginapi.Model) (bool, error) {
+			n, err := m.ArrayLength(field)
+			if err != nil {
+				return false, err
+			}
+			return inRange(float64(n), lower, upper), nil
+		}, nil
+	}},
+}
+
+// CheckErrorsBreak breaks the flow if any error is collected by check_XXX functions
+// Errors are in Details of *FlowError, e.g. ["result/errors[]", "validation_failed", "invalid request"]
+func CheckErrorsBreak(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@check_errors_break"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	src, err := arrayPathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	errorKey, err := stringParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	errorMessage, err := stringParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		arr, err := getGeneralArray(name, m, src)
+		if err != nil || len(arr) == 0 {
+			return err
+		}
+		details := make([]pluginapi.FieldErrorDetail, 0, len(arr))
+		for _, elem := range arr {
+			details = append(details, pluginapi.FieldErrorDetail{
+				Path:    stringOfPrimitive(elementValue(elem, []string{"path"})),
+				Rule:    stringOfPrimitive(elementValue(elem, []string{"rule"})),
+				Message: stringOfPrimitive(elementValue(elem, []string{"message"})),
+			})
+		}
+		return &pluginapi.FlowError{
+			Key:     errorKey,
+			Message: errorMessage,
+			Details: details,
+		}
+	}, nil
+}
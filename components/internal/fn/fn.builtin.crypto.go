@@ -1,23 +1,52 @@
 package fn
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/sha3"
 
 	"github.com/FimGroup/fim/fimapi/basicapi"
 	"github.com/FimGroup/fim/fimapi/pluginapi"
-	"github.com/FimGroup/fim/fimapi/rule"
 )
 
+// FnCryptoBcrypt hashes the field in place, optional cost parameter, e.g. ["user/password", 12]
 func FnCryptoBcrypt(params []interface{}) (pluginapi.Fn, error) {
-	var field string = params[0].(string)
-	if !rule.ValidateFullPath(field) {
-		return nil, errors.New("path invalid:" + field)
+	const name = "@crypto_bcrypt"
+	if len(params) != 1 && len(params) != 2 {
+		return nil, errors.New(name + " requires 1 or 2 parameters")
+	}
+	accessor, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	cost := bcrypt.DefaultCost
+	if len(params) == 2 {
+		c, err := intParam(name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		if c < int64(bcrypt.MinCost) || c > int64(bcrypt.MaxCost) {
+			return nil, errors.New(fmt.Sprintf("%s cost should be in range [%d, %d]", name, bcrypt.MinCost, bcrypt.MaxCost))
+		}
+		cost = int(c)
 	}
-	fieldPaths := rule.SplitFullPath(field)
 	return func(m pluginapi.Model) error {
-		val := m.GetFieldUnsafe0(fieldPaths)
+		val := accessor.Get(m)
 		if val == nil {
 			return nil
 		}
@@ -25,32 +54,33 @@ func FnCryptoBcrypt(params []interface{}) (pluginapi.Fn, error) {
 		if !ok {
 			return errors.New("FnCryptoBcrypt: data type is not string")
 		}
-		data, err := bcrypt.GenerateFromPassword([]byte(sval), bcrypt.DefaultCost)
+		data, err := bcrypt.GenerateFromPassword([]byte(sval), cost)
 		if err != nil {
 			return err
 		}
-		return m.AddOrUpdateField0(fieldPaths, string(data))
+		return accessor.Set(m, string(data))
 	}, nil
 }
 
 func FnCryptoBcryptVerify(params []interface{}) (pluginapi.Fn, error) {
-	var field string = params[0].(string)
-	if !rule.ValidateFullPath(field) {
-		return nil, errors.New("path invalid:" + field)
+	const name = "@crypto_bcrypt_verify"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
 	}
-	bcryptoDataPaths := rule.SplitFullPath(field)
-	var userInputField string = params[1].(string)
-	if !rule.ValidateFullPath(userInputField) {
-		return nil, errors.New("path invalid:" + userInputField)
+	bcryptoDataAccessor, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
 	}
-	userInputDataPaths := rule.SplitFullPath(userInputField)
-	var validateResultPath string = params[2].(string)
-	if !rule.ValidateFullPath(validateResultPath) {
-		return nil, errors.New("path invalid:" + validateResultPath)
+	userInputDataAccessor, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	validateResultAccessor, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
 	}
-	validateResultPaths := rule.SplitFullPath(validateResultPath)
 	return func(m basicapi.Model) error {
-		val := m.GetFieldUnsafe0(bcryptoDataPaths)
+		val := bcryptoDataAccessor.Get(m)
 		if val == nil {
 			return nil
 		}
@@ -58,7 +88,7 @@ func FnCryptoBcryptVerify(params []interface{}) (pluginapi.Fn, error) {
 		if !ok {
 			return errors.New("FnCryptoBcryptVerify: data type is not string")
 		}
-		inputVal := m.GetFieldUnsafe0(userInputDataPaths)
+		inputVal := userInputDataAccessor.Get(m)
 		if inputVal == nil {
 			return nil
 		}
@@ -68,11 +98,471 @@ func FnCryptoBcryptVerify(params []interface{}) (pluginapi.Fn, error) {
 		}
 		err := bcrypt.CompareHashAndPassword([]byte(sval), []byte(sinputVal))
 		if err == nil {
-			return m.AddOrUpdateField0(validateResultPaths, true)
+			return validateResultAccessor.Set(m, true)
 		} else if err == bcrypt.ErrMismatchedHashAndPassword {
-			return m.AddOrUpdateField0(validateResultPaths, false)
+			return validateResultAccessor.Set(m, false)
 		} else {
 			return err
 		}
 	}, nil
 }
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	"sha256":   sha256.New,
+	"sha384":   sha512.New384,
+	"sha512":   sha512.New,
+	"sha3_256": sha3.New256,
+	"sha3_384": sha3.New384,
+	"sha3_512": sha3.New512,
+}
+
+func hashParam(name string, params []interface{}, idx int) (func() hash.Hash, error) {
+	algorithm, err := stringParam(name, params, idx)
+	if err != nil {
+		return nil, err
+	}
+	h, ok := hashAlgorithms[algorithm]
+	if !ok {
+		return nil, errors.New(name + " unknown hash algorithm:" + algorithm)
+	}
+	return h, nil
+}
+
+// bytesEncoding encodes and decodes binary data as string: hex, base64 or base64url(without padding)
+type bytesEncoding struct {
+	encode func([]byte) string
+	decode func(string) ([]byte, error)
+}
+
+var bytesEncodings = map[string]bytesEncoding{
+	"hex":       {hex.EncodeToString, hex.DecodeString},
+	"base64":    {base64.StdEncoding.EncodeToString, base64.StdEncoding.DecodeString},
+	"base64url": {base64.RawURLEncoding.EncodeToString, base64.RawURLEncoding.DecodeString},
+}
+
+func encodingParam(name string, params []interface{}, idx int) (bytesEncoding, error) {
+	encoding, err := stringParam(name, params, idx)
+	if err != nil {
+		return bytesEncoding{}, err
+	}
+	e, ok := bytesEncodings[encoding]
+	if !ok {
+		return bytesEncoding{}, errors.New(name + " unknown encoding:" + encoding)
+	}
+	return e, nil
+}
+
+// FnCryptoHash sets digest of the string, e.g. ["file/content", "sha256", "hex", "file/digest"]
+func FnCryptoHash(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@crypto_hash"
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	h, err := hashParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	encoding, err := encodingParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return stringUnaryFn(name, []interface{}{params[0], params[3]}, func(s string) (interface{}, error) {
+		d := h()
+		d.Write([]byte(s))
+		return encoding.encode(d.Sum(nil)), nil
+	})
+}
+
+// FnCryptoHmacSign sets HMAC of the string, key is configure placeholder
+// e.g. ["webhook/body", "sha256", "configure-static://webhook.secret", "hex", "webhook/signature"]
+func FnCryptoHmacSign(cm basicapi.ConfigureManager) pluginapi.FnGen {
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		const name = "@crypto_hmac_sign"
+		if err := requireParams(name, params, 5); err != nil {
+			return nil, err
+		}
+		h, err := hashParam(name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		key, err := secretParam(cm, name, params, 2)
+		if err != nil {
+			return nil, err
+		}
+		encoding, err := encodingParam(name, params, 3)
+		if err != nil {
+			return nil, err
+		}
+		return stringUnaryFn(name, []interface{}{params[0], params[4]}, func(s string) (interface{}, error) {
+			k, err := key()
+			if err != nil {
+				return nil, err
+			}
+			mac := hmac.New(h, []byte(k))
+			mac.Write([]byte(s))
+			return encoding.encode(mac.Sum(nil)), nil
+		})
+	}
+}
+
+// FnCryptoHmacVerify sets bool result of verifying HMAC signature in constant time
+// e.g. ["webhook/body", "sha256", "configure-static://webhook.secret", "hex", "webhook/signature", "webhook/verified"]
+func FnCryptoHmacVerify(cm basicapi.ConfigureManager) pluginapi.FnGen {
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		const name = "@crypto_hmac_verify"
+		if err := requireParams(name, params, 6); err != nil {
+			return nil, err
+		}
+		src, err := pathParam(name, params, 0)
+		if err != nil {
+			return nil, err
+		}
+		h, err := hashParam(name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		key, err := secretParam(cm, name, params, 2)
+		if err != nil {
+			return nil, err
+		}
+		encoding, err := encodingParam(name, params, 3)
+		if err != nil {
+			return nil, err
+		}
+		signature, err := pathParam(name, params, 4)
+		if err != nil {
+			return nil, err
+		}
+		target, err := pathParam(name, params, 5)
+		if err != nil {
+			return nil, err
+		}
+		return func(m pluginapi.Model) error {
+			s, ok, err := getString(name, src, m)
+			if err != nil || !ok {
+				return err
+			}
+			sig, ok, err := getString(name, signature, m)
+			if err != nil {
+				return err
+			} else if !ok {
+				return target.Set(m, false)
+			}
+			expected, err := encoding.decode(sig)
+			if err != nil {
+				return target.Set(m, false)
+			}
+			k, err := key()
+			if err != nil {
+				return err
+			}
+			mac := hmac.New(h, []byte(k))
+			mac.Write([]byte(s))
+			return target.Set(m, hmac.Equal(mac.Sum(nil), expected))
+		}, nil
+	}
+}
+
+// aesGcmKey returns AES-GCM cipher of the base64 key(16, 24 or 32 bytes)
+func aesGcmKey(name string, key func() (string, error)) (cipher.AEAD, error) {
+	k, err := key()
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(k)
+	if err != nil {
+		return nil, errors.New(name + " key should be base64 encoded")
+	}
+	block, err := aes.NewCipher(data)
+	if err != nil {
+		return nil, errors.New(name + " invalid key:" + err.Error())
+	}
+	return cipher.NewGCM(block)
+}
+
+// aesGcmCipherParam validates static keys when the flow is loaded and returns resolver of the cipher
+func aesGcmCipherParam(cm basicapi.ConfigureManager, name string, params []interface{}, idx int) (func() (cipher.AEAD, error), error) {
+	key, err := secretParam(cm, name, params, idx)
+	if err != nil {
+		return nil, err
+	}
+	if placeholder, _ := stringParam(name, params, idx); strings.HasPrefix(placeholder, basicapi.ConfigurePrefixStatic) {
+		aead, err := aesGcmKey(name, key)
+		if err != nil {
+			return nil, err
+		}
+		return func() (cipher.AEAD, error) {
+			return aead, nil
+		}, nil
+	}
+	return func() (cipher.AEAD, error) {
+		return aesGcmKey(name, key)
+	}, nil
+}
+
+// FnCryptoAesGcmEncrypt encrypts the string, result is base64 of nonce and ciphertext
+// Key is configure placeholder of base64 encoded 16, 24 or 32 bytes
+// e.g. ["user/id_card", "configure-dynamic://pii.key", "user/id_card_encrypted"]
+func FnCryptoAesGcmEncrypt(cm basicapi.ConfigureManager) pluginapi.FnGen {
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		const name = "@crypto_aes_gcm_encrypt"
+		if err := requireParams(name, params, 3); err != nil {
+			return nil, err
+		}
+		aeadOf, err := aesGcmCipherParam(cm, name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return stringUnaryFn(name, []interface{}{params[0], params[2]}, func(s string) (interface{}, error) {
+			aead, err := aeadOf()
+			if err != nil {
+				return nil, err
+			}
+			nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(s)+aead.Overhead())
+			if _, err := rand.Read(nonce); err != nil {
+				return nil, err
+			}
+			return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(s), nil)), nil
+		})
+	}
+}
+
+// FnCryptoAesGcmDecrypt decrypts the result of @crypto_aes_gcm_encrypt
+// e.g. ["user/id_card_encrypted", "configure-dynamic://pii.key", "user/id_card"]
+func FnCryptoAesGcmDecrypt(cm basicapi.ConfigureManager) pluginapi.FnGen {
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		const name = "@crypto_aes_gcm_decrypt"
+		if err := requireParams(name, params, 3); err != nil {
+			return nil, err
+		}
+		aeadOf, err := aesGcmCipherParam(cm, name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return stringUnaryFn(name, []interface{}{params[0], params[2]}, func(s string) (interface{}, error) {
+			aead, err := aeadOf()
+			if err != nil {
+				return nil, err
+			}
+			data, err := base64.StdEncoding.DecodeString(s)
+			if err != nil || len(data) < aead.NonceSize() {
+				return nil, errors.New(name + " invalid ciphertext")
+			}
+			plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+			if err != nil {
+				return nil, errors.New(name + " decryption failed")
+			}
+			return string(plain), nil
+		})
+	}
+}
+
+const argon2idSaltLength = 16
+const argon2idKeyLength = 32
+
+// argon2idLimits are maximums of argon2id cost, hashes of larger cost are rejected so that they could not exhaust resources
+// Overridden by static configure crypto.argon2id.max_memory(KiB), crypto.argon2id.max_iterations and crypto.argon2id.max_parallelism
+type argon2idLimits struct {
+	memory     uint32
+	iterations uint32
+	threads    uint8
+}
+
+var defaultArgon2idLimits = argon2idLimits{
+	memory:     256 * 1024,
+	iterations: 16,
+	threads:    16,
+}
+
+func argon2idLimitsOf(cm basicapi.ConfigureManager, name string) (argon2idLimits, error) {
+	limits := defaultArgon2idLimits
+	for _, item := range []struct {
+		key     string
+		bitSize int
+		set     func(uint64)
+	}{
+		{"crypto.argon2id.max_memory", 32, func(v uint64) { limits.memory = uint32(v) }},
+		{"crypto.argon2id.max_iterations", 32, func(v uint64) { limits.iterations = uint32(v) }},
+		{"crypto.argon2id.max_parallelism", 8, func(v uint64) { limits.threads = uint8(v) }},
+	} {
+		placeholder := basicapi.ConfigurePrefixStatic + item.key
+		s := cm.ReplaceStaticConfigure(placeholder)
+		if s == placeholder {
+			continue
+		}
+		v, err := strconv.ParseUint(s, 10, item.bitSize)
+		if err != nil || v == 0 {
+			return limits, errors.New(name + " invalid configure " + item.key + ":" + s)
+		}
+		item.set(v)
+	}
+	return limits, nil
+}
+
+// FnCryptoArgon2id hashes the field in place with cost: iterations, memory in KiB, parallelism
+// Result is in PHC string format, e.g. $argon2id$v=19$m=65536,t=3,p=2$salt$hash
+// Cost should not exceed the limits(see argon2idLimits) so that the hash could be verified
+// e.g. ["user/password", 3, 65536, 2]
+func FnCryptoArgon2id(cm basicapi.ConfigureManager) pluginapi.FnGen {
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		const name = "@crypto_argon2id"
+		if err := requireParams(name, params, 4); err != nil {
+			return nil, err
+		}
+		limits, err := argon2idLimitsOf(cm, name)
+		if err != nil {
+			return nil, err
+		}
+		maximums := [3]int64{int64(limits.iterations), int64(limits.memory), int64(limits.threads)}
+		var cost [3]int64
+		for i := range cost {
+			c, err := intParam(name, params, i+1)
+			if err != nil {
+				return nil, err
+			}
+			if c <= 0 || c > maximums[i] {
+				return nil, errors.New(fmt.Sprintf("%s cost parameter %d out of range (0, %d]", name, i+1, maximums[i]))
+			}
+			cost[i] = c
+		}
+		iterations, memory, threads := uint32(cost[0]), uint32(cost[1]), uint8(cost[2])
+		return stringUnaryFn(name, []interface{}{params[0], params[0]}, func(s string) (interface{}, error) {
+			salt := make([]byte, argon2idSaltLength)
+			if _, err := rand.Read(salt); err != nil {
+				return nil, err
+			}
+			key := argon2.IDKey([]byte(s), salt, iterations, memory, threads, argon2idKeyLength)
+			return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, memory, iterations, threads,
+				base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+		})
+	}
+}
+
+// FnCryptoArgon2idVerify sets bool result of verifying the input against argon2id hash
+// Hashes of cost exceeding the limits(see argon2idLimits) are rejected with error
+// e.g. ["user/password_hash", "input/password", "result/verified"]
+func FnCryptoArgon2idVerify(cm basicapi.ConfigureManager) pluginapi.FnGen {
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		const name = "@crypto_argon2id_verify"
+		if err := requireParams(name, params, 3); err != nil {
+			return nil, err
+		}
+		limits, err := argon2idLimitsOf(cm, name)
+		if err != nil {
+			return nil, err
+		}
+		hashed, err := pathParam(name, params, 0)
+		if err != nil {
+			return nil, err
+		}
+		input, err := pathParam(name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		target, err := pathParam(name, params, 2)
+		if err != nil {
+			return nil, err
+		}
+		return func(m pluginapi.Model) error {
+			h, ok, err := getString(name, hashed, m)
+			if err != nil || !ok {
+				return err
+			}
+			s, ok, err := getString(name, input, m)
+			if err != nil || !ok {
+				return err
+			}
+			var version int
+			var memory, iterations uint32
+			var threads uint8
+			parts := strings.Split(h, "$")
+			if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+				return errors.New(name + " invalid argon2id hash")
+			}
+			if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+				return errors.New(name + " unsupported argon2id version")
+			}
+			if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil ||
+				memory < 1 || iterations < 1 || threads < 1 {
+				return errors.New(name + " invalid argon2id parameters")
+			}
+			if memory > limits.memory || iterations > limits.iterations || threads > limits.threads {
+				return errors.New(fmt.Sprintf("%s argon2id parameters exceed limits m=%d,t=%d,p=%d", name,
+					limits.memory, limits.iterations, limits.threads))
+			}
+			salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+			if err != nil {
+				return errors.New(name + " invalid argon2id salt")
+			}
+			key, err := base64.RawStdEncoding.DecodeString(parts[5])
+			if err != nil || len(key) == 0 {
+				return errors.New(name + " invalid argon2id hash")
+			}
+			actual := argon2.IDKey([]byte(s), salt, iterations, memory, threads, uint32(len(key)))
+			return target.Set(m, subtle.ConstantTimeCompare(actual, key) == 1)
+		}, nil
+	}
+}
+
+// FnCryptoConstantTimeEquals compares two strings in constant time, e.g. ["input/token", "session/token", "result/matched"]
+// Missing fields are not equal
+func FnCryptoConstantTimeEquals(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@crypto_constant_time_equals"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	a, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		av, aok, err := getString(name, a, m)
+		if err != nil {
+			return err
+		}
+		bv, bok, err := getString(name, b, m)
+		if err != nil {
+			return err
+		}
+		return target.Set(m, aok && bok && subtle.ConstantTimeCompare([]byte(av), []byte(bv)) == 1)
+	}, nil
+}
+
+// FnCryptoRandomToken sets cryptographically random token of the bytes in hex, base64 or base64url
+// e.g. [32, "base64url", "reset/token"]
+func FnCryptoRandomToken(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@crypto_random_token"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	n, err := intParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if n < 16 || n > 1024 {
+		return nil, errors.New(name + " bytes should be in range [16, 1024]")
+	}
+	encoding, err := encodingParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		data := make([]byte, n)
+		if _, err := rand.Read(data); err != nil {
+			return err
+		}
+		return target.Set(m, encoding.encode(data))
+	}, nil
+}
@@ -0,0 +1,289 @@
+package fn
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+// Encoding functions
+// Decoded data should be UTF-8 text since it is stored as string in the Model
+
+func decodeFn(name string, params []interface{}, decode func(s string) ([]byte, error)) (pluginapi.Fn, error) {
+	return stringUnaryFn(name, params, func(s string) (interface{}, error) {
+		data, err := decode(s)
+		if err != nil {
+			return nil, errors.New(name + " invalid input:" + err.Error())
+		}
+		if !utf8.Valid(data) {
+			return nil, errors.New(name + " decoded data is not UTF-8 text")
+		}
+		return string(data), nil
+	})
+}
+
+// FnBase64Encode e.g. ["user/name", "user/name_base64"]
+func FnBase64Encode(params []interface{}) (pluginapi.Fn, error) {
+	return stringUnaryFn("@base64_encode", params, func(s string) (interface{}, error) {
+		return base64.StdEncoding.EncodeToString([]byte(s)), nil
+	})
+}
+
+// FnBase64Decode e.g. ["user/name_base64", "user/name"]
+func FnBase64Decode(params []interface{}) (pluginapi.Fn, error) {
+	return decodeFn("@base64_decode", params, base64.StdEncoding.DecodeString)
+}
+
+// FnBase64UrlEncode encodes with URL alphabet and without padding, e.g. ["cursor/raw", "page/cursor"]
+func FnBase64UrlEncode(params []interface{}) (pluginapi.Fn, error) {
+	return stringUnaryFn("@base64url_encode", params, func(s string) (interface{}, error) {
+		return base64.RawURLEncoding.EncodeToString([]byte(s)), nil
+	})
+}
+
+// FnBase64UrlDecode accepts input with or without padding, e.g. ["page/cursor", "cursor/raw"]
+func FnBase64UrlDecode(params []interface{}) (pluginapi.Fn, error) {
+	return decodeFn("@base64url_decode", params, func(s string) ([]byte, error) {
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	})
+}
+
+// FnHexEncode e.g. ["user/name", "user/name_hex"]
+func FnHexEncode(params []interface{}) (pluginapi.Fn, error) {
+	return stringUnaryFn("@hex_encode", params, func(s string) (interface{}, error) {
+		return hex.EncodeToString([]byte(s)), nil
+	})
+}
+
+// FnHexDecode e.g. ["user/name_hex", "user/name"]
+func FnHexDecode(params []interface{}) (pluginapi.Fn, error) {
+	return decodeFn("@hex_decode", params, hex.DecodeString)
+}
+
+// FnUrlEncode escapes the string as query component, e.g. ["search/keyword", "search/keyword_escaped"]
+func FnUrlEncode(params []interface{}) (pluginapi.Fn, error) {
+	return stringUnaryFn("@url_encode", params, func(s string) (interface{}, error) {
+		return url.QueryEscape(s), nil
+	})
+}
+
+// FnUrlDecode unescapes query component, '+' is decoded as space, e.g. ["search/keyword_escaped", "search/keyword"]
+func FnUrlDecode(params []interface{}) (pluginapi.Fn, error) {
+	return decodeFn("@url_decode", params, func(s string) ([]byte, error) {
+		r, err := url.QueryUnescape(s)
+		return []byte(r), err
+	})
+}
+
+// definitionPathParam returns definition path(e.g. order/items[]) and path of the field(e.g. [order, items])
+// Only the last level could be array definition
+func definitionPathParam(name string, params []interface{}, idx int) (string, []string, error) {
+	field, err := stringParam(name, params, idx)
+	if err != nil {
+		return "", nil, err
+	}
+	if !rule.ValidateFullPathOfDefinition(field) {
+		return "", nil, errors.New("path invalid:" + field)
+	}
+	paths := rule.SplitFullPath(field)
+	for _, lv := range paths[:len(paths)-1] {
+		if rule.IsArrayDefinition(lv) {
+			return "", nil, errors.New(fmt.Sprintf("%s parameter %d should not be inside array:%s", name, idx, field))
+		}
+	}
+	paths[len(paths)-1], _ = rule.ExtractArrayPath(paths[len(paths)-1])
+	return field, paths, nil
+}
+
+// checkGeneralObject converts decoded value to data types of the definition path
+// Fields not defined are dropped, int is converted to float if float is defined
+func checkGeneralObject(name string, resolver pluginapi.PathTypeResolver, path string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	dt, elemDt, err := resolver.TypeOfDefinitionPath(path)
+	if err != nil {
+		// array levels mismatch the definitions
+		return nil, fmt.Errorf("%w: %s path=[%s] %s", basicapi.ErrFieldTypeMismatch, name, path, err)
+	}
+	switch dt {
+	case pluginapi.DataTypeUnavailable:
+		return nil, nil
+	case pluginapi.DataTypeObject:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s path=[%s] expected=[object]", basicapi.ErrFieldTypeMismatch, name, path)
+		}
+		r := make(map[string]interface{}, len(obj))
+		for key, val := range obj {
+			lv := key
+			if _, ok := val.([]interface{}); ok {
+				lv = key + "[]"
+			}
+			sub, err := checkGeneralObject(name, resolver, rule.ConcatFullPath([]string{path, lv}), val)
+			if err != nil {
+				return nil, err
+			}
+			if sub != nil {
+				r[key] = sub
+			}
+		}
+		return r, nil
+	case pluginapi.DataTypeArray:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %s path=[%s] expected=[array]", basicapi.ErrFieldTypeMismatch, name, path)
+		}
+		r := make([]interface{}, 0, len(arr))
+		for _, elem := range arr {
+			var val interface{}
+			var err error
+			if elem == nil && elemDt != pluginapi.DataTypeUnavailable {
+				// null elements of primitive array are dropped
+				continue
+			} else if elemDt != pluginapi.DataTypeUnavailable {
+				val, err = checkPrimitive(name, path, elemDt, elem)
+			} else if elem == nil {
+				val = map[string]interface{}{}
+			} else {
+				// object array elements are checked as object of the same definition path
+				val, err = checkGeneralObject(name, objectElementResolver{resolver, path}, path, elem)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if val != nil {
+				r = append(r, val)
+			}
+		}
+		return r, nil
+	default:
+		return checkPrimitive(name, path, dt, v)
+	}
+}
+
+// objectElementResolver resolves the element of object array as object
+type objectElementResolver struct {
+	pluginapi.PathTypeResolver
+	arrayPath string
+}
+
+func (r objectElementResolver) TypeOfDefinitionPath(path string) (pluginapi.DataType, pluginapi.DataType, error) {
+	if path == r.arrayPath {
+		return pluginapi.DataTypeObject, pluginapi.DataTypeUnavailable, nil
+	}
+	return r.PathTypeResolver.TypeOfDefinitionPath(path)
+}
+
+func checkPrimitive(name, path string, dt pluginapi.DataType, v interface{}) (interface{}, error) {
+	var expected string
+	switch dt {
+	case pluginapi.DataTypeString:
+		if _, ok := v.(string); ok {
+			return v, nil
+		}
+		expected = "string"
+	case pluginapi.DataTypeInt:
+		if _, ok := v.(int64); ok {
+			return v, nil
+		}
+		expected = "int"
+	case pluginapi.DataTypeFloat:
+		switch f := v.(type) {
+		case float64:
+			return f, nil
+		case int64:
+			return float64(f), nil
+		}
+		expected = "float"
+	case pluginapi.DataTypeBool:
+		if _, ok := v.(bool); ok {
+			return v, nil
+		}
+		expected = "bool"
+	}
+	return nil, fmt.Errorf("%w: %s path=[%s] value=[%v] expected=[%s]", basicapi.ErrFieldTypeMismatch, name, path, v, expected)
+}
+
+// FnJsonParse parses json string into the object or array which is checked against the data types of FlowModel
+// Target should be defined in in/out mappings or local variables of the flow, fields not defined are dropped
+// e.g. ["order/extra_json", "order/extra"], ["order/items_json", "order/items[]"]
+func FnJsonParse(resolver pluginapi.PathTypeResolver, params []interface{}) (pluginapi.Fn, error) {
+	const name = "@json_parse"
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	src, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	targetDefinition, target, err := definitionPathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	if dt, _, err := resolver.TypeOfDefinitionPath(targetDefinition); err != nil {
+		return nil, errors.New(name + " " + err.Error())
+	} else if dt != pluginapi.DataTypeObject && dt != pluginapi.DataTypeArray {
+		return nil, errors.New(name + " target should be object or array defined in mappings or local variables:" + targetDefinition)
+	}
+	return func(m pluginapi.Model) error {
+		s, ok, err := getString(name, src, m)
+		if err != nil || !ok {
+			return err
+		}
+		decoder := json.NewDecoder(strings.NewReader(s))
+		decoder.UseNumber()
+		var obj interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			return errors.New(name + " invalid json:" + err.Error())
+		}
+		if decoder.More() {
+			return errors.New(name + " invalid json: unexpected data after the value")
+		}
+		r, err := checkGeneralObject(name, resolver, targetDefinition, convertJsonNumbers(obj))
+		if err != nil {
+			return err
+		}
+		return setGeneralObject(m, target, r)
+	}, nil
+}
+
+// FnJsonStringify serializes the object, array or primitive field into json string
+// e.g. ["order/extra", "order/extra_json"], ["order/tags[]", "order/tags_json"]
+func FnJsonStringify(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@json_stringify"
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	_, src, err := definitionPathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		v, ok, err := getGeneralObject(m, src)
+		if err != nil || !ok {
+			return err
+		}
+		buf := new(bytes.Buffer)
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(v); err != nil {
+			return errors.New(name + " " + err.Error())
+		}
+		return target.Set(m, strings.TrimSuffix(buf.String(), "\n"))
+	}, nil
+}
@@ -1,49 +1,54 @@
 package fn
 
 import (
-	"errors"
-	"time"
-
 	"github.com/gofrs/uuid/v5"
 
 	"github.com/FimGroup/fim/fimapi/pluginapi"
-	"github.com/FimGroup/fim/fimapi/rule"
 )
 
 func FnAssign(params []interface{}) (pluginapi.Fn, error) {
-	var field string = params[0].(string)
-	if !rule.ValidateFullPath(field) {
-		return nil, errors.New("path invalid:" + field)
+	const name = "@assign"
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	accessor, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
 	}
-	fieldPaths := rule.SplitFullPath(field)
 	var val = params[1]
 	return func(m pluginapi.Model) error {
-		return m.AddOrUpdateField0(fieldPaths, val)
+		return accessor.Set(m, val)
 	}, nil
 }
 
 func FnUUID(params []interface{}) (pluginapi.Fn, error) {
-	var field string = params[0].(string)
-	if !rule.ValidateFullPath(field) {
-		return nil, errors.New("path invalid:" + field)
+	const name = "@uuid"
+	if err := requireParams(name, params, 1); err != nil {
+		return nil, err
+	}
+	accessor, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
 	}
-	fieldPaths := rule.SplitFullPath(field)
 	return func(m pluginapi.Model) error {
 		u, err := uuid.NewV4()
 		if err != nil {
 			return err
 		}
-		return m.AddOrUpdateField0(fieldPaths, u.String())
+		return accessor.Set(m, u.String())
 	}, nil
 }
 
 func FnSetCurrentUnixTimestamp(params []interface{}) (pluginapi.Fn, error) {
-	var field string = params[0].(string)
-	if !rule.ValidateFullPath(field) {
-		return nil, errors.New("path invalid:" + field)
+	const name = "@set_current_unix_timestamp"
+	if err := requireParams(name, params, 1); err != nil {
+		return nil, err
+	}
+	accessor, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
 	}
-	fieldPaths := rule.SplitFullPath(field)
 	return func(m pluginapi.Model) error {
-		return m.AddOrUpdateField0(fieldPaths, int(time.Now().UnixMilli()))
+		return accessor.Set(m, clock().UnixMilli())
 	}, nil
 }
@@ -0,0 +1,124 @@
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/tools"
+)
+
+// Time-ordered ID functions
+// Generators are shared by all flows so that IDs are increasing in the same instance
+
+func idClock() time.Time {
+	return clock()
+}
+
+var (
+	uuidV7Generator = tools.NewUuidV7Generator(idClock)
+	ulidGenerator   = tools.NewUlidGenerator(idClock)
+	ksuidGenerator  = tools.NewKsuidGenerator(idClock)
+
+	snowflakeLock       sync.Mutex
+	snowflakeGenerators = map[int64]*tools.SnowflakeGenerator{}
+)
+
+func idFn(name string, params []interface{}, generator tools.IdGenerator) (pluginapi.Fn, error) {
+	if err := requireParams(name, params, 1); err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		id, err := generator.NextId()
+		if err != nil {
+			return fmt.Errorf("%s %w", name, err)
+		}
+		return target.Set(m, id)
+	}, nil
+}
+
+// FnIdUuidV7 e.g. ["order/id"]
+func FnIdUuidV7(params []interface{}) (pluginapi.Fn, error) {
+	return idFn("@id_uuidv7", params, uuidV7Generator)
+}
+
+// FnIdUlid e.g. ["order/id"]
+func FnIdUlid(params []interface{}) (pluginapi.Fn, error) {
+	return idFn("@id_ulid", params, ulidGenerator)
+}
+
+// FnIdKsuid e.g. ["order/id"]
+func FnIdKsuid(params []interface{}) (pluginapi.Fn, error) {
+	return idFn("@id_ksuid", params, ksuidGenerator)
+}
+
+// snowflakeNodeParam returns node of int literal or configure-static placeholder
+func snowflakeNodeParam(cm basicapi.ConfigureManager, name string, params []interface{}, idx int) (int64, error) {
+	if s, ok := params[idx].(string); ok && strings.HasPrefix(s, basicapi.ConfigurePrefixStatic) {
+		v := cm.ReplaceStaticConfigure(s)
+		if v == s {
+			return 0, errors.New(name + " configure not found:" + s)
+		}
+		node, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("%s node should be int:%s=%s", name, s, v))
+		}
+		return node, nil
+	}
+	return intParam(name, params, idx)
+}
+
+func snowflakeGenerator(node int64) (*tools.SnowflakeGenerator, error) {
+	snowflakeLock.Lock()
+	defer snowflakeLock.Unlock()
+	if g, ok := snowflakeGenerators[node]; ok {
+		return g, nil
+	}
+	g, err := tools.NewSnowflakeGenerator(node, idClock)
+	if err != nil {
+		return nil, err
+	}
+	snowflakeGenerators[node] = g
+	return g, nil
+}
+
+// FnIdSnowflake generates int ID, node should be unique among instances and is usually from configuration
+// Generation fails if the clock moves backwards more than 5s
+// e.g. ["configure-static://idgen.node", "order/id"], [1, "order/id"]
+func FnIdSnowflake(cm basicapi.ConfigureManager) pluginapi.FnGen {
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		const name = "@id_snowflake"
+		if err := requireParams(name, params, 2); err != nil {
+			return nil, err
+		}
+		node, err := snowflakeNodeParam(cm, name, params, 0)
+		if err != nil {
+			return nil, err
+		}
+		generator, err := snowflakeGenerator(node)
+		if err != nil {
+			return nil, fmt.Errorf("%s %w", name, err)
+		}
+		target, err := pathParam(name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		return func(m pluginapi.Model) error {
+			id, err := generator.Next()
+			if err != nil {
+				// keep tools.ErrClockRollback for errors.Is
+				return fmt.Errorf("%s %w", name, err)
+			}
+			return target.Set(m, id)
+		}, nil
+	}
+}
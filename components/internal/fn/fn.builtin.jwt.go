@@ -0,0 +1,285 @@
+package fn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+)
+
+// JWT functions
+// Algorithms: HS256/384/512, RS256/384/512, ES256/384/512
+// Keys are configure placeholders or file placeholders(resource-file://<file resource manager name>/<path>)
+// HS keys are raw secrets, RS/ES keys are PEM encoded: private keys for signing and public keys or certificates for verifying
+
+var jwtAlgorithms = map[string]jwa.SignatureAlgorithm{
+	"HS256": jwa.HS256,
+	"HS384": jwa.HS384,
+	"HS512": jwa.HS512,
+	"RS256": jwa.RS256,
+	"RS384": jwa.RS384,
+	"RS512": jwa.RS512,
+	"ES256": jwa.ES256,
+	"ES384": jwa.ES384,
+	"ES512": jwa.ES512,
+}
+
+func jwtAlgorithmParam(name string, params []interface{}, idx int) (jwa.SignatureAlgorithm, error) {
+	alg, err := stringParam(name, params, idx)
+	if err != nil {
+		return "", err
+	}
+	a, ok := jwtAlgorithms[alg]
+	if !ok {
+		return "", errors.New(name + " unsupported algorithm:" + alg)
+	}
+	return a, nil
+}
+
+// parseJwtKey converts key material to the key of the algorithm
+func parseJwtKey(name string, alg jwa.SignatureAlgorithm, data []byte, private bool) (interface{}, error) {
+	if strings.HasPrefix(alg.String(), "HS") {
+		if len(data) == 0 {
+			return nil, errors.New(name + " empty key")
+		}
+		return data, nil
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New(name + " key should be PEM encoded")
+	}
+	var key interface{}
+	var err error
+	switch {
+	case private && block.Type == "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case private && block.Type == "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case private && block.Type == "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case !private && block.Type == "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case !private && block.Type == "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case !private && block.Type == "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, errors.New(name + " unsupported PEM type:" + block.Type)
+	}
+	if err != nil {
+		return nil, errors.New(name + " invalid key:" + err.Error())
+	}
+	return key, nil
+}
+
+// jwtKeyParam returns resolver of the key, static keys are parsed when the flow is loaded
+func jwtKeyParam(container pluginapi.Container, name string, params []interface{}, idx int, alg jwa.SignatureAlgorithm, private bool) (func() (interface{}, error), error) {
+	material, static, err := keyParam(container, name, params, idx)
+	if err != nil {
+		return nil, err
+	}
+	if static {
+		data, err := material()
+		if err != nil {
+			return nil, err
+		}
+		key, err := parseJwtKey(name, alg, data, private)
+		if err != nil {
+			return nil, err
+		}
+		return func() (interface{}, error) {
+			return key, nil
+		}, nil
+	}
+	return func() (interface{}, error) {
+		data, err := material()
+		if err != nil {
+			return nil, err
+		}
+		return parseJwtKey(name, alg, data, private)
+	}, nil
+}
+
+// FnJwtSign issues token with fields of the claims object, iat and exp(current time + expiry) are set automatically
+// Claims object is optional, e.g. {"sub": "1001", "roles": ["admin"]}
+// e.g. ["token/claims", "HS256", "configure-static://jwt.secret", "2h", "token/access_token"]
+func FnJwtSign(container pluginapi.Container) pluginapi.FnGen {
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		const name = "@jwt_sign"
+		if err := requireParams(name, params, 5); err != nil {
+			return nil, err
+		}
+		claims, err := pathParam(name, params, 0)
+		if err != nil {
+			return nil, err
+		}
+		alg, err := jwtAlgorithmParam(name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		keyOf, err := jwtKeyParam(container, name, params, 2, alg, true)
+		if err != nil {
+			return nil, err
+		}
+		expiryString, err := stringParam(name, params, 3)
+		if err != nil {
+			return nil, err
+		}
+		expiry, err := time.ParseDuration(expiryString)
+		if err != nil || expiry <= 0 {
+			return nil, errors.New(name + " expiry should be positive duration:" + expiryString)
+		}
+		target, err := pathParam(name, params, 4)
+		if err != nil {
+			return nil, err
+		}
+		return func(m pluginapi.Model) error {
+			t := jwt.New()
+			sub, err := m.CopySubModel(claims.Path())
+			if err != nil && !errors.Is(err, basicapi.ErrFieldNotFound) {
+				return err
+			}
+			if sub != nil {
+				obj, _ := sub.ToGeneralObject().(map[string]interface{})
+				for k, v := range obj {
+					if err := t.Set(k, v); err != nil {
+						return errors.New(name + " invalid claim " + k + ":" + err.Error())
+					}
+				}
+			}
+			now := clock()
+			if err := t.Set(jwt.IssuedAtKey, now); err != nil {
+				return err
+			}
+			if err := t.Set(jwt.ExpirationKey, now.Add(expiry)); err != nil {
+				return err
+			}
+			key, err := keyOf()
+			if err != nil {
+				return err
+			}
+			token, err := jwt.Sign(t, alg, key)
+			if err != nil {
+				return errors.New(name + " sign failed:" + err.Error())
+			}
+			return target.Set(m, string(token))
+		}, nil
+	}
+}
+
+// decodeJwtClaims decodes payload of the token, integer numbers are int and others are float
+func decodeJwtClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var claims map[string]interface{}
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, err
+	}
+	return convertJsonNumbers(claims).(map[string]interface{}), nil
+}
+
+func convertJsonNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range val {
+			val[k] = convertJsonNumbers(sub)
+		}
+		return val
+	case []interface{}:
+		for i, sub := range val {
+			val[i] = convertJsonNumbers(sub)
+		}
+		return val
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	default:
+		return v
+	}
+}
+
+// FnJwtVerify verifies signature, exp and nbf of the token and decodes claims into the object
+// Bool result is false and claims object is not changed if verification fails
+// e.g. ["request/token", "RS256", "resource-file://keys/jwt.pub.pem", "session/claims", "session/authenticated"]
+func FnJwtVerify(container pluginapi.Container) pluginapi.FnGen {
+	return func(params []interface{}) (pluginapi.Fn, error) {
+		const name = "@jwt_verify"
+		if err := requireParams(name, params, 5); err != nil {
+			return nil, err
+		}
+		src, err := pathParam(name, params, 0)
+		if err != nil {
+			return nil, err
+		}
+		alg, err := jwtAlgorithmParam(name, params, 1)
+		if err != nil {
+			return nil, err
+		}
+		keyOf, err := jwtKeyParam(container, name, params, 2, alg, false)
+		if err != nil {
+			return nil, err
+		}
+		claims, err := pathParam(name, params, 3)
+		if err != nil {
+			return nil, err
+		}
+		result, err := pathParam(name, params, 4)
+		if err != nil {
+			return nil, err
+		}
+		return func(m pluginapi.Model) error {
+			token, ok, err := getString(name, src, m)
+			if err != nil {
+				return err
+			} else if !ok {
+				return result.Set(m, false)
+			}
+			// bearer prefix of authorization header is allowed
+			token = strings.TrimPrefix(token, "Bearer ")
+			key, err := keyOf()
+			if err != nil {
+				return err
+			}
+			t, err := jwt.ParseString(token, jwt.WithVerify(alg, key))
+			if err != nil {
+				return result.Set(m, false)
+			}
+			if err := jwt.Validate(t, jwt.WithClock(jwt.ClockFunc(clock))); err != nil {
+				return result.Set(m, false)
+			}
+			obj, err := decodeJwtClaims(token)
+			if err != nil {
+				return result.Set(m, false)
+			}
+			if err := setGeneralObject(m, claims.Path(), obj); err != nil {
+				return err
+			}
+			return result.Set(m, true)
+		}, nil
+	}
+}
@@ -0,0 +1,386 @@
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+// Arithmetic functions
+// Operands are number literals or paths of int/float fields, the last parameter is the path of the result
+// Result is int if all operands are int, otherwise float
+// Missing operand fields are errors
+
+// numberOperand is a literal number or an accessor of the field
+type numberOperand struct {
+	literal  interface{}
+	accessor *pluginapi.FieldAccessor
+}
+
+func numberOperandParam(name string, params []interface{}, idx int) (numberOperand, error) {
+	if _, ok := params[idx].(string); ok {
+		accessor, err := pathParam(name, params, idx)
+		if err != nil {
+			return numberOperand{}, err
+		}
+		return numberOperand{accessor: accessor}, nil
+	}
+	v, err := basicapi.ConvertPrimitive(params[idx])
+	if err != nil {
+		return numberOperand{}, errors.New(fmt.Sprintf("%s parameter %d should be number or path", name, idx))
+	}
+	switch v.(type) {
+	case int64, float64:
+		return numberOperand{literal: v}, nil
+	default:
+		return numberOperand{}, errors.New(fmt.Sprintf("%s parameter %d should be number or path", name, idx))
+	}
+}
+
+// value returns int64 or float64 of the operand
+func (o numberOperand) value(name string, m pluginapi.Model) (interface{}, error) {
+	if o.accessor == nil {
+		return o.literal, nil
+	}
+	v := o.accessor.Get(m)
+	switch v.(type) {
+	case nil:
+		return nil, fmt.Errorf("%w: %s path=[%s]", basicapi.ErrFieldNotFound, name, rule.ConcatFullPath(o.accessor.Path()))
+	case int64, float64:
+		return v, nil
+	default:
+		return nil, typeMismatchError(name, o.accessor.Path(), v, "int or float")
+	}
+}
+
+func toFloat(v interface{}) float64 {
+	if i, ok := v.(int64); ok {
+		return float64(i)
+	}
+	return v.(float64)
+}
+
+func mathError(key, name, message string) error {
+	return &pluginapi.FlowError{
+		Key:     key,
+		Message: name + ": " + message,
+	}
+}
+
+func checkFloatResult(name string, f float64) (interface{}, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, mathError(pluginapi.FlowErrorKeyNumericOverflow, name, "result is not a finite number")
+	}
+	return f, nil
+}
+
+// binaryMathFn generates function calculating [a, b, target]
+func binaryMathFn(name string, params []interface{}, intOp func(a, b int64) (int64, error), floatOp func(a, b float64) (float64, error)) (pluginapi.Fn, error) {
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	a, err := numberOperandParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := numberOperandParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		av, err := a.value(name, m)
+		if err != nil {
+			return err
+		}
+		bv, err := b.value(name, m)
+		if err != nil {
+			return err
+		}
+		ai, aok := av.(int64)
+		bi, bok := bv.(int64)
+		if aok && bok {
+			r, err := intOp(ai, bi)
+			if err != nil {
+				return err
+			}
+			return target.Set(m, r)
+		}
+		r, err := floatOp(toFloat(av), toFloat(bv))
+		if err != nil {
+			return err
+		}
+		v, err := checkFloatResult(name, r)
+		if err != nil {
+			return err
+		}
+		return target.Set(m, v)
+	}, nil
+}
+
+// FnMathAdd e.g. ["order/amount", "order/fee", "order/total"]
+func FnMathAdd(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@math_add"
+	return binaryMathFn(name, params, func(a, b int64) (int64, error) {
+		r := a + b
+		if (r > a) != (b > 0) {
+			return 0, mathError(pluginapi.FlowErrorKeyNumericOverflow, name, "int overflow")
+		}
+		return r, nil
+	}, func(a, b float64) (float64, error) {
+		return a + b, nil
+	})
+}
+
+// FnMathSubtract e.g. ["page/no", 1, "page/offset"]
+func FnMathSubtract(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@math_subtract"
+	return binaryMathFn(name, params, func(a, b int64) (int64, error) {
+		r := a - b
+		if (r < a) != (b > 0) {
+			return 0, mathError(pluginapi.FlowErrorKeyNumericOverflow, name, "int overflow")
+		}
+		return r, nil
+	}, func(a, b float64) (float64, error) {
+		return a - b, nil
+	})
+}
+
+// FnMathMultiply e.g. ["page/offset", "page/size", "page/offset"]
+func FnMathMultiply(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@math_multiply"
+	return binaryMathFn(name, params, func(a, b int64) (int64, error) {
+		if a == 0 || b == 0 {
+			return 0, nil
+		}
+		r := a * b
+		if r/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+			return 0, mathError(pluginapi.FlowErrorKeyNumericOverflow, name, "int overflow")
+		}
+		return r, nil
+	}, func(a, b float64) (float64, error) {
+		return a * b, nil
+	})
+}
+
+// FnMathDivide divides a by b, int division truncates toward zero, e.g. ["order/total", "order/count", "order/avg"]
+func FnMathDivide(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@math_divide"
+	return binaryMathFn(name, params, func(a, b int64) (int64, error) {
+		if b == 0 {
+			return 0, mathError(pluginapi.FlowErrorKeyDivisionByZero, name, "division by zero")
+		}
+		if a == math.MinInt64 && b == -1 {
+			return 0, mathError(pluginapi.FlowErrorKeyNumericOverflow, name, "int overflow")
+		}
+		return a / b, nil
+	}, func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, mathError(pluginapi.FlowErrorKeyDivisionByZero, name, "division by zero")
+		}
+		return a / b, nil
+	})
+}
+
+// FnMathModulo remainder with the sign of a, e.g. ["user/user_id", 16, "user/shard"]
+func FnMathModulo(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@math_modulo"
+	return binaryMathFn(name, params, func(a, b int64) (int64, error) {
+		if b == 0 {
+			return 0, mathError(pluginapi.FlowErrorKeyDivisionByZero, name, "division by zero")
+		}
+		if b == -1 {
+			return 0, nil
+		}
+		return a % b, nil
+	}, func(a, b float64) (float64, error) {
+		if b == 0 {
+			return 0, mathError(pluginapi.FlowErrorKeyDivisionByZero, name, "division by zero")
+		}
+		return math.Mod(a, b), nil
+	})
+}
+
+// FnMathMin e.g. ["page/size", 100, "page/size"]
+func FnMathMin(params []interface{}) (pluginapi.Fn, error) {
+	return binaryMathFn("@math_min", params, func(a, b int64) (int64, error) {
+		if a < b {
+			return a, nil
+		}
+		return b, nil
+	}, func(a, b float64) (float64, error) {
+		return math.Min(a, b), nil
+	})
+}
+
+// FnMathMax e.g. ["page/no", 1, "page/no"]
+func FnMathMax(params []interface{}) (pluginapi.Fn, error) {
+	return binaryMathFn("@math_max", params, func(a, b int64) (int64, error) {
+		if a > b {
+			return a, nil
+		}
+		return b, nil
+	}, func(a, b float64) (float64, error) {
+		return math.Max(a, b), nil
+	})
+}
+
+// FnMathAbs e.g. ["account/delta", "account/delta"]
+func FnMathAbs(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@math_abs"
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	src, err := numberOperandParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		v, err := src.value(name, m)
+		if err != nil {
+			return err
+		}
+		if i, ok := v.(int64); ok {
+			if i == math.MinInt64 {
+				return mathError(pluginapi.FlowErrorKeyNumericOverflow, name, "int overflow")
+			}
+			if i < 0 {
+				i = -i
+			}
+			return target.Set(m, i)
+		}
+		return target.Set(m, math.Abs(v.(float64)))
+	}, nil
+}
+
+var roundingModes = map[string]func(float64) float64{
+	"half_up":   math.Round,
+	"half_even": math.RoundToEven,
+	"floor":     math.Floor,
+	"ceil":      math.Ceil,
+	"truncate":  math.Trunc,
+}
+
+// FnMathRound rounds the number to the decimal places by the mode: half_up, half_even, floor, ceil, truncate
+// Result is int if decimal places is 0, otherwise float
+// e.g. ["order/price", "half_even", 2, "order/price"]
+func FnMathRound(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@math_round"
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	src, err := numberOperandParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := stringParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	round, ok := roundingModes[mode]
+	if !ok {
+		return nil, errors.New(name + " unknown rounding mode:" + mode)
+	}
+	places, err := intParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	if places < 0 || places > 15 {
+		return nil, errors.New(name + " decimal places should be in range [0, 15]")
+	}
+	target, err := pathParam(name, params, 3)
+	if err != nil {
+		return nil, err
+	}
+	scale := math.Pow10(int(places))
+	return func(m pluginapi.Model) error {
+		v, err := src.value(name, m)
+		if err != nil {
+			return err
+		}
+		if i, ok := v.(int64); ok {
+			if places == 0 {
+				return target.Set(m, i)
+			}
+			return target.Set(m, float64(i))
+		}
+		r := round(v.(float64)*scale) / scale
+		if places > 0 {
+			f, err := checkFloatResult(name, r)
+			if err != nil {
+				return err
+			}
+			return target.Set(m, f)
+		}
+		if math.IsNaN(r) || r >= math.MaxInt64 || r < math.MinInt64 {
+			return mathError(pluginapi.FlowErrorKeyNumericOverflow, name, "result out of int range")
+		}
+		return target.Set(m, int64(r))
+	}, nil
+}
+
+// FnMathClamp limits the number in range [min, max], min and max are number literals or paths
+// e.g. ["page/size", 1, 100, "page/size"]
+func FnMathClamp(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@math_clamp"
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	src, err := numberOperandParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	lower, err := numberOperandParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	upper, err := numberOperandParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	if lower.accessor == nil && upper.accessor == nil && toFloat(lower.literal) > toFloat(upper.literal) {
+		return nil, errors.New(name + " min should not be greater than max")
+	}
+	target, err := pathParam(name, params, 3)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		var values [3]interface{}
+		for i, o := range []numberOperand{src, lower, upper} {
+			v, err := o.value(name, m)
+			if err != nil {
+				return err
+			}
+			values[i] = v
+		}
+		v, lo, hi := values[0], values[1], values[2]
+		if toFloat(lo) > toFloat(hi) {
+			return errors.New(name + " min should not be greater than max")
+		}
+		if vi, ok := v.(int64); ok {
+			loi, lok := lo.(int64)
+			hii, hok := hi.(int64)
+			if lok && hok {
+				if vi < loi {
+					vi = loi
+				} else if vi > hii {
+					vi = hii
+				}
+				return target.Set(m, vi)
+			}
+		}
+		return target.Set(m, math.Min(math.Max(toFloat(v), toFloat(lo)), toFloat(hi)))
+	}, nil
+}
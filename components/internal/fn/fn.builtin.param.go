@@ -0,0 +1,222 @@
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+// Parameter helpers of builtin functions
+// Parameters are validated when the flow is loaded so that functions fail fast on misconfiguration
+
+func requireParams(name string, params []interface{}, cnt int) error {
+	if len(params) != cnt {
+		return errors.New(fmt.Sprintf("%s requires %d parameters", name, cnt))
+	}
+	return nil
+}
+
+func requireMinParams(name string, params []interface{}, cnt int) error {
+	if len(params) < cnt {
+		return errors.New(fmt.Sprintf("%s requires at least %d parameters", name, cnt))
+	}
+	return nil
+}
+
+func stringParam(name string, params []interface{}, idx int) (string, error) {
+	s, ok := params[idx].(string)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("%s parameter %d should be string", name, idx))
+	}
+	return s, nil
+}
+
+func intParam(name string, params []interface{}, idx int) (int64, error) {
+	v, err := basicapi.ConvertPrimitive(params[idx])
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("%s parameter %d should be int", name, idx))
+	}
+	switch i := v.(type) {
+	case int64:
+		return i, nil
+	case float64:
+		// numbers decoded from json
+		if i == float64(int64(i)) {
+			return int64(i), nil
+		}
+	}
+	return 0, errors.New(fmt.Sprintf("%s parameter %d should be int", name, idx))
+}
+
+// pathParam returns accessor of the field path parameter
+func pathParam(name string, params []interface{}, idx int) (*pluginapi.FieldAccessor, error) {
+	field, err := stringParam(name, params, idx)
+	if err != nil {
+		return nil, err
+	}
+	if !rule.ValidateFullPath(field) {
+		return nil, errors.New("path invalid:" + field)
+	}
+	return pluginapi.NewFieldAccessor(rule.SplitFullPath(field)), nil
+}
+
+// arrayPathParam returns path of the primitive array parameter, e.g. result/tags[] -> [result, tags]
+func arrayPathParam(name string, params []interface{}, idx int) ([]string, error) {
+	field, err := stringParam(name, params, idx)
+	if err != nil {
+		return nil, err
+	}
+	if !rule.ValidateFullPathOfDefinition(field) {
+		return nil, errors.New("path invalid:" + field)
+	}
+	paths := rule.SplitFullPath(field)
+	lastPath := paths[len(paths)-1]
+	if !rule.IsArrayDefinition(lastPath) {
+		return nil, errors.New(fmt.Sprintf("%s parameter %d should be array definition, e.g. result/tags[]:%s", name, idx, field))
+	}
+	paths[len(paths)-1], _ = rule.ExtractArrayPath(lastPath)
+	return paths, nil
+}
+
+// getString returns string value of the field, false if the field does not exist
+func getString(name string, accessor *pluginapi.FieldAccessor, m pluginapi.Model) (string, bool, error) {
+	val := accessor.Get(m)
+	if val == nil {
+		return "", false, nil
+	}
+	s, ok := val.(string)
+	if !ok {
+		return "", false, typeMismatchError(name, accessor.Path(), val, "string")
+	}
+	return s, true, nil
+}
+
+func typeMismatchError(name string, path []string, val interface{}, expected string) error {
+	return fmt.Errorf("%w: %s path=[%s] type=[%s] expected=[%s]", basicapi.ErrFieldTypeMismatch, name, rule.ConcatFullPath(path), reflect.TypeOf(val), expected)
+}
+
+// setArray replaces the primitive array with the values, empty array is kept if there is no value
+func setArray(m pluginapi.Model, path []string, values []interface{}) error {
+	if err := basicapi.ResetArray(m, path); err != nil {
+		return err
+	}
+	for _, v := range values {
+		if _, err := m.AppendArrayElement(path, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getArray returns elements of the primitive array, empty if the array does not exist
+func getArray(m pluginapi.Model, path []string) ([]interface{}, error) {
+	n, err := m.ArrayLength(path)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, n)
+	elemPath := append([]string{}, path...)
+	last := path[len(path)-1]
+	for i := 0; i < n; i++ {
+		elemPath[len(elemPath)-1] = fmt.Sprintf("%s[%d]", last, i)
+		v, err := m.GetField(elemPath)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// secretParam returns resolver of the key parameter
+// Keys should be configure placeholders so that they are never inline in flows
+// Static configure is resolved when the flow is loaded and dynamic configure is resolved every time it is used
+func secretParam(cm basicapi.ConfigureManager, name string, params []interface{}, idx int) (func() (string, error), error) {
+	placeholder, err := stringParam(name, params, idx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(placeholder, basicapi.ConfigurePrefixStatic):
+		v := cm.ReplaceStaticConfigure(placeholder)
+		if v == placeholder {
+			return nil, errors.New(name + " configure not found:" + placeholder)
+		}
+		return func() (string, error) {
+			return v, nil
+		}, nil
+	case strings.HasPrefix(placeholder, basicapi.ConfigurePrefixDynamic):
+		if !cm.SupportDynamicConfigure(placeholder) {
+			return nil, errors.New(name + " dynamic configure not supported:" + placeholder)
+		}
+		return func() (string, error) {
+			v := cm.ReplaceDynamicConfigure(placeholder)
+			if v == placeholder {
+				return "", errors.New(name + " configure not found:" + placeholder)
+			}
+			return v, nil
+		}, nil
+	default:
+		return nil, errors.New(fmt.Sprintf("%s parameter %d should be %s or %s placeholder", name, idx, basicapi.ConfigurePrefixStatic, basicapi.ConfigurePrefixDynamic))
+	}
+}
+
+// keyParam returns resolver of key material which is configure placeholder(see secretParam) or
+// file placeholder(resource-file://<file resource manager name>/<path>) loaded when the flow is loaded
+// static is true if the key is not changed at runtime
+func keyParam(container pluginapi.Container, name string, params []interface{}, idx int) (key func() ([]byte, error), static bool, err error) {
+	placeholder, err := stringParam(name, params, idx)
+	if err != nil {
+		return nil, false, err
+	}
+	if !strings.HasPrefix(placeholder, pluginapi.FileResourcePrefix) {
+		secret, err := secretParam(container.ConfigureManager(), name, params, idx)
+		if err != nil {
+			return nil, false, err
+		}
+		return func() ([]byte, error) {
+			s, err := secret()
+			return []byte(s), err
+		}, !strings.HasPrefix(placeholder, basicapi.ConfigurePrefixDynamic), nil
+	}
+	managerName, path, ok := strings.Cut(placeholder[len(pluginapi.FileResourcePrefix):], "/")
+	if !ok || managerName == "" || path == "" {
+		return nil, false, errors.New(name + " invalid file placeholder:" + placeholder)
+	}
+	fm := container.GetFileResourceManager(managerName)
+	if fm == nil {
+		return nil, false, errors.New(name + " file resource manager not found:" + managerName)
+	}
+	data, err := fm.LoadFile(path)
+	if err != nil {
+		return nil, false, errors.New(fmt.Sprintf("%s load file=[%s] failed:%s", name, placeholder, err))
+	}
+	return func() ([]byte, error) {
+		return data, nil
+	}, true, nil
+}
+
+// getGeneralObject returns general object(see Model.ToGeneralObject) of the field, object or array, false if not exists
+// Only the field is copied, see basicapi.GetGeneralObject
+func getGeneralObject(m pluginapi.Model, path []string) (interface{}, bool, error) {
+	v, err := basicapi.GetGeneralObject(m, path)
+	if errors.Is(err, basicapi.ErrFieldNotFound) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return v, v != nil, nil
+}
+
+// setGeneralObject replaces the field, object or array of the path with general object(see Model.ToGeneralObject)
+func setGeneralObject(m pluginapi.Model, path []string, obj interface{}) error {
+	if err := m.DeleteField(path); err != nil {
+		return err
+	}
+	return basicapi.PutGeneralObject(m, path, obj)
+}
@@ -0,0 +1,50 @@
+package fn
+
+import (
+	"errors"
+
+	"github.com/FimGroup/fim/fimapi/modelpatch"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+)
+
+// FnMergePatch applies JSON Merge Patch(RFC 7396) document of the string field to the object field
+// Null members remove fields, fields not defined are dropped the same as @json_parse
+// e.g. ["http/merge_patch", "user"]
+func FnMergePatch(resolver pluginapi.PathTypeResolver, params []interface{}) (pluginapi.Fn, error) {
+	const name = "@merge_patch"
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	src, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	targetDefinition, target, err := definitionPathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	if dt, _, err := resolver.TypeOfDefinitionPath(targetDefinition); err != nil {
+		return nil, errors.New(name + " " + err.Error())
+	} else if dt != pluginapi.DataTypeObject {
+		return nil, errors.New(name + " target should be object defined in mappings or local variables:" + targetDefinition)
+	}
+	return func(m pluginapi.Model) error {
+		s, ok, err := getString(name, src, m)
+		if err != nil || !ok {
+			return err
+		}
+		current, _, err := getGeneralObject(m, target)
+		if err != nil {
+			return err
+		}
+		merged, err := modelpatch.MergePatchGeneralObject(current, []byte(s))
+		if err != nil {
+			return errors.New(name + " invalid merge patch:" + err.Error())
+		}
+		r, err := checkGeneralObject(name, resolver, targetDefinition, merged)
+		if err != nil {
+			return err
+		}
+		return setGeneralObject(m, target, r)
+	}, nil
+}
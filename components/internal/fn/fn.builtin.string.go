@@ -0,0 +1,370 @@
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+)
+
+// String functions
+// Source parameters are paths of fields and the last parameter is the path of the result
+// Functions with single source do nothing if the source field does not exist
+
+// stringUnaryFn generates function converting the string of source field to target field
+// params: [source, target]
+func stringUnaryFn(name string, params []interface{}, convert func(s string) (interface{}, error)) (pluginapi.Fn, error) {
+	if err := requireParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	src, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		s, ok, err := getString(name, src, m)
+		if err != nil || !ok {
+			return err
+		}
+		v, err := convert(s)
+		if err != nil {
+			return err
+		}
+		return target.Set(m, v)
+	}, nil
+}
+
+// stringOfPrimitive formats primitive value as string, nil is empty string
+func stringOfPrimitive(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// FnStringConcat concatenates values of fields, missing fields are empty strings
+// e.g. ["user/first_name", "user/last_name", "user/full_name"]
+func FnStringConcat(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@string_concat"
+	if err := requireMinParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	var srcList []*pluginapi.FieldAccessor
+	for i := 0; i < len(params)-1; i++ {
+		src, err := pathParam(name, params, i)
+		if err != nil {
+			return nil, err
+		}
+		srcList = append(srcList, src)
+	}
+	target, err := pathParam(name, params, len(params)-1)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		var sb strings.Builder
+		for _, src := range srcList {
+			sb.WriteString(stringOfPrimitive(src.Get(m)))
+		}
+		return target.Set(m, sb.String())
+	}, nil
+}
+
+// FnStringFormat formats values of fields by printf-style format, missing fields are nil
+// e.g. ["%s-%d", "user/name", "user/user_id", "cache/key"]
+func FnStringFormat(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@string_format"
+	if err := requireMinParams(name, params, 2); err != nil {
+		return nil, err
+	}
+	format, err := stringParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	var srcList []*pluginapi.FieldAccessor
+	for i := 1; i < len(params)-1; i++ {
+		src, err := pathParam(name, params, i)
+		if err != nil {
+			return nil, err
+		}
+		srcList = append(srcList, src)
+	}
+	target, err := pathParam(name, params, len(params)-1)
+	if err != nil {
+		return nil, err
+	}
+	// verify format against the number of arguments
+	if s := fmt.Sprintf(format, make([]interface{}, len(srcList))...); strings.Contains(s, "%!(EXTRA") || strings.Contains(s, "(MISSING)") {
+		return nil, errors.New(fmt.Sprintf("%s format does not match %d arguments:%s", name, len(srcList), format))
+	}
+	return func(m pluginapi.Model) error {
+		args := make([]interface{}, len(srcList))
+		for i, src := range srcList {
+			args[i] = src.Get(m)
+		}
+		return target.Set(m, fmt.Sprintf(format, args...))
+	}, nil
+}
+
+// FnStringSubstring extracts characters in range [start, end), end < 0 means the end of the string
+// Range is truncated by length of the string
+// e.g. ["user/name", 0, 3, "user/short_name"]
+func FnStringSubstring(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@string_substring"
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	start, err := intParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	end, err := intParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || (end >= 0 && end < start) {
+		return nil, errors.New(fmt.Sprintf("%s invalid range [%d, %d)", name, start, end))
+	}
+	return stringUnaryFn(name, []interface{}{params[0], params[3]}, func(s string) (interface{}, error) {
+		runes := []rune(s)
+		from, to := int(start), int(end)
+		if to < 0 || to > len(runes) {
+			to = len(runes)
+		}
+		if from > to {
+			from = to
+		}
+		return string(runes[from:to]), nil
+	})
+}
+
+// FnStringUpper e.g. ["user/name", "user/name"]
+func FnStringUpper(params []interface{}) (pluginapi.Fn, error) {
+	return stringUnaryFn("@string_upper", params, func(s string) (interface{}, error) {
+		return strings.ToUpper(s), nil
+	})
+}
+
+// FnStringLower e.g. ["user/email", "user/email"]
+func FnStringLower(params []interface{}) (pluginapi.Fn, error) {
+	return stringUnaryFn("@string_lower", params, func(s string) (interface{}, error) {
+		return strings.ToLower(s), nil
+	})
+}
+
+// FnStringTrim removes leading and trailing white spaces, e.g. ["user/name", "user/name"]
+func FnStringTrim(params []interface{}) (pluginapi.Fn, error) {
+	return stringUnaryFn("@string_trim", params, func(s string) (interface{}, error) {
+		return strings.TrimSpace(s), nil
+	})
+}
+
+// FnStringLength returns number of characters as int, e.g. ["user/name", "user/name_length"]
+func FnStringLength(params []interface{}) (pluginapi.Fn, error) {
+	return stringUnaryFn("@string_length", params, func(s string) (interface{}, error) {
+		return int64(utf8.RuneCountInString(s)), nil
+	})
+}
+
+func stringPadFn(name string, params []interface{}, left bool) (pluginapi.Fn, error) {
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	width, err := intParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	if width < 0 {
+		return nil, errors.New(name + " width should not be negative")
+	}
+	pad, err := stringParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	if utf8.RuneCountInString(pad) != 1 {
+		return nil, errors.New(name + " padding should be single character:" + pad)
+	}
+	return stringUnaryFn(name, []interface{}{params[0], params[3]}, func(s string) (interface{}, error) {
+		n := int(width) - utf8.RuneCountInString(s)
+		if n <= 0 {
+			return s, nil
+		}
+		if left {
+			return strings.Repeat(pad, n) + s, nil
+		}
+		return s + strings.Repeat(pad, n), nil
+	})
+}
+
+// FnStringPadLeft pads the string to the width, e.g. ["order/no", 8, "0", "order/no"]
+func FnStringPadLeft(params []interface{}) (pluginapi.Fn, error) {
+	return stringPadFn("@string_pad_left", params, true)
+}
+
+// FnStringPadRight pads the string to the width, e.g. ["order/no", 8, " ", "order/no"]
+func FnStringPadRight(params []interface{}) (pluginapi.Fn, error) {
+	return stringPadFn("@string_pad_right", params, false)
+}
+
+// FnStringReplace replaces all occurrences, e.g. ["user/name", " ", "-", "user/slug"]
+func FnStringReplace(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@string_replace"
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	old, err := stringParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	if old == "" {
+		return nil, errors.New(name + " old string should not be empty")
+	}
+	replacement, err := stringParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return stringUnaryFn(name, []interface{}{params[0], params[3]}, func(s string) (interface{}, error) {
+		return strings.ReplaceAll(s, old, replacement), nil
+	})
+}
+
+func regexpParam(name string, params []interface{}, idx int) (*regexp.Regexp, error) {
+	pattern, err := stringParam(name, params, idx)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.New(name + " invalid regular expression:" + err.Error())
+	}
+	return re, nil
+}
+
+// FnStringRegexReplace replaces all matches, $1 refers to the submatch
+// e.g. ["user/name", "[^a-z0-9]+", "-", "user/slug"]
+func FnStringRegexReplace(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@string_regex_replace"
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	re, err := regexpParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := stringParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return stringUnaryFn(name, []interface{}{params[0], params[3]}, func(s string) (interface{}, error) {
+		return re.ReplaceAllString(s, replacement), nil
+	})
+}
+
+// FnStringRegexExtract extracts the first submatch, or the whole match if no group is defined
+// Target is not changed if nothing matches
+// e.g. ["user/email", "@(.+)$", "user/email_domain"]
+func FnStringRegexExtract(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@string_regex_extract"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	re, err := regexpParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	src, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	group := 0
+	if re.NumSubexp() > 0 {
+		group = 1
+	}
+	return func(m pluginapi.Model) error {
+		s, ok, err := getString(name, src, m)
+		if err != nil || !ok {
+			return err
+		}
+		matches := re.FindStringSubmatch(s)
+		if matches == nil {
+			return nil
+		}
+		return target.Set(m, matches[group])
+	}, nil
+}
+
+// FnStringSplit splits the string into the primitive array, e.g. ["user/tags", ",", "user/tag_list[]"]
+func FnStringSplit(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@string_split"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	src, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := stringParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	if sep == "" {
+		return nil, errors.New(name + " separator should not be empty")
+	}
+	targetPaths, err := arrayPathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		s, ok, err := getString(name, src, m)
+		if err != nil || !ok {
+			return err
+		}
+		var values []interface{}
+		for _, part := range strings.Split(s, sep) {
+			values = append(values, part)
+		}
+		return setArray(m, targetPaths, values)
+	}, nil
+}
+
+// FnStringJoin joins elements of the primitive array, e.g. ["user/tag_list[]", ",", "user/tags"]
+func FnStringJoin(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@string_join"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	srcPaths, err := arrayPathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := stringParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		values, err := getArray(m, srcPaths)
+		if err != nil {
+			return err
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = stringOfPrimitive(v)
+		}
+		return target.Set(m, strings.Join(parts, sep))
+	}, nil
+}
@@ -0,0 +1,392 @@
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+)
+
+// Date and time functions
+// Timestamps in the Model are strings or ints, the format parameter is one of:
+// * unix - int of epoch seconds
+// * unix_milli - int of epoch milliseconds
+// * rfc3339 - string of RFC 3339
+// * Go layout, e.g. 2006-01-02 15:04:05
+// The zone parameter is IANA time zone name, e.g. UTC, Asia/Shanghai, Local
+
+var clock = time.Now
+
+// SetClock replaces the clock of time functions, nil restores the system clock
+// Used by tests for deterministic results and should be set before flows are running
+func SetClock(now func() time.Time) {
+	if now == nil {
+		now = time.Now
+	}
+	clock = now
+}
+
+const (
+	timeFormatUnix      = "unix"
+	timeFormatUnixMilli = "unix_milli"
+	timeFormatRfc3339   = "rfc3339"
+)
+
+type timeFormat struct {
+	format string
+	loc    *time.Location
+}
+
+func timeFormatParam(name string, params []interface{}, formatIdx, zoneIdx int) (timeFormat, error) {
+	format, err := stringParam(name, params, formatIdx)
+	if err != nil {
+		return timeFormat{}, err
+	}
+	switch format {
+	case timeFormatUnix, timeFormatUnixMilli, timeFormatRfc3339:
+	default:
+		// layout without any element is formatted as is
+		if time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(format) == format {
+			return timeFormat{}, errors.New(name + " invalid time format:" + format)
+		}
+	}
+	loc := time.UTC
+	if zoneIdx >= 0 {
+		zone, err := stringParam(name, params, zoneIdx)
+		if err != nil {
+			return timeFormat{}, err
+		}
+		loc, err = time.LoadLocation(zone)
+		if err != nil {
+			return timeFormat{}, errors.New(name + " invalid time zone:" + zone)
+		}
+	}
+	return timeFormat{format: format, loc: loc}, nil
+}
+
+func (f timeFormat) parse(name string, accessor *pluginapi.FieldAccessor, m pluginapi.Model) (time.Time, bool, error) {
+	val := accessor.Get(m)
+	if val == nil {
+		return time.Time{}, false, nil
+	}
+	switch f.format {
+	case timeFormatUnix, timeFormatUnixMilli:
+		i, ok := val.(int64)
+		if !ok {
+			return time.Time{}, false, typeMismatchError(name, accessor.Path(), val, "int")
+		}
+		if f.format == timeFormatUnix {
+			return time.Unix(i, 0).In(f.loc), true, nil
+		}
+		return time.UnixMilli(i).In(f.loc), true, nil
+	}
+	s, ok := val.(string)
+	if !ok {
+		return time.Time{}, false, typeMismatchError(name, accessor.Path(), val, "string")
+	}
+	var t time.Time
+	var err error
+	if f.format == timeFormatRfc3339 {
+		t, err = time.Parse(time.RFC3339Nano, s)
+	} else {
+		t, err = time.ParseInLocation(f.format, s, f.loc)
+	}
+	if err != nil {
+		return time.Time{}, false, errors.New(fmt.Sprintf("%s parse time of path=[%s] failed:%s", name, strings.Join(accessor.Path(), "/"), err))
+	}
+	return t, true, nil
+}
+
+func (f timeFormat) toValue(t time.Time) interface{} {
+	switch f.format {
+	case timeFormatUnix:
+		return t.Unix()
+	case timeFormatUnixMilli:
+		return t.UnixMilli()
+	case timeFormatRfc3339:
+		return t.In(f.loc).Format(time.RFC3339)
+	default:
+		return t.In(f.loc).Format(f.format)
+	}
+}
+
+// FnTimeNow sets current time, e.g. ["rfc3339", "UTC", "order/created_at"]
+func FnTimeNow(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@time_now"
+	if err := requireParams(name, params, 3); err != nil {
+		return nil, err
+	}
+	format, err := timeFormatParam(name, params, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 2)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		return target.Set(m, format.toValue(clock()))
+	}, nil
+}
+
+// FnTimeConvert parses the time and formats it in another format
+// e.g. ["order/created_at", "unix_milli", "2006-01-02 15:04:05", "Asia/Shanghai", "order/created_time"]
+func FnTimeConvert(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@time_convert"
+	if err := requireParams(name, params, 5); err != nil {
+		return nil, err
+	}
+	src, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	srcFormat, err := timeFormatParam(name, params, 1, 3)
+	if err != nil {
+		return nil, err
+	}
+	dstFormat, err := timeFormatParam(name, params, 2, 3)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 4)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		t, ok, err := srcFormat.parse(name, src, m)
+		if err != nil || !ok {
+			return err
+		}
+		return target.Set(m, dstFormat.toValue(t))
+	}, nil
+}
+
+// timeOffset is parsed from Go duration or calendar duration: d(days), w(weeks), mo(months), y(years)
+type timeOffset struct {
+	duration            time.Duration
+	years, months, days int
+}
+
+func parseTimeOffset(s string) (timeOffset, error) {
+	for _, unit := range []string{"mo", "y", "w", "d"} {
+		if !strings.HasSuffix(s, unit) {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(s, unit))
+		if err != nil {
+			break
+		}
+		switch unit {
+		case "mo":
+			return timeOffset{months: n}, nil
+		case "y":
+			return timeOffset{years: n}, nil
+		case "w":
+			return timeOffset{days: n * 7}, nil
+		default:
+			return timeOffset{days: n}, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return timeOffset{}, err
+	}
+	return timeOffset{duration: d}, nil
+}
+
+// FnTimeAdd adds the duration to the time, negative duration subtracts
+// Duration is Go duration(e.g. 90m, -1h30m) or calendar duration in d, w, mo, y(e.g. 7d, -1mo)
+// e.g. ["token/issued_at", "unix", "Asia/Shanghai", "30d", "token/expire_at"]
+func FnTimeAdd(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@time_add"
+	if err := requireParams(name, params, 5); err != nil {
+		return nil, err
+	}
+	src, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	format, err := timeFormatParam(name, params, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	durationString, err := stringParam(name, params, 3)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := parseTimeOffset(durationString)
+	if err != nil {
+		return nil, errors.New(name + " invalid duration:" + durationString)
+	}
+	target, err := pathParam(name, params, 4)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		t, ok, err := format.parse(name, src, m)
+		if err != nil || !ok {
+			return err
+		}
+		// calendar duration is applied in the time zone
+		t = t.In(format.loc).AddDate(offset.years, offset.months, offset.days).Add(offset.duration)
+		return target.Set(m, format.toValue(t))
+	}, nil
+}
+
+var timeDiffUnits = map[string]time.Duration{
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+}
+
+// FnTimeDiff sets (end - start) as int in the unit: ms, s, m, h, d, truncated toward zero
+// e.g. ["order/created_at", "order/paid_at", "rfc3339", "s", "order/pay_seconds"]
+func FnTimeDiff(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@time_diff"
+	if err := requireParams(name, params, 5); err != nil {
+		return nil, err
+	}
+	start, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	end, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	format, err := timeFormatParam(name, params, 2, -1)
+	if err != nil {
+		return nil, err
+	}
+	unitString, err := stringParam(name, params, 3)
+	if err != nil {
+		return nil, err
+	}
+	unit, ok := timeDiffUnits[unitString]
+	if !ok {
+		return nil, errors.New(name + " unknown unit:" + unitString)
+	}
+	target, err := pathParam(name, params, 4)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		st, ok, err := format.parse(name, start, m)
+		if err != nil || !ok {
+			return err
+		}
+		et, ok, err := format.parse(name, end, m)
+		if err != nil || !ok {
+			return err
+		}
+		return target.Set(m, int64(et.Sub(st)/unit))
+	}, nil
+}
+
+// FnTimeTruncate truncates the time to the start of day, week(Monday) or month in the time zone
+// e.g. ["report/time", "rfc3339", "Asia/Shanghai", "month", "report/month_start"]
+func FnTimeTruncate(params []interface{}) (pluginapi.Fn, error) {
+	const name = "@time_truncate"
+	if err := requireParams(name, params, 5); err != nil {
+		return nil, err
+	}
+	src, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	format, err := timeFormatParam(name, params, 1, 2)
+	if err != nil {
+		return nil, err
+	}
+	unit, err := stringParam(name, params, 3)
+	if err != nil {
+		return nil, err
+	}
+	switch unit {
+	case "day", "week", "month":
+	default:
+		return nil, errors.New(name + " unknown unit:" + unit)
+	}
+	target, err := pathParam(name, params, 4)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		t, ok, err := format.parse(name, src, m)
+		if err != nil || !ok {
+			return err
+		}
+		t = t.In(format.loc)
+		year, month, day := t.Date()
+		switch unit {
+		case "day":
+			t = time.Date(year, month, day, 0, 0, 0, 0, format.loc)
+		case "week":
+			// weeks start on Monday
+			t = time.Date(year, month, day-(int(t.Weekday())+6)%7, 0, 0, 0, 0, format.loc)
+		case "month":
+			t = time.Date(year, month, 1, 0, 0, 0, 0, format.loc)
+		}
+		return target.Set(m, format.toValue(t))
+	}, nil
+}
+
+func timeCompareFn(name string, params []interface{}, result func(a, b time.Time) interface{}) (pluginapi.Fn, error) {
+	if err := requireParams(name, params, 4); err != nil {
+		return nil, err
+	}
+	a, err := pathParam(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	b, err := pathParam(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	format, err := timeFormatParam(name, params, 2, -1)
+	if err != nil {
+		return nil, err
+	}
+	target, err := pathParam(name, params, 3)
+	if err != nil {
+		return nil, err
+	}
+	return func(m pluginapi.Model) error {
+		at, ok, err := format.parse(name, a, m)
+		if err != nil || !ok {
+			return err
+		}
+		bt, ok, err := format.parse(name, b, m)
+		if err != nil || !ok {
+			return err
+		}
+		return target.Set(m, result(at, bt))
+	}, nil
+}
+
+// FnTimeCompare sets int -1, 0 or 1 when the first time is before, equal to or after the second one
+// e.g. ["coupon/start_at", "coupon/end_at", "rfc3339", "coupon/compare"]
+func FnTimeCompare(params []interface{}) (pluginapi.Fn, error) {
+	return timeCompareFn("@time_compare", params, func(a, b time.Time) interface{} {
+		switch {
+		case a.Before(b):
+			return int64(-1)
+		case a.After(b):
+			return int64(1)
+		default:
+			return int64(0)
+		}
+	})
+}
+
+// FnTimeBefore sets true if the first time is before the second one, e.g. ["token/expire_at", "now", "unix", "token/expired"]
+func FnTimeBefore(params []interface{}) (pluginapi.Fn, error) {
+	return timeCompareFn("@time_before", params, func(a, b time.Time) interface{} {
+		return a.Before(b)
+	})
+}
@@ -0,0 +1,533 @@
+package fn
+
+import (
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+)
+
+// Functions registered with parameter schemas
+// Parameters are validated by the container before FnGen is called so that the FnGen could assert parameter types
+
+type schemaFn struct {
+	schema pluginapi.FnSchema
+	gen    pluginapi.FnGen
+}
+
+type typedSchemaFn struct {
+	schema pluginapi.FnSchema
+	gen    pluginapi.TypedFnGen
+}
+
+func param(name string, kind pluginapi.ParamKind, description string) pluginapi.ParamSchema {
+	return pluginapi.ParamSchema{Name: name, Kind: kind, Description: description}
+}
+
+func srcParam(description string) pluginapi.ParamSchema {
+	return param("src", pluginapi.ParamKindPath, description)
+}
+
+func targetParam(description string) pluginapi.ParamSchema {
+	return param("target", pluginapi.ParamKindPath, description)
+}
+
+func breakSchema(description string, params ...pluginapi.ParamSchema) pluginapi.FnSchema {
+	return pluginapi.FnSchema{
+		Description: description,
+		Params: append(params,
+			param("error_key", pluginapi.ParamKindString, "key of FlowError"),
+			param("error_message", pluginapi.ParamKindString, "message of FlowError"),
+		),
+	}
+}
+
+// unarySchema declares [src, target] parameters
+func unarySchema(description, src, target string) pluginapi.FnSchema {
+	return pluginapi.FnSchema{
+		Description: description,
+		Params:      []pluginapi.ParamSchema{srcParam(src), targetParam(target)},
+	}
+}
+
+func schemaFns(container pluginapi.Container) map[string]schemaFn {
+	cm := container.ConfigureManager()
+	field := param("field", pluginapi.ParamKindPath, "field to check")
+	srcArray := srcParam("array, e.g. order/items[]")
+	targetArray := targetParam("array, e.g. order/items[]")
+	elementField := param("field", pluginapi.ParamKindString, "field of object elements, empty string for primitive elements")
+	operand := func(name string) pluginapi.ParamSchema {
+		return param(name, pluginapi.ParamKindAny, "number or path of int/float field")
+	}
+	format := param("format", pluginapi.ParamKindString, "unix, unix_milli, rfc3339 or Go layout")
+	zone := param("zone", pluginapi.ParamKindString, "IANA time zone name, e.g. UTC")
+	encoding := param("encoding", pluginapi.ParamKindString, "hex, base64 or base64url")
+	algorithm := param("algorithm", pluginapi.ParamKindString, "sha256, sha384, sha512, sha3_256, sha3_384 or sha3_512")
+	secret := param("key", pluginapi.ParamKindString, "configure-static or configure-dynamic placeholder")
+	jwtAlgorithm := param("algorithm", pluginapi.ParamKindString, "HS256/384/512, RS256/384/512 or ES256/384/512")
+	jwtKey := param("key", pluginapi.ParamKindString, "configure placeholder or resource-file placeholder")
+
+	m := map[string]schemaFn{
+		"@assign": {pluginapi.FnSchema{
+			Description: "Sets the literal value to the field",
+			Params: []pluginapi.ParamSchema{
+				targetParam("field to set"),
+				param("value", pluginapi.ParamKindAny, "literal value"),
+			},
+		}, FnAssign},
+		"@uuid": {pluginapi.FnSchema{
+			Description: "Generates random UUID version 4",
+			Params:      []pluginapi.ParamSchema{targetParam("string result")},
+		}, FnUUID},
+		"@set_current_unix_timestamp": {pluginapi.FnSchema{
+			Description: "Sets current unix milliseconds",
+			Params:      []pluginapi.ParamSchema{targetParam("int result")},
+		}, FnSetCurrentUnixTimestamp},
+		"@collect": {pluginapi.FnSchema{
+			Description: "Collects primitive values matching the query into the array, empty array if nothing matches",
+			Params: []pluginapi.ParamSchema{
+				param("query", pluginapi.ParamKindString, "query, e.g. items[*]/price"),
+				targetParam("primitive array, e.g. result/prices[]"),
+			},
+		}, FnCollect},
+
+		"@array_length": {pluginapi.FnSchema{
+			Description: "Sets number of elements, 0 if the array does not exist",
+			Params:      []pluginapi.ParamSchema{srcArray, targetParam("int result")},
+		}, FnArrayLength},
+		"@array_append": {pluginapi.FnSchema{
+			Description: "Appends the primitive value or object to the array, nothing is appended if the value does not exist",
+			Params:      []pluginapi.ParamSchema{srcArray, param("value", pluginapi.ParamKindPath, "field of the element"), targetArray},
+		}, FnArrayAppend},
+		"@array_prepend": {pluginapi.FnSchema{
+			Description: "Inserts the primitive value or object before the first element",
+			Params:      []pluginapi.ParamSchema{srcArray, param("value", pluginapi.ParamKindPath, "field of the element"), targetArray},
+		}, FnArrayPrepend},
+		"@array_remove_at": {pluginapi.FnSchema{
+			Description: "Removes the element at the index, negative index counts from the end",
+			Params:      []pluginapi.ParamSchema{srcArray, param("index", pluginapi.ParamKindAny, "int or path of int field"), targetArray},
+		}, FnArrayRemoveAt},
+		"@array_contains": {pluginapi.FnSchema{
+			Description: "Sets true if any element equals to the value",
+			Params:      []pluginapi.ParamSchema{srcArray, param("value", pluginapi.ParamKindPath, "field to search"), targetParam("bool result")},
+		}, FnArrayContains},
+		"@array_index_of": {pluginapi.FnSchema{
+			Description: "Sets index of the first element equal to the value, -1 if not found",
+			Params:      []pluginapi.ParamSchema{srcArray, param("value", pluginapi.ParamKindPath, "field to search"), targetParam("int result")},
+		}, FnArrayIndexOf},
+		"@array_distinct": {pluginapi.FnSchema{
+			Description: "Removes duplicated elements and keeps the first ones",
+			Params:      []pluginapi.ParamSchema{srcArray, elementField, targetArray},
+		}, FnArrayDistinct},
+		"@array_sort": {pluginapi.FnSchema{
+			Description: "Sorts elements by the field, the sort is stable and missing values are the last",
+			Params:      []pluginapi.ParamSchema{srcArray, elementField, param("direction", pluginapi.ParamKindString, "asc or desc"), targetArray},
+		}, FnArraySort},
+		"@array_reverse": {pluginapi.FnSchema{
+			Description: "Reverses elements",
+			Params:      []pluginapi.ParamSchema{srcArray, targetArray},
+		}, FnArrayReverse},
+		"@array_slice": {pluginapi.FnSchema{
+			Description: "Keeps elements in [start, end)",
+			Params: []pluginapi.ParamSchema{
+				srcArray,
+				param("start", pluginapi.ParamKindInt, "start index"),
+				param("end", pluginapi.ParamKindInt, "end index, -1 means the end"),
+				targetArray,
+			},
+		}, FnArraySlice},
+		"@array_flatten": {pluginapi.FnSchema{
+			Description: "Concatenates the array field of elements",
+			Params:      []pluginapi.ParamSchema{srcArray, param("field", pluginapi.ParamKindPath, "array of the element, e.g. items[]"), targetArray},
+		}, FnArrayFlatten},
+		"@array_sum": {pluginapi.FnSchema{
+			Description: "Sums the numeric field, result is int if all values are int and 0 for empty array",
+			Params:      []pluginapi.ParamSchema{srcArray, elementField, targetParam("int or float result")},
+		}, FnArraySum},
+		"@array_avg": {pluginapi.FnSchema{
+			Description: "Sets average of the numeric field, nothing is set for empty array",
+			Params:      []pluginapi.ParamSchema{srcArray, elementField, targetParam("float result")},
+		}, FnArrayAvg},
+		"@array_min": {pluginapi.FnSchema{
+			Description: "Sets the minimum value of the field, nothing is set for empty array",
+			Params:      []pluginapi.ParamSchema{srcArray, elementField, targetParam("result")},
+		}, FnArrayMin},
+		"@array_max": {pluginapi.FnSchema{
+			Description: "Sets the maximum value of the field, nothing is set for empty array",
+			Params:      []pluginapi.ParamSchema{srcArray, elementField, targetParam("result")},
+		}, FnArrayMax},
+		"@array_group_by": {pluginapi.FnSchema{
+			Description: "Groups elements of object array into object arrays named by values of the field",
+			Params:      []pluginapi.ParamSchema{srcArray, param("field", pluginapi.ParamKindString, "field of object elements"), targetParam("object")},
+		}, FnArrayGroupBy},
+
+		"@string_concat": {pluginapi.FnSchema{
+			Description: "Concatenates values of fields, missing fields are empty strings",
+			Params: []pluginapi.ParamSchema{
+				{Name: "src", Kind: pluginapi.ParamKindPath, Variadic: true, Description: "fields to concatenate"},
+				targetParam("string result"),
+			},
+		}, FnStringConcat},
+		"@string_format": {pluginapi.FnSchema{
+			Description: "Formats values of fields by printf-style format, missing fields are nil",
+			Params: []pluginapi.ParamSchema{
+				param("format", pluginapi.ParamKindString, "printf-style format"),
+				{Name: "src", Kind: pluginapi.ParamKindPath, Optional: true, Variadic: true, Description: "fields of the format arguments"},
+				targetParam("string result"),
+			},
+		}, FnStringFormat},
+		"@string_substring": {pluginapi.FnSchema{
+			Description: "Extracts characters in range [start, end), range is truncated by length of the string",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field"),
+				param("start", pluginapi.ParamKindInt, "start index"),
+				param("end", pluginapi.ParamKindInt, "end index, negative means the end"),
+				targetParam("string result"),
+			},
+		}, FnStringSubstring},
+		"@string_upper":  {unarySchema("Converts the string to upper case", "string field", "string result"), FnStringUpper},
+		"@string_lower":  {unarySchema("Converts the string to lower case", "string field", "string result"), FnStringLower},
+		"@string_trim":   {unarySchema("Removes leading and trailing white spaces", "string field", "string result"), FnStringTrim},
+		"@string_length": {unarySchema("Sets number of characters", "string field", "int result"), FnStringLength},
+		"@string_pad_left": {pluginapi.FnSchema{
+			Description: "Pads the string on the left to the width",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field"),
+				param("width", pluginapi.ParamKindInt, "number of characters"),
+				param("pad", pluginapi.ParamKindString, "single character"),
+				targetParam("string result"),
+			},
+		}, FnStringPadLeft},
+		"@string_pad_right": {pluginapi.FnSchema{
+			Description: "Pads the string on the right to the width",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field"),
+				param("width", pluginapi.ParamKindInt, "number of characters"),
+				param("pad", pluginapi.ParamKindString, "single character"),
+				targetParam("string result"),
+			},
+		}, FnStringPadRight},
+		"@string_replace": {pluginapi.FnSchema{
+			Description: "Replaces all occurrences",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field"),
+				param("old", pluginapi.ParamKindString, "string to replace"),
+				param("new", pluginapi.ParamKindString, "replacement"),
+				targetParam("string result"),
+			},
+		}, FnStringReplace},
+		"@string_regex_replace": {pluginapi.FnSchema{
+			Description: "Replaces all matches of the regular expression, $1 refers to the submatch",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field"),
+				param("pattern", pluginapi.ParamKindString, "regular expression"),
+				param("replacement", pluginapi.ParamKindString, "replacement"),
+				targetParam("string result"),
+			},
+		}, FnStringRegexReplace},
+		"@string_regex_extract": {pluginapi.FnSchema{
+			Description: "Extracts the first submatch, or the whole match if no group is defined",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field"),
+				param("pattern", pluginapi.ParamKindString, "regular expression"),
+				targetParam("string result, not changed if nothing matches"),
+			},
+		}, FnStringRegexExtract},
+		"@string_split": {pluginapi.FnSchema{
+			Description: "Splits the string into the primitive array",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field"),
+				param("separator", pluginapi.ParamKindString, "separator"),
+				targetParam("primitive array, e.g. user/tags[]"),
+			},
+		}, FnStringSplit},
+		"@string_join": {pluginapi.FnSchema{
+			Description: "Joins elements of the primitive array",
+			Params: []pluginapi.ParamSchema{
+				srcParam("primitive array, e.g. user/tags[]"),
+				param("separator", pluginapi.ParamKindString, "separator"),
+				targetParam("string result"),
+			},
+		}, FnStringJoin},
+
+		"@math_add": {pluginapi.FnSchema{
+			Description: "Sets a + b",
+			Params:      []pluginapi.ParamSchema{operand("a"), operand("b"), targetParam("int or float result")},
+		}, FnMathAdd},
+		"@math_subtract": {pluginapi.FnSchema{
+			Description: "Sets a - b",
+			Params:      []pluginapi.ParamSchema{operand("a"), operand("b"), targetParam("int or float result")},
+		}, FnMathSubtract},
+		"@math_multiply": {pluginapi.FnSchema{
+			Description: "Sets a * b",
+			Params:      []pluginapi.ParamSchema{operand("a"), operand("b"), targetParam("int or float result")},
+		}, FnMathMultiply},
+		"@math_divide": {pluginapi.FnSchema{
+			Description: "Sets a / b, int division truncates toward zero",
+			Params:      []pluginapi.ParamSchema{operand("a"), operand("b"), targetParam("int or float result")},
+		}, FnMathDivide},
+		"@math_modulo": {pluginapi.FnSchema{
+			Description: "Sets remainder of a / b with the sign of a",
+			Params:      []pluginapi.ParamSchema{operand("a"), operand("b"), targetParam("int or float result")},
+		}, FnMathModulo},
+		"@math_min": {pluginapi.FnSchema{
+			Description: "Sets the smaller one of a and b",
+			Params:      []pluginapi.ParamSchema{operand("a"), operand("b"), targetParam("int or float result")},
+		}, FnMathMin},
+		"@math_max": {pluginapi.FnSchema{
+			Description: "Sets the larger one of a and b",
+			Params:      []pluginapi.ParamSchema{operand("a"), operand("b"), targetParam("int or float result")},
+		}, FnMathMax},
+		"@math_abs": {pluginapi.FnSchema{
+			Description: "Sets the absolute value",
+			Params:      []pluginapi.ParamSchema{operand("src"), targetParam("int or float result")},
+		}, FnMathAbs},
+		"@math_round": {pluginapi.FnSchema{
+			Description: "Rounds the number to the decimal places, result is int if decimal places is 0",
+			Params: []pluginapi.ParamSchema{
+				operand("src"),
+				param("mode", pluginapi.ParamKindString, "half_up, half_even, floor, ceil or truncate"),
+				param("places", pluginapi.ParamKindInt, "decimal places"),
+				targetParam("int or float result"),
+			},
+		}, FnMathRound},
+		"@math_clamp": {pluginapi.FnSchema{
+			Description: "Limits the number in range [min, max]",
+			Params:      []pluginapi.ParamSchema{operand("src"), operand("min"), operand("max"), targetParam("int or float result")},
+		}, FnMathClamp},
+
+		"@time_now": {pluginapi.FnSchema{
+			Description: "Sets current time",
+			Params:      []pluginapi.ParamSchema{format, zone, targetParam("time result")},
+		}, FnTimeNow},
+		"@time_convert": {pluginapi.FnSchema{
+			Description: "Parses the time and formats it in another format",
+			Params: []pluginapi.ParamSchema{
+				srcParam("time field"),
+				param("src_format", pluginapi.ParamKindString, "format of the source"),
+				param("target_format", pluginapi.ParamKindString, "format of the result"),
+				zone,
+				targetParam("time result"),
+			},
+		}, FnTimeConvert},
+		"@time_add": {pluginapi.FnSchema{
+			Description: "Adds the duration to the time, negative duration subtracts",
+			Params: []pluginapi.ParamSchema{
+				srcParam("time field"),
+				format,
+				zone,
+				param("duration", pluginapi.ParamKindString, "Go duration or calendar duration in d, w, mo, y, e.g. 7d"),
+				targetParam("time result"),
+			},
+		}, FnTimeAdd},
+		"@time_diff": {pluginapi.FnSchema{
+			Description: "Sets (end - start) in the unit, truncated toward zero",
+			Params: []pluginapi.ParamSchema{
+				param("start", pluginapi.ParamKindPath, "time field"),
+				param("end", pluginapi.ParamKindPath, "time field"),
+				format,
+				param("unit", pluginapi.ParamKindString, "ms, s, m, h or d"),
+				targetParam("int result"),
+			},
+		}, FnTimeDiff},
+		"@time_truncate": {pluginapi.FnSchema{
+			Description: "Truncates the time to the start of day, week(Monday) or month in the time zone",
+			Params: []pluginapi.ParamSchema{
+				srcParam("time field"),
+				format,
+				zone,
+				param("unit", pluginapi.ParamKindString, "day, week or month"),
+				targetParam("time result"),
+			},
+		}, FnTimeTruncate},
+		"@time_compare": {pluginapi.FnSchema{
+			Description: "Sets -1, 0 or 1 when the first time is before, equal to or after the second one",
+			Params: []pluginapi.ParamSchema{
+				param("a", pluginapi.ParamKindPath, "time field"),
+				param("b", pluginapi.ParamKindPath, "time field"),
+				format,
+				targetParam("int result"),
+			},
+		}, FnTimeCompare},
+		"@time_before": {pluginapi.FnSchema{
+			Description: "Sets true if the first time is before the second one",
+			Params: []pluginapi.ParamSchema{
+				param("a", pluginapi.ParamKindPath, "time field"),
+				param("b", pluginapi.ParamKindPath, "time field"),
+				format,
+				targetParam("bool result"),
+			},
+		}, FnTimeBefore},
+
+		"@id_uuidv7": {pluginapi.FnSchema{
+			Description: "Generates UUID version 7 of unix milliseconds and random bits",
+			Params:      []pluginapi.ParamSchema{targetParam("string result")},
+		}, FnIdUuidV7},
+		"@id_ulid": {pluginapi.FnSchema{
+			Description: "Generates ULID which is increasing within the same millisecond",
+			Params:      []pluginapi.ParamSchema{targetParam("string result")},
+		}, FnIdUlid},
+		"@id_ksuid": {pluginapi.FnSchema{
+			Description: "Generates KSUID ordered by seconds",
+			Params:      []pluginapi.ParamSchema{targetParam("string result")},
+		}, FnIdKsuid},
+		"@id_snowflake": {pluginapi.FnSchema{
+			Description: "Generates snowflake ID of milliseconds, node and sequence",
+			Params: []pluginapi.ParamSchema{
+				param("node", pluginapi.ParamKindAny, "int in range [0, 1023] or configure-static placeholder"),
+				targetParam("int result"),
+			},
+		}, FnIdSnowflake(cm)},
+
+		"@crypto_bcrypt": {pluginapi.FnSchema{
+			Description: "Hashes the string field in place with bcrypt",
+			Params: []pluginapi.ParamSchema{
+				param("field", pluginapi.ParamKindPath, "string field"),
+				{Name: "cost", Kind: pluginapi.ParamKindInt, Optional: true, Description: "bcrypt cost"},
+			},
+		}, FnCryptoBcrypt},
+		"@crypto_bcrypt_verify": {pluginapi.FnSchema{
+			Description: "Verifies the input against the bcrypt hash, nothing is set if any field does not exist",
+			Params: []pluginapi.ParamSchema{
+				param("hash", pluginapi.ParamKindPath, "bcrypt hash"),
+				param("input", pluginapi.ParamKindPath, "plain text"),
+				targetParam("bool result"),
+			},
+		}, FnCryptoBcryptVerify},
+		"@crypto_hash": {pluginapi.FnSchema{
+			Description: "Sets digest of the string",
+			Params:      []pluginapi.ParamSchema{srcParam("string field"), algorithm, encoding, targetParam("string result")},
+		}, FnCryptoHash},
+		"@crypto_hmac_sign": {pluginapi.FnSchema{
+			Description: "Sets HMAC of the string",
+			Params:      []pluginapi.ParamSchema{srcParam("string field"), algorithm, secret, encoding, targetParam("string result")},
+		}, FnCryptoHmacSign(cm)},
+		"@crypto_hmac_verify": {pluginapi.FnSchema{
+			Description: "Verifies HMAC signature of the string in constant time",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field"),
+				algorithm,
+				secret,
+				encoding,
+				param("signature", pluginapi.ParamKindPath, "string field of the signature"),
+				targetParam("bool result"),
+			},
+		}, FnCryptoHmacVerify(cm)},
+		"@crypto_aes_gcm_encrypt": {pluginapi.FnSchema{
+			Description: "Encrypts the string with AES-GCM, result is base64 of nonce and ciphertext",
+			Params:      []pluginapi.ParamSchema{srcParam("string field"), param("key", pluginapi.ParamKindString, "configure placeholder of base64 key"), targetParam("string result")},
+		}, FnCryptoAesGcmEncrypt(cm)},
+		"@crypto_aes_gcm_decrypt": {pluginapi.FnSchema{
+			Description: "Decrypts the result of @crypto_aes_gcm_encrypt",
+			Params:      []pluginapi.ParamSchema{srcParam("string field"), param("key", pluginapi.ParamKindString, "configure placeholder of base64 key"), targetParam("string result")},
+		}, FnCryptoAesGcmDecrypt(cm)},
+		"@crypto_argon2id": {pluginapi.FnSchema{
+			Description: "Hashes the string field in place with argon2id, result is PHC string",
+			Params: []pluginapi.ParamSchema{
+				param("field", pluginapi.ParamKindPath, "string field"),
+				param("iterations", pluginapi.ParamKindInt, "number of iterations"),
+				param("memory", pluginapi.ParamKindInt, "memory in KiB"),
+				param("parallelism", pluginapi.ParamKindInt, "number of threads"),
+			},
+		}, FnCryptoArgon2id(cm)},
+		"@crypto_argon2id_verify": {pluginapi.FnSchema{
+			Description: "Verifies the input against the argon2id hash",
+			Params: []pluginapi.ParamSchema{
+				param("hash", pluginapi.ParamKindPath, "argon2id hash"),
+				param("input", pluginapi.ParamKindPath, "plain text"),
+				targetParam("bool result"),
+			},
+		}, FnCryptoArgon2idVerify(cm)},
+		"@crypto_constant_time_equals": {pluginapi.FnSchema{
+			Description: "Compares two strings in constant time, missing fields are not equal",
+			Params: []pluginapi.ParamSchema{
+				param("a", pluginapi.ParamKindPath, "string field"),
+				param("b", pluginapi.ParamKindPath, "string field"),
+				targetParam("bool result"),
+			},
+		}, FnCryptoConstantTimeEquals},
+		"@crypto_random_token": {pluginapi.FnSchema{
+			Description: "Sets cryptographically random token of the bytes",
+			Params:      []pluginapi.ParamSchema{param("bytes", pluginapi.ParamKindInt, "number of bytes in range [16, 1024]"), encoding, targetParam("string result")},
+		}, FnCryptoRandomToken},
+
+		"@jwt_sign": {pluginapi.FnSchema{
+			Description: "Issues token with fields of the claims object, iat and exp are set automatically",
+			Params: []pluginapi.ParamSchema{
+				param("claims", pluginapi.ParamKindPath, "optional claims object"),
+				jwtAlgorithm,
+				jwtKey,
+				param("expiry", pluginapi.ParamKindString, "Go duration, e.g. 2h"),
+				targetParam("string result"),
+			},
+		}, FnJwtSign(container)},
+		"@jwt_verify": {pluginapi.FnSchema{
+			Description: "Verifies signature, exp and nbf of the token and decodes claims into the object",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field of the token"),
+				jwtAlgorithm,
+				jwtKey,
+				param("claims", pluginapi.ParamKindPath, "object of claims, not changed if verification fails"),
+				targetParam("bool result"),
+			},
+		}, FnJwtVerify(container)},
+
+		"@base64_encode":    {unarySchema("Encodes the string in base64", "string field", "string result"), FnBase64Encode},
+		"@base64_decode":    {unarySchema("Decodes base64 into UTF-8 string", "string field", "string result"), FnBase64Decode},
+		"@base64url_encode": {unarySchema("Encodes the string in base64url without padding", "string field", "string result"), FnBase64UrlEncode},
+		"@base64url_decode": {unarySchema("Decodes base64url with or without padding into UTF-8 string", "string field", "string result"), FnBase64UrlDecode},
+		"@hex_encode":       {unarySchema("Encodes the string in hex", "string field", "string result"), FnHexEncode},
+		"@hex_decode":       {unarySchema("Decodes hex into UTF-8 string", "string field", "string result"), FnHexDecode},
+		"@url_encode":       {unarySchema("Escapes the string as query component", "string field", "string result"), FnUrlEncode},
+		"@url_decode":       {unarySchema("Unescapes query component, '+' is decoded as space", "string field", "string result"), FnUrlDecode},
+		"@json_stringify": {unarySchema("Serializes the object, array or primitive field into json string",
+			"field, e.g. order/extra or order/tags[]", "string result"), FnJsonStringify},
+
+		"@check_always_break": {breakSchema("Breaks the flow"), CheckAlwaysBreak},
+		"@check_empty_break": {breakSchema("Breaks the flow if the string field exists and is not empty",
+			field), CheckEmptyBreak},
+		"@check_not_blank_break": {breakSchema("Breaks the flow if the string field does not exist or is blank",
+			field), CheckNotBlankBreak},
+		"@check_not_exist_break": {breakSchema("Breaks the flow if the field exists",
+			field), CheckNotExistBreak},
+		"@check_exist_break": {breakSchema("Breaks the flow if the field does not exist",
+			field), CheckExistBreak},
+		"@check_errors_break": {breakSchema("Breaks the flow if any error is collected by check functions, errors are in Details of FlowError",
+			srcParam("object array of errors, e.g. result/errors[]")), CheckErrorsBreak},
+	}
+	for _, c := range validationChecks {
+		m["@check_"+c.rule+"_break"] = schemaFn{c.breakSchema(), c.breakFn()}
+		m["@check_"+c.rule] = schemaFn{c.collectSchema(), c.collectFn()}
+	}
+	return m
+}
+
+func typedSchemaFns() map[string]typedSchemaFn {
+	return map[string]typedSchemaFn{
+		"@json_parse": {pluginapi.FnSchema{
+			Description: "Parses json string into the object or array, fields not defined in FlowModel are dropped",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field"),
+				{Name: "target", Kind: pluginapi.ParamKindPath, MustExist: true, Description: "object or array, e.g. order/extra or order/items[]"},
+			},
+		}, FnJsonParse},
+		"@merge_patch": {pluginapi.FnSchema{
+			Description: "Applies JSON Merge Patch(RFC 7396) document to the object, null members remove fields",
+			Params: []pluginapi.ParamSchema{
+				srcParam("string field of the patch document"),
+				{Name: "target", Kind: pluginapi.ParamKindPath, MustExist: true, Description: "object to patch"},
+			},
+		}, FnMergePatch},
+	}
+}
+
+func registerSchemaFn(container pluginapi.Container) error {
+	for name, fn := range schemaFns(container) {
+		if err := container.RegisterBuiltinFnWithSchema(name, fn.schema, fn.gen); err != nil {
+			return err
+		}
+	}
+	for name, fn := range typedSchemaFns() {
+		if err := container.RegisterBuiltinTypedFnWithSchema(name, fn.schema, fn.gen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
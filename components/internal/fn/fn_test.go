@@ -0,0 +1,586 @@
+package fn
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimcore/modelinst"
+)
+
+func newModel(t *testing.T, fields map[string]interface{}) pluginapi.Model {
+	m := modelinst.ModelInstHelper{}.NewInst()
+	for path, v := range fields {
+		if err := m.AddOrUpdateField0([]string{path}, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func runFn(t *testing.T, m pluginapi.Model, gen pluginapi.FnGen, params ...interface{}) {
+	t.Helper()
+	f, err := gen(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f(m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertField(t *testing.T, m pluginapi.Model, path string, expected interface{}) {
+	t.Helper()
+	if v := m.GetFieldUnsafe0([]string{path}); v != expected {
+		t.Fatalf("field %s expected=[%v] actual=[%v]", path, expected, v)
+	}
+}
+
+func TestStringFn(t *testing.T) {
+	m := newModel(t, map[string]interface{}{"first": " Alice ", "last": "Smith", "id": int64(42), "tags": "a,b,c"})
+	runFn(t, m, FnStringTrim, "first", "first")
+	runFn(t, m, FnStringConcat, "first", "last", "full")
+	assertField(t, m, "full", "AliceSmith")
+	runFn(t, m, FnStringFormat, "%s-%d", "last", "id", "key")
+	assertField(t, m, "key", "Smith-42")
+	runFn(t, m, FnStringSubstring, "last", int64(1), int64(-1), "sub")
+	assertField(t, m, "sub", "mith")
+	runFn(t, m, FnStringUpper, "last", "upper")
+	assertField(t, m, "upper", "SMITH")
+	runFn(t, m, FnStringPadLeft, "last", int64(7), "*", "padded")
+	assertField(t, m, "padded", "**Smith")
+	runFn(t, m, FnStringRegexReplace, "full", "[A-Z]", "_", "slug")
+	assertField(t, m, "slug", "_lice_mith")
+	runFn(t, m, FnStringRegexExtract, "key", "-(\\d+)$", "extracted")
+	assertField(t, m, "extracted", "42")
+	runFn(t, m, FnStringSplit, "tags", ",", "tag_list[]")
+	runFn(t, m, FnStringJoin, "tag_list[]", "|", "joined")
+	assertField(t, m, "joined", "a|b|c")
+	runFn(t, m, FnStringLength, "joined", "length")
+	assertField(t, m, "length", int64(5))
+
+	if _, err := FnStringFormat([]interface{}{"%s-%d", "last", "key"}); err == nil {
+		t.Fatal("format with missing arguments should fail")
+	}
+	if _, err := FnStringRegexReplace([]interface{}{"last", "[", "_", "slug"}); err == nil {
+		t.Fatal("invalid regular expression should fail")
+	}
+	if f, err := FnStringPadLeft([]interface{}{"id", int64(5), "0", "padded"}); err != nil {
+		t.Fatal(err)
+	} else if err := f(m); !errors.Is(err, basicapi.ErrFieldTypeMismatch) {
+		t.Fatal("padding int field should fail:", err)
+	}
+}
+
+func TestMathFn(t *testing.T) {
+	m := newModel(t, map[string]interface{}{"page": int64(3), "size": int64(20), "price": 10.125, "zero": int64(0), "max": int64(math.MaxInt64)})
+	runFn(t, m, FnMathSubtract, "page", int64(1), "offset")
+	runFn(t, m, FnMathMultiply, "offset", "size", "offset")
+	assertField(t, m, "offset", int64(40))
+	runFn(t, m, FnMathAdd, "price", "page", "total")
+	assertField(t, m, "total", 13.125)
+	runFn(t, m, FnMathRound, "total", "half_even", int64(2), "rounded")
+	assertField(t, m, "rounded", 13.12)
+	runFn(t, m, FnMathRound, "total", "ceil", int64(0), "ceil")
+	assertField(t, m, "ceil", int64(14))
+	runFn(t, m, FnMathClamp, "size", int64(1), int64(10), "size")
+	assertField(t, m, "size", int64(10))
+	runFn(t, m, FnMathModulo, int64(-7), int64(3), "mod")
+	assertField(t, m, "mod", int64(-1))
+	runFn(t, m, FnMathAbs, "mod", "abs")
+	assertField(t, m, "abs", int64(1))
+
+	for _, c := range []struct {
+		gen    pluginapi.FnGen
+		params []interface{}
+		key    string
+	}{
+		{FnMathDivide, []interface{}{"page", "zero", "result"}, pluginapi.FlowErrorKeyDivisionByZero},
+		{FnMathAdd, []interface{}{"max", int64(1), "result"}, pluginapi.FlowErrorKeyNumericOverflow},
+	} {
+		f, err := c.gen(c.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var flowErr *pluginapi.FlowError
+		if err := f(m); !errors.As(err, &flowErr) || flowErr.Key != c.key {
+			t.Fatal("expected error key:", c.key, err)
+		}
+	}
+	if _, err := FnMathRound([]interface{}{"price", "unknown", int64(0), "result"}); err == nil {
+		t.Fatal("unknown rounding mode should fail")
+	}
+}
+
+func TestTimeFn(t *testing.T) {
+	SetClock(func() time.Time {
+		return time.Date(2024, 3, 14, 18, 30, 0, 0, time.UTC)
+	})
+	defer SetClock(nil)
+
+	m := newModel(t, map[string]interface{}{})
+	runFn(t, m, FnTimeNow, "unix", "UTC", "now")
+	assertField(t, m, "now", int64(1710441000))
+	runFn(t, m, FnTimeConvert, "now", "unix", "2006-01-02 15:04", "Asia/Shanghai", "local")
+	assertField(t, m, "local", "2024-03-15 02:30")
+	runFn(t, m, FnTimeAdd, "now", "unix", "UTC", "1mo", "expire")
+	runFn(t, m, FnTimeConvert, "expire", "unix", "rfc3339", "UTC", "expire_text")
+	assertField(t, m, "expire_text", "2024-04-14T18:30:00Z")
+	runFn(t, m, FnTimeDiff, "now", "expire", "unix", "d", "days")
+	assertField(t, m, "days", int64(31))
+	runFn(t, m, FnTimeTruncate, "local", "2006-01-02 15:04", "Asia/Shanghai", "week", "week_start")
+	assertField(t, m, "week_start", "2024-03-11 00:00")
+	runFn(t, m, FnTimeBefore, "expire", "now", "unix", "expired")
+	assertField(t, m, "expired", false)
+	runFn(t, m, FnTimeCompare, "now", "expire", "unix", "compare")
+	assertField(t, m, "compare", int64(-1))
+
+	if _, err := FnTimeNow([]interface{}{"unix", "Mars/Base", "now"}); err == nil {
+		t.Fatal("unknown time zone should fail")
+	}
+	if _, err := FnTimeAdd([]interface{}{"now", "unix", "UTC", "1x", "expire"}); err == nil {
+		t.Fatal("invalid duration should fail")
+	}
+}
+
+type testConfigureManager map[string]string
+
+func (c testConfigureManager) ReplaceStaticConfigure(placeholder string) string {
+	if v, ok := c[strings.TrimPrefix(placeholder, basicapi.ConfigurePrefixStatic)]; ok {
+		return v
+	}
+	return placeholder
+}
+
+func (c testConfigureManager) ReplaceDynamicConfigure(placeholder string) string {
+	return placeholder
+}
+
+func (c testConfigureManager) SupportDynamicConfigure(placeholder string) bool {
+	return false
+}
+
+func TestCryptoFn(t *testing.T) {
+	cm := testConfigureManager{
+		"secret": "key",
+		"aes":    "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=",
+	}
+	m := newModel(t, map[string]interface{}{"body": "hello", "password": "p@ss", "input": "p@ss"})
+	runFn(t, m, FnCryptoHash, "body", "sha256", "hex", "digest")
+	assertField(t, m, "digest", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824")
+	runFn(t, m, FnCryptoHmacSign(cm), "body", "sha256", "configure-static://secret", "base64url", "signature")
+	runFn(t, m, FnCryptoHmacVerify(cm), "body", "sha256", "configure-static://secret", "base64url", "signature", "verified")
+	assertField(t, m, "verified", true)
+	runFn(t, m, FnCryptoAesGcmEncrypt(cm), "body", "configure-static://aes", "encrypted")
+	runFn(t, m, FnCryptoAesGcmDecrypt(cm), "encrypted", "configure-static://aes", "decrypted")
+	assertField(t, m, "decrypted", "hello")
+	runFn(t, m, FnCryptoArgon2id(cm), "password", int64(1), int64(1024), int64(1))
+	runFn(t, m, FnCryptoArgon2idVerify(cm), "password", "input", "argon2_verified")
+	assertField(t, m, "argon2_verified", true)
+	runFn(t, m, FnCryptoArgon2idVerify(cm), "password", "body", "argon2_verified")
+	assertField(t, m, "argon2_verified", false)
+	if _, err := FnCryptoArgon2id(cm)([]interface{}{"password", int64(1), int64(1024 * 1024), int64(1)}); err == nil {
+		t.Fatal("memory exceeding the limit should be rejected")
+	}
+	limited := testConfigureManager{"crypto.argon2id.max_memory": "512"}
+	for _, hashed := range []string{
+		"argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2i$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2id$v=19$m=1024,t=1,p=0$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2id$v=19$m=1024,t=1,p=256$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHQ$!!",
+		"$argon2id$v=19$m=4294967295,t=1,p=1$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2id$v=19$m=1024,t=1000,p=1$c2FsdHNhbHQ$aGFzaGhhc2g",
+		"$argon2id$v=19$m=1024,t=1,p=64$c2FsdHNhbHQ$aGFzaGhhc2g",
+	} {
+		mm := newModel(t, map[string]interface{}{"hash": hashed, "input": "p@ss"})
+		f, err := FnCryptoArgon2idVerify(cm)([]interface{}{"hash", "input", "verified"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := f(mm); err == nil {
+			t.Fatal("malformed hash should be rejected:", hashed)
+		}
+	}
+	mm := newModel(t, map[string]interface{}{"hash": m.GetFieldUnsafe0([]string{"password"}), "input": "p@ss"})
+	runFn(t, mm, FnCryptoArgon2idVerify(cm), "hash", "input", "verified")
+	assertField(t, mm, "verified", true)
+	f, err := FnCryptoArgon2idVerify(limited)([]interface{}{"hash", "input", "verified"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f(mm); err == nil {
+		t.Fatal("hash exceeding the configured limit should be rejected")
+	}
+	if _, err := FnCryptoArgon2idVerify(testConfigureManager{"crypto.argon2id.max_parallelism": "256"})([]interface{}{"hash", "input", "verified"}); err == nil {
+		t.Fatal("invalid limit configure should be rejected")
+	}
+	runFn(t, m, FnCryptoRandomToken, int64(32), "hex", "token")
+	if s, _ := m.GetFieldUnsafe0([]string{"token"}).(string); len(s) != 64 {
+		t.Fatal("unexpected token:", s)
+	}
+	runFn(t, m, FnCryptoConstantTimeEquals, "digest", "digest", "equals")
+	assertField(t, m, "equals", true)
+
+	for _, key := range []string{"key", "configure-static://unknown", "configure-dynamic://secret"} {
+		if _, err := FnCryptoHmacSign(cm)([]interface{}{"body", "sha256", key, "hex", "signature"}); err == nil {
+			t.Fatal("key should be available configure placeholder:", key)
+		}
+	}
+}
+
+type testContainer struct {
+	pluginapi.Container
+	cm basicapi.ConfigureManager
+}
+
+func (c testContainer) ConfigureManager() basicapi.ConfigureManager {
+	return c.cm
+}
+
+func (c testContainer) GetFileResourceManager(name string) pluginapi.FileResourceManager {
+	return nil
+}
+
+func TestJwtFn(t *testing.T) {
+	now := time.Date(2024, 3, 14, 18, 30, 0, 0, time.UTC)
+	SetClock(func() time.Time {
+		return now
+	})
+	defer SetClock(nil)
+
+	container := testContainer{cm: testConfigureManager{"secret": "jwt-secret", "other": "other-secret"}}
+	m := newModel(t, map[string]interface{}{})
+	if err := m.AddOrUpdateField0([]string{"claims", "sub"}, "1001"); err != nil {
+		t.Fatal(err)
+	}
+	runFn(t, m, FnJwtSign(container), "claims", "HS256", "configure-static://secret", "1h", "token")
+	runFn(t, m, FnJwtVerify(container), "token", "HS256", "configure-static://secret", "session", "valid")
+	assertField(t, m, "valid", true)
+	if v := m.GetFieldUnsafe0([]string{"session", "sub"}); v != "1001" {
+		t.Fatal("unexpected sub:", v)
+	}
+	if v := m.GetFieldUnsafe0([]string{"session", "exp"}); v != now.Add(time.Hour).Unix() {
+		t.Fatal("unexpected exp:", v)
+	}
+	runFn(t, m, FnJwtVerify(container), "token", "HS256", "configure-static://other", "other_session", "valid")
+	assertField(t, m, "valid", false)
+
+	now = now.Add(2 * time.Hour)
+	runFn(t, m, FnJwtVerify(container), "token", "HS256", "configure-static://secret", "session", "valid")
+	assertField(t, m, "valid", false)
+
+	if _, err := FnJwtSign(container)([]interface{}{"claims", "none", "configure-static://secret", "1h", "token"}); err == nil {
+		t.Fatal("unsupported algorithm should fail")
+	}
+	if _, err := FnJwtVerify(container)([]interface{}{"token", "RS256", "configure-static://secret", "session", "valid"}); err == nil {
+		t.Fatal("non-PEM key of RS256 should fail")
+	}
+}
+
+type testTypeResolver map[string][2]pluginapi.DataType
+
+func (r testTypeResolver) TypeOfDefinitionPath(path string) (pluginapi.DataType, pluginapi.DataType, error) {
+	dt := r[path]
+	return dt[0], dt[1], nil
+}
+
+func TestEncodingFn(t *testing.T) {
+	m := newModel(t, map[string]interface{}{"text": "a b&c/é"})
+	runFn(t, m, FnBase64Encode, "text", "base64")
+	assertField(t, m, "base64", "YSBiJmMvw6k=")
+	runFn(t, m, FnBase64UrlEncode, "text", "base64url")
+	runFn(t, m, FnBase64UrlDecode, "base64url", "base64url_decoded")
+	assertField(t, m, "base64url_decoded", "a b&c/é")
+	runFn(t, m, FnHexEncode, "text", "hex")
+	runFn(t, m, FnHexDecode, "hex", "hex_decoded")
+	assertField(t, m, "hex_decoded", "a b&c/é")
+	runFn(t, m, FnUrlEncode, "text", "url")
+	assertField(t, m, "url", "a+b%26c%2F%C3%A9")
+	runFn(t, m, FnUrlDecode, "url", "url_decoded")
+	assertField(t, m, "url_decoded", "a b&c/é")
+
+	resolver := testTypeResolver{
+		"order":                {pluginapi.DataTypeObject},
+		"order/id":             {pluginapi.DataTypeInt},
+		"order/price":          {pluginapi.DataTypeFloat},
+		"order/tags[]":         {pluginapi.DataTypeArray, pluginapi.DataTypeString},
+		"order/items[]":        {pluginapi.DataTypeArray},
+		"order/items[]/sku":    {pluginapi.DataTypeString},
+		"order/items[]/amount": {pluginapi.DataTypeInt},
+	}
+	if err := m.AddOrUpdateField0([]string{"json"}, `{"id":7,"price":10,"tags":["a","b"],"items":[{"sku":"x","amount":2,"extra":true}],"note":"dropped"}`); err != nil {
+		t.Fatal(err)
+	}
+	runFn(t, m, func(params []interface{}) (pluginapi.Fn, error) {
+		return FnJsonParse(resolver, params)
+	}, "json", "order")
+	if v := m.GetFieldUnsafe0([]string{"order", "price"}); v != 10.0 {
+		t.Fatal("int should be converted to float:", v)
+	}
+	if v := m.GetFieldUnsafe0([]string{"order", "items[0]", "amount"}); v != int64(2) {
+		t.Fatal("unexpected amount:", v)
+	}
+	runFn(t, m, FnJsonStringify, "order", "stringified")
+	assertField(t, m, "stringified", `{"id":7,"items":[{"amount":2,"sku":"x"}],"price":10,"tags":["a","b"]}`)
+	runFn(t, m, FnJsonStringify, "order/tags[]", "tags_json")
+	assertField(t, m, "tags_json", `["a","b"]`)
+
+	if err := m.AddOrUpdateField0([]string{"json"}, `{"id":"7"}`); err != nil {
+		t.Fatal(err)
+	}
+	if f, err := FnJsonParse(resolver, []interface{}{"json", "order"}); err != nil {
+		t.Fatal(err)
+	} else if err := f(m); !errors.Is(err, basicapi.ErrFieldTypeMismatch) {
+		t.Fatal("string of int field should fail:", err)
+	}
+	if _, err := FnJsonParse(resolver, []interface{}{"json", "unknown"}); err == nil {
+		t.Fatal("target not defined should fail")
+	}
+	// null members of merge patch remove fields
+	if err := m.AddOrUpdateField0([]string{"patch"}, `{"price":null,"tags":["c"],"items":[{"sku":"y"}],"note":"dropped"}`); err != nil {
+		t.Fatal(err)
+	}
+	runFn(t, m, func(params []interface{}) (pluginapi.Fn, error) {
+		return FnMergePatch(resolver, params)
+	}, "patch", "order")
+	runFn(t, m, FnJsonStringify, "order", "patched")
+	assertField(t, m, "patched", `{"id":7,"items":[{"sku":"y"}],"tags":["c"]}`)
+	if _, err := FnMergePatch(resolver, []interface{}{"patch", "order/tags[]"}); err == nil {
+		t.Fatal("target of array should fail")
+	}
+
+	if f, err := FnBase64Decode([]interface{}{"text", "decoded"}); err != nil {
+		t.Fatal(err)
+	} else if err := f(m); err == nil {
+		t.Fatal("invalid base64 should fail")
+	}
+}
+
+func TestArrayFn(t *testing.T) {
+	m := newModel(t, map[string]interface{}{"role": "admin"})
+	for _, item := range []map[string]interface{}{
+		{"sku": "b", "price": 2.5, "category": "food", "tags": []interface{}{"x"}},
+		{"sku": "a", "price": int64(4), "category": "toy", "tags": []interface{}{"y", "z"}},
+		{"sku": "b", "price": int64(1), "category": "food"},
+	} {
+		if err := basicapi.PutGeneralObject(m, []string{"items"}, []interface{}{item}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runFn(t, m, FnArrayLength, "items[]", "count")
+	assertField(t, m, "count", int64(3))
+	runFn(t, m, FnCollect, "items[?price>10]/sku", "expensive[]")
+	if dt, err := m.FieldType([]string{"expensive"}); err != nil || dt != pluginapi.DataTypeArray {
+		t.Fatal("empty array should be kept when nothing matches:", dt, err)
+	}
+	if v := m.ToGeneralObject().(map[string]interface{})["expensive"]; len(v.([]interface{})) != 0 {
+		t.Fatal("unexpected collected values:", v)
+	}
+	runFn(t, m, FnArraySum, "items[]", "price", "total")
+	assertField(t, m, "total", 7.5)
+	runFn(t, m, FnArrayMax, "items[]", "price", "max")
+	assertField(t, m, "max", int64(4))
+	runFn(t, m, FnArraySort, "items[]", "price", "asc", "sorted[]")
+	if v := m.GetFieldUnsafe0([]string{"sorted[0]", "price"}); v != int64(1) {
+		t.Fatal("unexpected first element:", v)
+	}
+	runFn(t, m, FnArrayDistinct, "items[]", "sku", "distinct[]")
+	runFn(t, m, FnArrayLength, "distinct[]", "count")
+	assertField(t, m, "count", int64(2))
+	runFn(t, m, FnArrayFlatten, "items[]", "tags[]", "tags[]")
+	runFn(t, m, FnArrayReverse, "tags[]", "tags[]")
+	runFn(t, m, FnArrayPrepend, "tags[]", "role", "tags[]")
+	runFn(t, m, FnArrayRemoveAt, "tags[]", int64(-1), "tags[]")
+	runFn(t, m, FnArraySlice, "tags[]", int64(0), int64(2), "tags[]")
+	runFn(t, m, FnStringJoin, "tags[]", ",", "joined")
+	assertField(t, m, "joined", "admin,z")
+	runFn(t, m, FnArraySlice, "tags[]", int64(0), int64(0), "empty[]")
+	if n, err := m.ArrayLength([]string{"empty"}); err != nil || n != 0 || m.ToGeneralObject().(map[string]interface{})["empty"] == nil {
+		t.Fatal("empty result should be kept as empty array:", n, err)
+	}
+	if err := setGeneralObject(m, []string{"nested"}, map[string]interface{}{"list": []interface{}{}}); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := getGeneralObject(m, []string{"nested", "list"}); err != nil || !ok || len(v.([]interface{})) != 0 {
+		t.Fatal("nested empty array should be kept:", v, ok, err)
+	}
+	if _, ok, err := getGeneralObject(m, []string{"missing", "list"}); err != nil || ok {
+		t.Fatal("field under missing parent should not exist:", ok, err)
+	}
+	runFn(t, m, FnArrayIndexOf, "tags[]", "role", "index")
+	assertField(t, m, "index", int64(0))
+	runFn(t, m, FnArrayContains, "missing[]", "role", "contains")
+	assertField(t, m, "contains", false)
+	runFn(t, m, FnArrayGroupBy, "items[]", "category", "groups")
+	if n, err := m.ArrayLength([]string{"groups", "food"}); err != nil || n != 2 {
+		t.Fatal("unexpected group:", n, err)
+	}
+
+	if _, err := FnArraySort([]interface{}{"items[]", "price", "up", "sorted[]"}); err == nil {
+		t.Fatal("unknown direction should fail")
+	}
+	if f, err := FnArraySum([]interface{}{"items[]", "sku", "total"}); err != nil {
+		t.Fatal(err)
+	} else if err := f(m); !errors.Is(err, basicapi.ErrFieldTypeMismatch) {
+		t.Fatal("sum of string field should fail:", err)
+	}
+}
+
+func validationCheckOf(t *testing.T, rule string) validationCheck {
+	for _, c := range validationChecks {
+		if c.rule == rule {
+			return c
+		}
+	}
+	t.Fatal("unknown rule:", rule)
+	return validationCheck{}
+}
+
+func TestValidationCheckFn(t *testing.T) {
+	SetClock(func() time.Time {
+		return time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)
+	})
+	defer SetClock(nil)
+
+	m := newModel(t, map[string]interface{}{"email": "alice", "age": int64(17), "name": "Al", "status": "active",
+		"homepage": "https://example.com/a", "id": "9f1c2d3e-0000-4000-8000-00000000000a", "birthday": "2030-01-01"})
+	for _, c := range []struct {
+		rule   string
+		params []interface{}
+	}{
+		{"email", []interface{}{"email"}},
+		{"range", []interface{}{"age", int64(18), ""}},
+		{"length", []interface{}{"name", int64(3), int64(32)}},
+		{"enum", []interface{}{"status", []interface{}{"active", "disabled"}}},
+		{"url", []interface{}{"homepage"}},
+		{"uuid", []interface{}{"id"}},
+		{"regex", []interface{}{"name", "^[A-Z]"}},
+		{"date_range", []interface{}{"birthday", "2006-01-02", "1900-01-01T00:00:00Z", "now"}},
+		{"array_size", []interface{}{"tags[]", int64(0), int64(3)}},
+		{"range", []interface{}{"missing", int64(0), int64(1)}},
+	} {
+		runFn(t, m, validationCheckOf(t, c.rule).collectFn(), append(c.params, c.rule+" invalid", "errors[]")...)
+	}
+	if n, _ := m.ArrayLength([]string{"errors"}); n != 4 {
+		t.Fatal("unexpected error count:", n)
+	}
+	if v := m.GetFieldUnsafe0([]string{"errors[1]", "path"}); v != "age" {
+		t.Fatal("unexpected error path:", v)
+	}
+
+	f, err := CheckErrorsBreak([]interface{}{"errors[]", pluginapi.FlowErrorKeyValidationFailed, "invalid request"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flowErr *pluginapi.FlowError
+	if err := f(m); !errors.As(err, &flowErr) || len(flowErr.Details) != 4 || flowErr.Details[3].Rule != "date_range" {
+		t.Fatal("collected errors should break the flow:", err)
+	}
+
+	f, err = validationCheckOf(t, "length").breakFn()([]interface{}{"name", int64(3), "", "invalid_name", "name is too short"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f(m); !errors.As(err, &flowErr) || flowErr.Key != "invalid_name" {
+		t.Fatal("length check should break the flow:", err)
+	}
+	if _, err := validationCheckOf(t, "regex").breakFn()([]interface{}{"name", "[", "key", "message"}); err == nil {
+		t.Fatal("invalid pattern should fail")
+	}
+}
+
+func TestIdFn(t *testing.T) {
+	SetClock(func() time.Time {
+		return time.Date(2024, 3, 14, 18, 30, 0, 0, time.UTC)
+	})
+	defer SetClock(nil)
+
+	m := newModel(t, map[string]interface{}{})
+	runFn(t, m, FnIdUuidV7, "uuid")
+	if s, _ := m.GetFieldUnsafe0([]string{"uuid"}).(string); !strings.HasPrefix(s, "018e3e3a-6c40-7") {
+		t.Fatal("unexpected uuid:", s)
+	}
+	runFn(t, m, FnIdUlid, "ulid")
+	if s, _ := m.GetFieldUnsafe0([]string{"ulid"}).(string); !strings.HasPrefix(s, "01HRZ3MV20") {
+		t.Fatal("unexpected ulid:", s)
+	}
+	runFn(t, m, FnIdKsuid, "ksuid")
+	if s, _ := m.GetFieldUnsafe0([]string{"ksuid"}).(string); len(s) != 27 {
+		t.Fatal("unexpected ksuid:", s)
+	}
+
+	cm := testConfigureManager{"node": "7", "invalid": "node-a"}
+	runFn(t, m, FnIdSnowflake(cm), "configure-static://node", "first")
+	runFn(t, m, FnIdSnowflake(cm), int64(7), "second")
+	first, _ := m.GetFieldUnsafe0([]string{"first"}).(int64)
+	second, _ := m.GetFieldUnsafe0([]string{"second"}).(int64)
+	if first>>12&1023 != 7 || second != first+1 {
+		t.Fatal("flows of the same node should share sequence:", first, second)
+	}
+	for _, node := range []interface{}{"configure-static://invalid", "configure-static://unknown", int64(1024)} {
+		if _, err := FnIdSnowflake(cm)([]interface{}{node, "id"}); err == nil {
+			t.Fatal("invalid node should fail:", node)
+		}
+	}
+}
+
+type schemaContainer struct {
+	testContainer
+	schemas map[string]pluginapi.FnSchema
+}
+
+func (c schemaContainer) RegisterBuiltinFn(name string, fnGen pluginapi.FnGen) error {
+	return errors.New("builtin function without schema:" + name)
+}
+
+func (c schemaContainer) RegisterBuiltinTypedFn(name string, fnGen pluginapi.TypedFnGen) error {
+	return errors.New("builtin function without schema:" + name)
+}
+
+func (c schemaContainer) RegisterBuiltinFnWithSchema(name string, schema pluginapi.FnSchema, fnGen pluginapi.FnGen) error {
+	return c.register(name, schema)
+}
+
+func (c schemaContainer) RegisterBuiltinTypedFnWithSchema(name string, schema pluginapi.FnSchema, fnGen pluginapi.TypedFnGen) error {
+	return c.register(name, schema)
+}
+
+func (c schemaContainer) register(name string, schema pluginapi.FnSchema) error {
+	if err := schema.Validate(); err != nil {
+		return errors.New(name + " " + err.Error())
+	}
+	if _, ok := c.schemas[name]; ok {
+		return errors.New("duplicated function:" + name)
+	}
+	c.schemas[name] = schema
+	return nil
+}
+
+func TestFnSchemas(t *testing.T) {
+	container := schemaContainer{
+		testContainer: testContainer{cm: testConfigureManager{}},
+		schemas:       map[string]pluginapi.FnSchema{},
+	}
+	if err := InitFn(container); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"@assign", "@collect", "@json_parse", "@merge_patch", "@check_errors_break", "@check_range", "@check_range_break"} {
+		if _, ok := container.schemas[name]; !ok {
+			t.Fatal("function should be registered with schema:", name)
+		}
+	}
+	if least, most := container.schemas["@check_date_range"].ParamCountRange(); least != 6 || most != 6 {
+		t.Fatal("unexpected parameter count of @check_date_range:", least, most)
+	}
+	if least, most := container.schemas["@string_format"].ParamCountRange(); least != 2 || most != -1 {
+		t.Fatal("unexpected parameter count of @string_format:", least, most)
+	}
+}
@@ -3,3 +3,21 @@ package basicapi
 type Fn func(m Model) error
 
 type FnGen func(params []interface{}) (Fn, error)
+
+// PathTypeResolver resolves data types of definition paths, e.g. user/name, items[]/id, tags[]
+// Returns data type of the last level and element data type of primitive array
+// DataTypeUnavailable is returned without error if the path is not defined
+type PathTypeResolver interface {
+	TypeOfDefinitionPath(path string) (DataType, DataType, error)
+}
+
+// TypedFnGen generates function with data types of the Model which the function is applied on
+// For steps of flows, data types come from FlowModel through in/out mappings and local variables
+type TypedFnGen func(resolver PathTypeResolver, params []interface{}) (Fn, error)
+
+// PreOutOperation is applied to the path of the output Model before(pre_out) or after(post_out) out mapping of the flow
+type PreOutOperation func(m Model, path []string) error
+
+// PreOutOperationGen generates operation from parameters after the path, e.g. ["@mask", "user/phone", "4"] -> ["4"]
+// dataType is the data type of the path in FlowModel, DataTypeUnavailable if the path is a query
+type PreOutOperationGen func(dataType DataType, params []string) (PreOutOperation, error)
@@ -0,0 +1,127 @@
+package basicapi
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParamKind is the kind of function parameter in flows
+type ParamKind string
+
+const (
+	// ParamKindPath is path of the field, e.g. user/name, items[0]/id, tags[]
+	ParamKindPath ParamKind = "path"
+	// ParamKindString is string literal, e.g. format, error key or configure placeholder
+	ParamKindString ParamKind = "string"
+	ParamKindInt    ParamKind = "int"
+	// ParamKindNumber is int or float literal
+	ParamKindNumber ParamKind = "number"
+	ParamKindBool   ParamKind = "bool"
+	ParamKindArray  ParamKind = "array"
+	// ParamKindAny accepts any literal, e.g. value of @assign
+	ParamKindAny ParamKind = "any"
+)
+
+var paramKinds = map[ParamKind]struct{}{
+	ParamKindPath:   {},
+	ParamKindString: {},
+	ParamKindInt:    {},
+	ParamKindNumber: {},
+	ParamKindBool:   {},
+	ParamKindArray:  {},
+	ParamKindAny:    {},
+}
+
+// ParamSchema declares a parameter of the function
+type ParamSchema struct {
+	Name string
+	Kind ParamKind
+	// Optional parameters should be after required parameters
+	Optional bool
+	// Variadic parameter matches one or more parameters, zero or more if Optional
+	// Parameters after the variadic parameter are matched from the end, e.g. sources and target of @string_concat
+	Variadic bool
+	// MustExist requires the path to be defined in FlowModel through in/out mappings or local variables of the flow
+	MustExist   bool
+	Description string
+}
+
+// FnSchema declares parameters of the function
+// Parameters of steps are validated against the schema when the flow is loaded and before FnGen is called
+type FnSchema struct {
+	Description string
+	Params      []ParamSchema
+}
+
+// Validate checks the declaration of the schema
+func (s FnSchema) Validate() error {
+	variadic := -1
+	optional := -1
+	for idx, p := range s.Params {
+		if p.Name == "" {
+			return errors.New(fmt.Sprintf("parameter %d has empty name", idx))
+		}
+		if _, ok := paramKinds[p.Kind]; !ok {
+			return errors.New(fmt.Sprintf("parameter %d(%s) has unknown kind:%s", idx, p.Name, p.Kind))
+		}
+		if p.MustExist && p.Kind != ParamKindPath {
+			return errors.New(fmt.Sprintf("parameter %d(%s) MustExist requires path kind", idx, p.Name))
+		}
+		if p.Variadic {
+			if variadic >= 0 {
+				return errors.New(fmt.Sprintf("parameter %d(%s) only one parameter could be variadic", idx, p.Name))
+			}
+			variadic = idx
+		}
+		if p.Optional && !p.Variadic {
+			// optional variadic parameter could be followed by required parameters which are matched from the end
+			if optional < 0 {
+				optional = idx
+			}
+		} else if !p.Optional && optional >= 0 {
+			return errors.New(fmt.Sprintf("parameter %d(%s) required parameter should not be after optional parameters", idx, p.Name))
+		}
+	}
+	if variadic >= 0 && optional >= 0 {
+		return errors.New("optional parameters are not allowed together with variadic parameter except the variadic one")
+	}
+	return nil
+}
+
+// ParamIndex returns index of the schema parameter which the idx-th of count parameters matches
+// Count should be checked by ParamCountRange first
+func (s FnSchema) ParamIndex(idx, count int) int {
+	for v, p := range s.Params {
+		if !p.Variadic {
+			continue
+		}
+		after := len(s.Params) - 1 - v
+		switch {
+		case idx < v:
+			return idx
+		case idx >= count-after:
+			return len(s.Params) - (count - idx)
+		default:
+			return v
+		}
+	}
+	return idx
+}
+
+// ParamCountRange returns min and max count of parameters, max is -1 if unlimited
+func (s FnSchema) ParamCountRange() (int, int) {
+	required := 0
+	variadic := false
+	for _, p := range s.Params {
+		if !p.Optional {
+			required++
+		}
+		if p.Variadic {
+			variadic = true
+		}
+	}
+	if variadic {
+		return required, -1
+	}
+	return required, len(s.Params)
+}
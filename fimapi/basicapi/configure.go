@@ -1,5 +1,10 @@
 package basicapi
 
+const (
+	ConfigurePrefixStatic  = "configure-static://"
+	ConfigurePrefixDynamic = "configure-dynamic://"
+)
+
 type ConfigureManager interface {
 	ReplaceStaticConfigure(placeholder string) string
 	ReplaceDynamicConfigure(placeholder string) string
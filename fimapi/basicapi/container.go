@@ -2,10 +2,16 @@ package basicapi
 
 type BasicContainer interface {
 	RegisterCustomFn(name string, fnGen FnGen) error
+	// RegisterCustomFnWithSchema registers custom function whose parameters are validated against the schema when flows are loaded
+	RegisterCustomFnWithSchema(name string, schema FnSchema, fnGen FnGen) error
+	// FnReferenceDoc generates markdown reference of functions registered with schemas
+	FnReferenceDoc() string
 	AddConfigureManager(manager ConfigureManager) error
 
 	LoadFlowModel(tomlContent string) error
 	LoadMerged(content string) error
+	// EnableCompiledModel uses schema-compiled and pooled Models. Should be called before StartContainer.
+	EnableCompiledModel() error
 
 	StartContainer() error
 }
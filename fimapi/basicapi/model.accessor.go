@@ -0,0 +1,65 @@
+package basicapi
+
+import (
+	"sync/atomic"
+)
+
+// PathResolver is implemented by Models which are able to resolve paths in advance, e.g. schema-compiled Model
+type PathResolver interface {
+	// PathResolverOwner returns the owner of resolutions. Resolutions are shared by Models with the same owner.
+	PathResolverOwner() interface{}
+	// ResolvePath returns resolution of the path, false if the path cannot be resolved
+	ResolvePath(path []string) (interface{}, bool)
+	GetResolvedField(resolved interface{}) interface{}
+	AddOrUpdateResolvedField(resolved interface{}, value interface{}) error
+}
+
+// FieldAccessor accesses a field of Model with the path prepared in advance
+// Resolution from PathResolver is cached and reused by the accessor
+// Otherwise GetFieldUnsafe0/AddOrUpdateField0 are used
+type FieldAccessor struct {
+	path  []string
+	cache atomic.Pointer[fieldAccessorCache]
+}
+
+type fieldAccessorCache struct {
+	owner    interface{}
+	resolved interface{}
+	ok       bool
+}
+
+func NewFieldAccessor(path []string) *FieldAccessor {
+	return &FieldAccessor{path: path}
+}
+
+func (a *FieldAccessor) Path() []string {
+	return a.path
+}
+
+func (a *FieldAccessor) resolve(r PathResolver) (interface{}, bool) {
+	owner := r.PathResolverOwner()
+	if c := a.cache.Load(); c != nil && c.owner == owner {
+		return c.resolved, c.ok
+	}
+	resolved, ok := r.ResolvePath(a.path)
+	a.cache.Store(&fieldAccessorCache{owner: owner, resolved: resolved, ok: ok})
+	return resolved, ok
+}
+
+func (a *FieldAccessor) Get(m Model) interface{} {
+	if r, ok := m.(PathResolver); ok {
+		if resolved, ok := a.resolve(r); ok {
+			return r.GetResolvedField(resolved)
+		}
+	}
+	return m.GetFieldUnsafe0(a.path)
+}
+
+func (a *FieldAccessor) Set(m Model, value interface{}) error {
+	if r, ok := m.(PathResolver); ok {
+		if resolved, ok := a.resolve(r); ok {
+			return r.AddOrUpdateResolvedField(resolved, value)
+		}
+	}
+	return m.AddOrUpdateField0(a.path, value)
+}
@@ -0,0 +1,86 @@
+package basicapi
+
+// General object(see Model.ToGeneralObject) of fields
+
+// GetGeneralObject returns general object of the field, object or array, nil if not exists
+// Only the field is copied rather than the whole Model
+func GetGeneralObject(m Model, path []string) (interface{}, error) {
+	dataType, err := m.FieldType(path)
+	if err != nil {
+		return nil, err
+	}
+	switch dataType {
+	case DataTypeUnavailable:
+		return nil, nil
+	case DataTypeObject:
+		sub, err := m.CopySubModel(path)
+		if err != nil {
+			return nil, err
+		}
+		return sub.ToGeneralObject(), nil
+	case DataTypeArray:
+		n, err := m.ArrayLength(path)
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, 0, n)
+		for i := 0; i < n; i++ {
+			elem, err := GetGeneralObject(m, elementPath(path, i))
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, elem)
+		}
+		return arr, nil
+	default:
+		return m.GetField(path)
+	}
+}
+
+// PutGeneralObject writes general object of the field, object or array to the path
+// Existing fields are updated and array elements are appended, delete the field first for replacement
+// Empty arrays are written as empty arrays
+func PutGeneralObject(m Model, path []string, obj interface{}) error {
+	switch v := obj.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		for key, val := range v {
+			if err := PutGeneralObject(m, append(append([]string{}, path...), key), val); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		if len(v) == 0 {
+			// keep empty array rather than leaving the field absent
+			dataType, err := m.FieldType(path)
+			if err != nil {
+				return err
+			}
+			if dataType == DataTypeUnavailable {
+				return ResetArray(m, path)
+			}
+			return nil
+		}
+		for _, elem := range v {
+			sub, ok := elem.(map[string]interface{})
+			if !ok {
+				if _, err := m.AppendArrayElement(path, elem); err != nil {
+					return err
+				}
+				continue
+			}
+			idx, err := m.AppendArrayElement(path, nil)
+			if err != nil {
+				return err
+			}
+			if err := PutGeneralObject(m, elementPath(path, idx), sub); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return m.AddOrUpdateField0(path, v)
+	}
+}
@@ -0,0 +1,67 @@
+package basicapi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Typed getters of Model
+// ErrFieldNotFound is returned if the field does not exist and ErrFieldTypeMismatch if the value is not the expected type
+
+func GetString(m Model, path []string) (string, error) {
+	v, err := getTypedField(m, path, DataTypeString)
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+func GetInt(m Model, path []string) (int64, error) {
+	v, err := getTypedField(m, path, DataTypeInt)
+	if err != nil {
+		return 0, err
+	}
+	return v.(int64), nil
+}
+
+func GetFloat(m Model, path []string) (float64, error) {
+	v, err := getTypedField(m, path, DataTypeFloat)
+	if err != nil {
+		return 0, err
+	}
+	return v.(float64), nil
+}
+
+func GetBool(m Model, path []string) (bool, error) {
+	v, err := getTypedField(m, path, DataTypeBool)
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+func getTypedField(m Model, path []string, dataType DataType) (interface{}, error) {
+	v, err := m.GetField(path)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, fmt.Errorf("%w: path=[%s]", ErrFieldNotFound, strings.Join(path, "/"))
+	}
+	var ok bool
+	switch dataType {
+	case DataTypeString:
+		_, ok = v.(string)
+	case DataTypeInt:
+		_, ok = v.(int64)
+	case DataTypeFloat:
+		_, ok = v.(float64)
+	case DataTypeBool:
+		_, ok = v.(bool)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: path=[%s] type=[%s]", ErrFieldTypeMismatch, strings.Join(path, "/"), reflect.TypeOf(v))
+	}
+	return v, nil
+}
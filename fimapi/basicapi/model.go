@@ -6,15 +6,51 @@ import (
 	"reflect"
 )
 
-type Model interface {
-	//FIXME need design the type and behaviors of Model
+type DataType int
+
+const (
+	DataTypeUnavailable DataType = 0
+	DataTypeInt         DataType = 1
+	DataTypeString      DataType = 2
+	DataTypeBool        DataType = 3
+	DataTypeFloat       DataType = 4
+	DataTypeArray       DataType = 51
+	DataTypeObject      DataType = 52
+)
 
+var (
+	ErrFieldNotFound     = errors.New("field not found")
+	ErrFieldTypeMismatch = errors.New("field type mismatch")
+)
+
+// Model is the data object processed by flows
+// Path is a list of levels, each level is a field name or an array element with index, e.g. []string{"user", "tags[0]"}
+// Empty path refers to the root object
+// Primitive values are int64, float64, string and bool
+type Model interface {
 	//AddOrUpdateField0 supports primitive value only from object and array(nested array is ok)
 	AddOrUpdateField0(path []string, value interface{}) error
 	//GetFieldUnsafe0 supports primitive value only from object and array(nested array is ok)
 	GetFieldUnsafe0(path []string) interface{}
 
 	ToGeneralObject() interface{}
+
+	// GetField returns primitive value of the path, nil if the field does not exist
+	// ErrFieldTypeMismatch is returned if the field or any level of the path is not the expected type
+	GetField(path []string) (interface{}, error)
+	// FieldType returns data type of the path, DataTypeUnavailable if the field does not exist
+	FieldType(path []string) (DataType, error)
+	// Keys returns sorted field names of the object
+	Keys(path []string) ([]string, error)
+	// ArrayLength returns length of object array or primitive array, 0 if the array does not exist
+	ArrayLength(path []string) (int, error)
+	// AppendArrayElement appends primitive value to primitive array or an empty object to object array if value is nil
+	// The array is created if not exists. Index of the new element is returned.
+	AppendArrayElement(path []string, value interface{}) (int, error)
+	// DeleteField deletes field, object, array or array element(last level with index). Nothing happens if not exists.
+	DeleteField(path []string) error
+	// CopySubModel returns a writable deep copy of the object. ErrFieldNotFound is returned if the object does not exist.
+	CopySubModel(path []string) (Model, error)
 }
 
 func ConvertPrimitive(in interface{}) (interface{}, error) {
@@ -0,0 +1,494 @@
+package basicapi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query selects fields of Model using a subset of JSONPath with the path separator '/'
+// Each level is one of:
+//
+//	name        - field of object, '*' for all fields
+//	name[3]     - element of array
+//	name[*]     - all elements of array
+//	name[?pred] - elements of object array matching the predicate, e.g. items[?price>10], items[?status=='active'], items[?flag]
+//	**          - recursive descent, matches zero or more levels of objects
+//
+// Predicate compares a field of the element with a literal(string, number, bool) by ==, !=, <, <=, >, >=
+// Without operator, predicate matches elements having the field
+type Query struct {
+	raw    string
+	levels []queryLevel
+}
+
+type queryLevel struct {
+	recursive bool
+	// name is the field name, "*" for all fields
+	name string
+	// selector of array elements
+	selector  byte
+	index     int
+	predicate *queryPredicate
+}
+
+const (
+	querySelectorNone = iota
+	querySelectorIndex
+	querySelectorAll
+	querySelectorPredicate
+)
+
+type queryPredicate struct {
+	field    string
+	operator string
+	value    interface{}
+}
+
+// IsQuery returns true if the path contains query syntax rather than a concrete path
+func IsQuery(path string) bool {
+	return strings.Contains(path, "*") || strings.Contains(path, "[?")
+}
+
+func ParseQuery(query string) (*Query, error) {
+	tokens, err := splitQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{raw: query}
+	for _, token := range tokens {
+		level, err := parseQueryLevel(token)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid query=[%s] level=[%s]: %s", query, token, err))
+		}
+		q.levels = append(q.levels, level)
+	}
+	if q.levels[len(q.levels)-1].recursive {
+		return nil, errors.New("query should not end with '**':" + query)
+	}
+	return q, nil
+}
+
+func (q *Query) String() string {
+	return q.raw
+}
+
+// DefinitionPrefix returns the definition path(e.g. items[]/price) of levels before the first '*' field or '**'
+// Empty if the query starts with wildcards. complete is true if all levels are included.
+func (q *Query) DefinitionPrefix() (prefix string, complete bool) {
+	var levels []string
+	for _, level := range q.levels {
+		if level.recursive || level.name == "*" {
+			return strings.Join(levels, "/"), false
+		}
+		if level.selector == querySelectorNone {
+			levels = append(levels, level.name)
+		} else {
+			levels = append(levels, level.name+"[]")
+		}
+	}
+	return strings.Join(levels, "/"), true
+}
+
+// splitQuery splits levels by '/' outside of brackets and quotes
+func splitQuery(query string) ([]string, error) {
+	var tokens []string
+	var quote byte
+	depth := 0
+	start := 0
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '[':
+			depth++
+		case ch == ']':
+			depth--
+		case ch == '/' && depth == 0:
+			tokens = append(tokens, query[start:i])
+			start = i + 1
+		}
+	}
+	if quote != 0 || depth != 0 {
+		return nil, errors.New("unclosed quote or bracket in query:" + query)
+	}
+	tokens = append(tokens, query[start:])
+	for _, token := range tokens {
+		if len(token) == 0 {
+			return nil, errors.New("empty level in query:" + query)
+		}
+	}
+	return tokens, nil
+}
+
+func parseQueryLevel(token string) (queryLevel, error) {
+	if token == "**" {
+		return queryLevel{recursive: true}, nil
+	}
+	level := queryLevel{name: token, index: -1}
+	bracket := strings.IndexByte(token, '[')
+	if bracket < 0 {
+		return level, checkQueryName(token)
+	}
+	if token[len(token)-1] != ']' {
+		return level, errors.New("array selector should be the end of level")
+	}
+	level.name = token[:bracket]
+	if err := checkQueryName(level.name); err != nil {
+		return level, err
+	}
+	selector := token[bracket+1 : len(token)-1]
+	switch {
+	case selector == "*":
+		level.selector = querySelectorAll
+	case strings.HasPrefix(selector, "?"):
+		predicate, err := parseQueryPredicate(selector[1:])
+		if err != nil {
+			return level, err
+		}
+		level.selector = querySelectorPredicate
+		level.predicate = predicate
+	default:
+		idx, err := strconv.Atoi(selector)
+		if err != nil || idx < 0 {
+			return level, errors.New("invalid array index")
+		}
+		level.selector = querySelectorIndex
+		level.index = idx
+	}
+	return level, nil
+}
+
+func checkQueryName(name string) error {
+	if name == "*" {
+		return nil
+	}
+	if len(name) == 0 {
+		return errors.New("empty field name")
+	}
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		if (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_' {
+			continue
+		}
+		if i > 0 && ((ch >= '0' && ch <= '9') || ch == '-' || ch == '.') {
+			continue
+		}
+		return errors.New("invalid character in field name")
+	}
+	return nil
+}
+
+var queryOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+func parseQueryPredicate(predicate string) (*queryPredicate, error) {
+	if idx, op := findQueryOperator(predicate); idx >= 0 {
+		field := strings.TrimSpace(predicate[:idx])
+		if err := checkQueryName(field); err != nil || field == "*" {
+			return nil, errors.New("invalid predicate field:" + field)
+		}
+		value, err := parseQueryLiteral(strings.TrimSpace(predicate[idx+len(op):]))
+		if err != nil {
+			return nil, err
+		}
+		return &queryPredicate{field: field, operator: op, value: value}, nil
+	}
+	field := strings.TrimSpace(predicate)
+	if err := checkQueryName(field); err != nil || field == "*" {
+		return nil, errors.New("invalid predicate field:" + field)
+	}
+	return &queryPredicate{field: field}, nil
+}
+
+// findQueryOperator returns index and operator of the first operator outside of quoted literals, -1 if not found
+func findQueryOperator(predicate string) (int, string) {
+	var quote byte
+	for i := 0; i < len(predicate); i++ {
+		ch := predicate[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && quote == '"' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		default:
+			for _, op := range queryOperators {
+				if strings.HasPrefix(predicate[i:], op) {
+					return i, op
+				}
+			}
+		}
+	}
+	return -1, ""
+}
+
+func parseQueryLiteral(literal string) (interface{}, error) {
+	switch {
+	case len(literal) >= 2 && literal[0] == '\'' && literal[len(literal)-1] == '\'':
+		return literal[1 : len(literal)-1], nil
+	case len(literal) >= 2 && literal[0] == '"':
+		return strconv.Unquote(literal)
+	case literal == "true":
+		return true, nil
+	case literal == "false":
+		return false, nil
+	case strings.ContainsAny(literal, ".eE"):
+		return strconv.ParseFloat(literal, 64)
+	default:
+		if v, err := strconv.ParseInt(literal, 10, 64); err == nil {
+			return v, nil
+		}
+		return nil, errors.New("invalid predicate literal:" + literal)
+	}
+}
+
+// Select returns concrete paths of existing fields matching the query, in the order of arrays and sorted field names
+func (q *Query) Select(m Model) ([][]string, error) {
+	var result [][]string
+	if err := q.selectLevel(m, 0, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Values returns primitive values matching the query
+// ErrFieldTypeMismatch is returned if any matched field is not primitive
+func (q *Query) Values(m Model) ([]interface{}, error) {
+	paths, err := q.Select(m)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(paths))
+	for _, path := range paths {
+		v, err := m.GetField(path)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (q *Query) selectLevel(m Model, levelIdx int, path []string, result *[][]string) error {
+	if levelIdx == len(q.levels) {
+		*result = append(*result, path)
+		return nil
+	}
+	level := q.levels[levelIdx]
+	if level.recursive {
+		// zero level
+		if err := q.selectLevel(m, levelIdx+1, path, result); err != nil {
+			return err
+		}
+		// descendant objects
+		return q.foreachChildObject(m, path, func(child []string) error {
+			return q.selectLevel(m, levelIdx, child, result)
+		})
+	}
+
+	var names []string
+	if level.name == "*" {
+		keys, err := m.Keys(path)
+		if err != nil {
+			return err
+		}
+		names = keys
+	} else {
+		names = []string{level.name}
+	}
+	last := levelIdx == len(q.levels)-1
+	for _, name := range names {
+		child := appendPath(path, name)
+		dataType, err := m.FieldType(child)
+		if err != nil {
+			return err
+		}
+		if dataType == DataTypeUnavailable {
+			continue
+		}
+		if level.selector == querySelectorNone {
+			if last || dataType == DataTypeObject {
+				if err := q.selectLevel(m, levelIdx+1, child, result); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if dataType != DataTypeArray {
+			continue
+		}
+		length, err := m.ArrayLength(child)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < length; i++ {
+			if level.selector == querySelectorIndex && i != level.index {
+				continue
+			}
+			elem := elementPath(child, i)
+			elemType, err := m.FieldType(elem)
+			if err != nil {
+				return err
+			}
+			if !last && elemType != DataTypeObject {
+				continue
+			}
+			if level.selector == querySelectorPredicate {
+				if elemType != DataTypeObject {
+					continue
+				}
+				matched, err := level.predicate.match(m, elem)
+				if err != nil {
+					return err
+				}
+				if !matched {
+					continue
+				}
+			}
+			if err := q.selectLevel(m, levelIdx+1, elem, result); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// foreachChildObject iterates child objects including objects in object arrays
+func (q *Query) foreachChildObject(m Model, path []string, f func(child []string) error) error {
+	keys, err := m.Keys(path)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		child := appendPath(path, key)
+		dataType, err := m.FieldType(child)
+		if err != nil {
+			return err
+		}
+		switch dataType {
+		case DataTypeObject:
+			if err := f(child); err != nil {
+				return err
+			}
+		case DataTypeArray:
+			length, err := m.ArrayLength(child)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < length; i++ {
+				elem := elementPath(child, i)
+				if elemType, err := m.FieldType(elem); err != nil {
+					return err
+				} else if elemType != DataTypeObject {
+					continue
+				}
+				if err := f(elem); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p *queryPredicate) match(m Model, elem []string) (bool, error) {
+	field := appendPath(elem, p.field)
+	if p.operator == "" {
+		dataType, err := m.FieldType(field)
+		return dataType != DataTypeUnavailable, err
+	}
+	v, err := m.GetField(field)
+	if err != nil || v == nil {
+		return false, err
+	}
+	switch p.operator {
+	case "==":
+		c, ok := compareQueryValue(v, p.value)
+		return ok && c == 0, nil
+	case "!=":
+		c, ok := compareQueryValue(v, p.value)
+		return !ok || c != 0, nil
+	}
+	c, ok := compareQueryValue(v, p.value)
+	if !ok {
+		return false, nil
+	}
+	switch p.operator {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+// compareQueryValue compares values of the same kind, false if not comparable
+func compareQueryValue(a, b interface{}) (int, bool) {
+	if af, ok := queryNumber(a); ok {
+		bf, ok := queryNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(av, bv), true
+	case bool:
+		bv, ok := b.(bool)
+		if !ok || av != bv {
+			return 1, ok
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func queryNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func appendPath(path []string, name string) []string {
+	r := make([]string, len(path)+1)
+	copy(r, path)
+	r[len(path)] = name
+	return r
+}
+
+func elementPath(path []string, idx int) []string {
+	r := make([]string, len(path))
+	copy(r, path)
+	r[len(r)-1] = fmt.Sprint(r[len(r)-1], "[", idx, "]")
+	return r
+}
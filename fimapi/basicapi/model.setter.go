@@ -0,0 +1,17 @@
+package basicapi
+
+// Setters of Model
+
+// ResetArray replaces the field with an empty array
+// Element type of the empty array is decided by the first appended element
+func ResetArray(m Model, path []string) error {
+	if err := m.DeleteField(path); err != nil {
+		return err
+	}
+	// Model creates arrays by appending, so an empty object element is appended and removed
+	idx, err := m.AppendArrayElement(path, nil)
+	if err != nil {
+		return err
+	}
+	return m.DeleteField(elementPath(path, idx))
+}
@@ -0,0 +1,58 @@
+package modelbind
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+// FnGen generates custom function from a typed function
+// Parameters of the step are optional paths of input and output objects, root object by default
+// e.g. { "#greet" = ["user", "result/greeting"] }
+// Output is not written back if fn returns nil
+func FnGen[In any, Out any](fn func(in *In) (*Out, error)) basicapi.FnGen {
+	return func(params []interface{}) (basicapi.Fn, error) {
+		for _, t := range []reflect.Type{reflect.TypeOf((*In)(nil)).Elem(), reflect.TypeOf((*Out)(nil)).Elem()} {
+			if t.Kind() != reflect.Struct {
+				return nil, errors.New("struct is required:" + t.String())
+			}
+			if _, err := structSpecs(t); err != nil {
+				return nil, err
+			}
+		}
+		if len(params) > 2 {
+			return nil, errors.New("at most 2 parameters are allowed: input path and output path")
+		}
+		paths := make([][]string, 2)
+		for i, param := range params {
+			p, ok := param.(string)
+			if !ok {
+				return nil, errors.New("path parameter should be string")
+			}
+			if p == "" {
+				continue
+			}
+			if !rule.ValidateFullPath(p) {
+				return nil, errors.New("path invalid:" + p)
+			}
+			paths[i] = rule.SplitFullPath(p)
+		}
+		inPath, outPath := paths[0], paths[1]
+		return func(m basicapi.Model) error {
+			in := new(In)
+			if err := Decode(m, inPath, in); err != nil {
+				return err
+			}
+			out, err := fn(in)
+			if err != nil {
+				return err
+			}
+			if out == nil {
+				return nil
+			}
+			return Encode(m, outPath, out)
+		}, nil
+	}
+}
@@ -0,0 +1,349 @@
+package modelbind
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+// Binding between Model and Go struct by field tags
+//
+//	type User struct {
+//		Name  string   `fim:"name"`
+//		Age   int      `fim:"profile/age"`
+//		Tags  []string `fim:"tags,omitempty"`
+//		Posts []Post   `fim:"posts"`
+//	}
+//
+// Tag paths are relative to the path of the struct. Fields without tag or with tag "-" are ignored.
+// Supported field types: string, bool, integers, floats, struct, pointers and slices of them(nested slices are not allowed)
+// Missing fields in the Model are left unchanged when decoding
+// Type mismatches are returned as basicapi.ErrFieldTypeMismatch
+
+const TagName = "fim"
+
+type fieldSpec struct {
+	index     int
+	path      []string
+	omitEmpty bool
+}
+
+var structSpecCache sync.Map // reflect.Type -> []fieldSpec
+
+func structSpecs(t reflect.Type) ([]fieldSpec, error) {
+	return buildStructSpecs(t, map[reflect.Type]bool{})
+}
+
+// buildStructSpecs parses tags of the struct and checks field types recursively
+// visiting protects against recursive types
+func buildStructSpecs(t reflect.Type, visiting map[reflect.Type]bool) ([]fieldSpec, error) {
+	if cached, ok := structSpecCache.Load(t); ok {
+		return cached.([]fieldSpec), nil
+	}
+	visiting[t] = true
+	var specs []fieldSpec
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup(TagName)
+		if !ok || tag == "-" {
+			continue
+		}
+		if !field.IsExported() {
+			return nil, errors.New(fmt.Sprintf("unexported field with fim tag:%s.%s", t, field.Name))
+		}
+		path, opts, _ := strings.Cut(tag, ",")
+		if !rule.ValidateFullPath(path) || rule.IsPathArray(path) {
+			return nil, errors.New(fmt.Sprintf("invalid fim tag path:%s.%s [%s]", t, field.Name, path))
+		}
+		if err := checkFieldType(field.Type, false, visiting); err != nil {
+			return nil, errors.New(fmt.Sprintf("field=[%s.%s] %s", t, field.Name, err))
+		}
+		specs = append(specs, fieldSpec{
+			index:     i,
+			path:      rule.SplitFullPath(path),
+			omitEmpty: opts == "omitempty",
+		})
+	}
+	structSpecCache.Store(t, specs)
+	return specs, nil
+}
+
+func checkFieldType(t reflect.Type, inSlice bool, visiting map[reflect.Type]bool) error {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return nil
+	case reflect.Pointer:
+		return checkFieldType(t.Elem(), inSlice, visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			return nil
+		}
+		_, err := buildStructSpecs(t, visiting)
+		return err
+	case reflect.Slice:
+		if inSlice {
+			return errors.New("nested slice is not supported")
+		}
+		return checkFieldType(t.Elem(), true, visiting)
+	default:
+		return errors.New("unsupported field type:" + t.String())
+	}
+}
+
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return reflect.Value{}, errors.New("nil pointer")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("struct is required:" + rv.Type().String())
+	}
+	return rv, nil
+}
+
+func joinPath(base []string, sub []string) []string {
+	r := make([]string, 0, len(base)+len(sub))
+	r = append(r, base...)
+	return append(r, sub...)
+}
+
+func elementPath(path []string, idx int) []string {
+	r := append([]string{}, path...)
+	r[len(r)-1] = r[len(r)-1] + "[" + strconv.Itoa(idx) + "]"
+	return r
+}
+
+func mismatch(path []string, value interface{}, t reflect.Type) error {
+	return fmt.Errorf("%w: path=[%s] value=[%s] field=[%s]", basicapi.ErrFieldTypeMismatch, strings.Join(path, "/"), reflect.TypeOf(value), t)
+}
+
+// Decode fills the struct pointed by v with the object of the path in the Model
+func Decode(m basicapi.Model, path []string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("Decode requires non-nil pointer of struct")
+	}
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	return decodeStruct(m, path, rv)
+}
+
+func decodeStruct(m basicapi.Model, path []string, rv reflect.Value) error {
+	specs, err := structSpecs(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		if err := decodeValue(m, joinPath(path, spec.path), rv.Field(spec.index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeValue(m basicapi.Model, path []string, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Pointer:
+		dataType, err := m.FieldType(path)
+		if err != nil {
+			return err
+		}
+		if dataType == basicapi.DataTypeUnavailable {
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return decodeValue(m, path, rv.Elem())
+	case reflect.Struct:
+		return decodeStruct(m, path, rv)
+	case reflect.Slice:
+		dataType, err := m.FieldType(path)
+		if err != nil {
+			return err
+		}
+		if dataType == basicapi.DataTypeUnavailable {
+			return nil
+		}
+		if dataType != basicapi.DataTypeArray {
+			return fmt.Errorf("%w: path=[%s] should be array", basicapi.ErrFieldTypeMismatch, strings.Join(path, "/"))
+		}
+		length, err := m.ArrayLength(path)
+		if err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(rv.Type(), length, length)
+		for i := 0; i < length; i++ {
+			if err := decodeValue(m, elementPath(path, i), slice.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(slice)
+		return nil
+	default:
+		value, err := m.GetField(path)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return nil
+		}
+		return setPrimitive(path, rv, value)
+	}
+}
+
+func setPrimitive(path []string, rv reflect.Value, value interface{}) error {
+	switch rv.Kind() {
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			rv.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			rv.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := value.(int64); ok {
+			if rv.OverflowInt(i) {
+				return fmt.Errorf("%w: path=[%s] value=[%d] overflows field=[%s]", basicapi.ErrFieldTypeMismatch, strings.Join(path, "/"), i, rv.Type())
+			}
+			rv.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i, ok := value.(int64); ok {
+			if i < 0 || rv.OverflowUint(uint64(i)) {
+				return fmt.Errorf("%w: path=[%s] value=[%d] overflows field=[%s]", basicapi.ErrFieldTypeMismatch, strings.Join(path, "/"), i, rv.Type())
+			}
+			rv.SetUint(uint64(i))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch f := value.(type) {
+		case float64:
+			rv.SetFloat(f)
+			return nil
+		case int64:
+			rv.SetFloat(float64(f))
+			return nil
+		}
+	}
+	return mismatch(path, value, rv.Type())
+}
+
+// Encode writes fields of the struct(or pointer of struct) v into the object of the path in the Model
+// Slices replace existing arrays. Nil pointers and empty values with omitempty are skipped.
+func Encode(m basicapi.Model, path []string, v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	return encodeStruct(m, path, rv)
+}
+
+func encodeStruct(m basicapi.Model, path []string, rv reflect.Value) error {
+	specs, err := structSpecs(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		field := rv.Field(spec.index)
+		if spec.omitEmpty && field.IsZero() {
+			continue
+		}
+		if err := encodeValue(m, joinPath(path, spec.path), field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeValue(m basicapi.Model, path []string, rv reflect.Value) error {
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+		return encodeValue(m, path, rv.Elem())
+	case reflect.Struct:
+		return encodeStruct(m, path, rv)
+	case reflect.Slice:
+		if err := m.DeleteField(path); err != nil {
+			return err
+		}
+		elemType := rv.Type().Elem()
+		for elemType.Kind() == reflect.Pointer {
+			elemType = elemType.Elem()
+		}
+		for i := 0; i < rv.Len(); i++ {
+			elem := rv.Index(i)
+			if elemType.Kind() == reflect.Struct {
+				idx, err := m.AppendArrayElement(path, nil)
+				if err != nil {
+					return err
+				}
+				if err := encodeValue(m, elementPath(path, idx), elem); err != nil {
+					return err
+				}
+				continue
+			}
+			for elem.Kind() == reflect.Pointer {
+				if elem.IsNil() {
+					return errors.New(fmt.Sprintf("path=[%s] nil element of primitive array", strings.Join(path, "/")))
+				}
+				elem = elem.Elem()
+			}
+			value, err := primitiveOf(path, elem)
+			if err != nil {
+				return err
+			}
+			if _, err := m.AppendArrayElement(path, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		value, err := primitiveOf(path, rv)
+		if err != nil {
+			return err
+		}
+		return m.AddOrUpdateField0(path, value)
+	}
+}
+
+func primitiveOf(path []string, rv reflect.Value) (interface{}, error) {
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return nil, errors.New(fmt.Sprintf("path=[%s] value overflows int64", strings.Join(path, "/")))
+		}
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("path=[%s] unsupported value type:%s", strings.Join(path, "/"), rv.Type()))
+	}
+}
@@ -0,0 +1,89 @@
+package modelbind
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimcore/modelinst"
+)
+
+type post struct {
+	Id    int64  `fim:"id"`
+	Title string `fim:"title,omitempty"`
+}
+
+type user struct {
+	Name   string   `fim:"name"`
+	Age    int      `fim:"profile/age"`
+	Score  float64  `fim:"profile/score"`
+	Active *bool    `fim:"active"`
+	Tags   []string `fim:"tags"`
+	Posts  []post   `fim:"posts"`
+	Parent *user    `fim:"parent"`
+	Ignore string
+}
+
+type greeting struct {
+	Message string `fim:"message"`
+}
+
+func TestEncodeAndDecode(t *testing.T) {
+	m := modelinst.ModelInstHelper{}.NewInst()
+	active := true
+	in := user{
+		Name:   "alice",
+		Age:    18,
+		Score:  9.5,
+		Active: &active,
+		Tags:   []string{"a", "b"},
+		Posts:  []post{{Id: 1, Title: "t"}, {Id: 2}},
+		Parent: &user{Name: "bob"},
+	}
+	if err := Encode(m, []string{"user"}, &in); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.GetFieldUnsafe0([]string{"user", "profile", "age"}); v != int64(18) {
+		t.Fatal("unexpected age:", v)
+	}
+	if v := m.GetFieldUnsafe0([]string{"user", "posts[1]", "title"}); v != nil {
+		t.Fatal("omitempty field should not be written:", v)
+	}
+
+	var out user
+	if err := Decode(m, []string{"user"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "alice" || out.Age != 18 || out.Score != 9.5 || out.Active == nil || !*out.Active ||
+		len(out.Tags) != 2 || out.Tags[1] != "b" || len(out.Posts) != 2 || out.Posts[0].Title != "t" ||
+		out.Parent == nil || out.Parent.Name != "bob" || out.Parent.Parent != nil {
+		t.Fatal("unexpected result:", out)
+	}
+
+	if err := m.AddOrUpdateField0([]string{"user", "profile", "age"}, "18"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Decode(m, []string{"user"}, &out); !errors.Is(err, basicapi.ErrFieldTypeMismatch) {
+		t.Fatal("type mismatch expected:", err)
+	}
+}
+
+func TestFnGen(t *testing.T) {
+	gen := FnGen(func(in *user) (*greeting, error) {
+		return &greeting{Message: "hello " + in.Name}, nil
+	})
+	fn, err := gen([]interface{}{"user", "result"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := modelinst.ModelInstHelper{}.NewInst()
+	if err := m.AddOrUpdateField0([]string{"user", "name"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fn(m); err != nil {
+		t.Fatal(err)
+	}
+	if v := m.GetFieldUnsafe0([]string{"result", "message"}); v != "hello alice" {
+		t.Fatal("unexpected result:", v)
+	}
+}
@@ -0,0 +1,57 @@
+package modelpatch
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+)
+
+// Diff computes the JSON Patch which transforms src into dst
+// Arrays with different length are replaced as a whole
+// Values of different types are replaced even if they are numerically equal, e.g. int64(1) and float64(1)
+func Diff(src, dst basicapi.Model) (Patch, error) {
+	var patch Patch
+	diffValue("", src.ToGeneralObject(), dst.ToGeneralObject(), &patch)
+	return patch, nil
+}
+
+func diffValue(pointer string, src, dst interface{}, patch *Patch) {
+	switch sv := src.(type) {
+	case map[string]interface{}:
+		dv, ok := dst.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range sortedKeys(sv) {
+			child := pointer + "/" + escapePointerToken(key)
+			if dstElem, ok := dv[key]; !ok || dstElem == nil {
+				if sv[key] != nil {
+					*patch = append(*patch, Operation{Op: OpRemove, Path: child})
+				}
+			} else if sv[key] == nil {
+				*patch = append(*patch, Operation{Op: OpAdd, Path: child, Value: dstElem})
+			} else {
+				diffValue(child, sv[key], dstElem, patch)
+			}
+		}
+		for _, key := range sortedKeys(dv) {
+			if _, ok := sv[key]; !ok && dv[key] != nil {
+				*patch = append(*patch, Operation{Op: OpAdd, Path: pointer + "/" + escapePointerToken(key), Value: dv[key]})
+			}
+		}
+		return
+	case []interface{}:
+		dv, ok := dst.([]interface{})
+		if !ok || len(sv) != len(dv) {
+			break
+		}
+		for i := range sv {
+			diffValue(fmt.Sprint(pointer, "/", i), sv[i], dv[i], patch)
+		}
+		return
+	}
+	if reflect.TypeOf(src) != reflect.TypeOf(dst) || !equalValue(src, dst) {
+		*patch = append(*patch, Operation{Op: OpReplace, Path: pointer, Value: dst})
+	}
+}
@@ -0,0 +1,195 @@
+package modelpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+)
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+// ErrPatchTestFailed is returned(wrapped) when a test operation fails
+var ErrPatchTestFailed = errors.New("patch test operation failed")
+
+// Operation is an operation of JSON Patch(RFC 6902)
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// Patch is a JSON Patch(RFC 6902) document
+type Patch []Operation
+
+// DecodePatch decodes JSON Patch document
+func DecodePatch(data []byte) (Patch, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var patch Patch
+	if err := decoder.Decode(&patch); err != nil {
+		return nil, err
+	}
+	for i := range patch {
+		v, err := normalizeValue(patch[i].Value)
+		if err != nil {
+			return nil, err
+		}
+		patch[i].Value = v
+	}
+	return patch, nil
+}
+
+func (p Patch) Encode() ([]byte, error) {
+	if p == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(p)
+}
+
+// Apply applies operations in order
+// Note: Apply is not atomic. The Model may be partially modified when an error is returned.
+// Apply the patch on a copy(Model.CopySubModel) if atomicity is required.
+func (p Patch) Apply(m basicapi.Model) error {
+	for i, op := range p {
+		if err := op.apply(m); err != nil {
+			return fmt.Errorf("apply patch operation[%d] op=[%s] path=[%s] failed: %w", i, op.Op, op.Path, err)
+		}
+	}
+	return nil
+}
+
+func (o Operation) apply(m basicapi.Model) error {
+	switch o.Op {
+	case OpAdd:
+		return add(m, o.Path, o.Value)
+	case OpRemove:
+		_, err := remove(m, o.Path)
+		return err
+	case OpReplace:
+		if o.Path == "" {
+			return add(m, o.Path, o.Value)
+		}
+		if _, err := remove(m, o.Path); err != nil {
+			return err
+		}
+		return add(m, o.Path, o.Value)
+	case OpMove:
+		if o.Path == o.From {
+			return nil
+		}
+		if len(o.Path) > len(o.From) && o.Path[:len(o.From)] == o.From && o.Path[len(o.From)] == '/' {
+			return errors.New("cannot move to its children")
+		}
+		value, err := remove(m, o.From)
+		if err != nil {
+			return err
+		}
+		return add(m, o.Path, value)
+	case OpCopy:
+		loc, err := resolvePointer(m, o.From)
+		if err != nil {
+			return err
+		}
+		value, err := existingValue(m, loc)
+		if err != nil {
+			return err
+		}
+		return add(m, o.Path, value)
+	case OpTest:
+		loc, err := resolvePointer(m, o.Path)
+		if err != nil {
+			return err
+		}
+		value, err := existingValue(m, loc)
+		if err != nil {
+			return err
+		}
+		if !equalValue(value, o.Value) {
+			return ErrPatchTestFailed
+		}
+		return nil
+	default:
+		return errors.New("unknown patch operation:" + o.Op)
+	}
+}
+
+func existingValue(m basicapi.Model, loc location) (interface{}, error) {
+	if loc.parentType == basicapi.DataTypeArray && loc.index < 0 {
+		return nil, errors.New("'-' refers to nonexistent element")
+	}
+	value, err := getValue(m, loc.path())
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("%w: json pointer=[%s]", basicapi.ErrFieldNotFound, loc.pointer)
+	}
+	return value, nil
+}
+
+func add(m basicapi.Model, pointer string, value interface{}) error {
+	if value == nil {
+		return errors.New("null value is not supported")
+	}
+	loc, err := resolvePointer(m, pointer)
+	if err != nil {
+		return err
+	}
+	if loc.root {
+		if _, ok := value.(map[string]interface{}); !ok {
+			return errors.New("root of Model should be object")
+		}
+		return replaceValue(m, nil, value)
+	}
+	if loc.parentType == basicapi.DataTypeObject {
+		return replaceValue(m, loc.path(), value)
+	}
+	length, err := m.ArrayLength(loc.parent)
+	if err != nil {
+		return err
+	}
+	if loc.index < 0 || loc.index == length {
+		return appendValue(m, loc.parent, value)
+	}
+	if loc.index > length {
+		return errors.New("array index out of bounds")
+	}
+	// insert: rebuild the array since Model only supports appending
+	arr, err := getValue(m, loc.parent)
+	if err != nil {
+		return err
+	}
+	elements := arr.([]interface{})
+	newElements := make([]interface{}, 0, len(elements)+1)
+	newElements = append(newElements, elements[:loc.index]...)
+	newElements = append(newElements, value)
+	newElements = append(newElements, elements[loc.index:]...)
+	return replaceValue(m, loc.parent, newElements)
+}
+
+// remove removes the value and returns the removed value
+func remove(m basicapi.Model, pointer string) (interface{}, error) {
+	loc, err := resolvePointer(m, pointer)
+	if err != nil {
+		return nil, err
+	}
+	if loc.root {
+		return nil, errors.New("cannot remove root")
+	}
+	value, err := existingValue(m, loc)
+	if err != nil {
+		return nil, err
+	}
+	return value, m.DeleteField(loc.path())
+}
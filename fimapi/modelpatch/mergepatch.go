@@ -0,0 +1,81 @@
+package modelpatch
+
+import (
+	"errors"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+)
+
+// ApplyMergePatch applies JSON Merge Patch(RFC 7396) document to the Model
+// The document should be an object since the root of Model is always object
+func ApplyMergePatch(m basicapi.Model, data []byte) error {
+	val, err := decodeJson(data)
+	if err != nil {
+		return err
+	}
+	patch, ok := val.(map[string]interface{})
+	if !ok {
+		return errors.New("merge patch document should be object")
+	}
+	return mergeObject(m, nil, patch)
+}
+
+func mergeObject(m basicapi.Model, path []string, patch map[string]interface{}) error {
+	for _, key := range sortedKeys(patch) {
+		field := childPath(path, key)
+		switch v := patch[key].(type) {
+		case nil:
+			if err := m.DeleteField(field); err != nil {
+				return err
+			}
+		case map[string]interface{}:
+			dataType, err := m.FieldType(field)
+			if err != nil {
+				return err
+			}
+			if dataType != basicapi.DataTypeObject && dataType != basicapi.DataTypeUnavailable {
+				if err := m.DeleteField(field); err != nil {
+					return err
+				}
+			}
+			if err := mergeObject(m, field, v); err != nil {
+				return err
+			}
+		default:
+			if err := replaceValue(m, field, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MergePatchGeneralObject applies JSON Merge Patch(RFC 7396) document to the general object(see Model.ToGeneralObject)
+// The target is not modified, unchanged values are shared by the result
+func MergePatchGeneralObject(target interface{}, data []byte) (interface{}, error) {
+	patch, err := decodeJson(data)
+	if err != nil {
+		return nil, err
+	}
+	return mergeValue(target, patch), nil
+}
+
+func mergeValue(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, _ := target.(map[string]interface{})
+	r := make(map[string]interface{}, len(t)+len(p))
+	for key, val := range t {
+		r[key] = val
+	}
+	for key, val := range p {
+		if val == nil {
+			delete(r, key)
+			continue
+		}
+		r[key] = mergeValue(r[key], val)
+	}
+	return r
+}
@@ -0,0 +1,313 @@
+package modelpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+)
+
+// Values of Models and patches are general objects: map[string]interface{}, []interface{} and primitives
+// Numbers in JSON documents are decoded as int64 or float64(with '.', 'e' or 'E') to match types of Model
+// Empty objects cannot be represented by Model, so they are not created when applying patches
+
+func decodeJson(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var val interface{}
+	if err := decoder.Decode(&val); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, errors.New("unexpected trailing data of json document")
+	}
+	return normalizeValue(val)
+}
+
+func normalizeValue(val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return v.Float64()
+		}
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case map[string]interface{}:
+		for key, elem := range v {
+			n, err := normalizeValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = n
+		}
+		return v, nil
+	case []interface{}:
+		for i, elem := range v {
+			n, err := normalizeValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = n
+		}
+		return v, nil
+	case nil, string, bool, int64, float64:
+		return v, nil
+	default:
+		return basicapi.ConvertPrimitive(v)
+	}
+}
+
+// equalValue compares general objects. Numbers are compared by value.
+func equalValue(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, elem := range av {
+			other, ok := bv[key]
+			if !ok || !equalValue(elem, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equalValue(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case int64:
+		switch bv := b.(type) {
+		case int64:
+			return av == bv
+		case float64:
+			return float64(av) == bv
+		}
+		return false
+	case float64:
+		switch bv := b.(type) {
+		case int64:
+			return av == float64(bv)
+		case float64:
+			return av == bv || (math.IsNaN(av) && math.IsNaN(bv))
+		}
+		return false
+	default:
+		return a == b
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func childPath(path []string, name string) []string {
+	r := make([]string, len(path)+1)
+	copy(r, path)
+	r[len(path)] = name
+	return r
+}
+
+func elementPath(path []string, idx int) []string {
+	r := make([]string, len(path))
+	copy(r, path)
+	r[len(r)-1] = fmt.Sprint(r[len(r)-1], "[", idx, "]")
+	return r
+}
+
+// getValue returns general object of the path, nil if not exists
+func getValue(m basicapi.Model, path []string) (interface{}, error) {
+	dataType, err := m.FieldType(path)
+	if err != nil {
+		return nil, err
+	}
+	switch dataType {
+	case basicapi.DataTypeUnavailable:
+		return nil, nil
+	case basicapi.DataTypeObject:
+		sub, err := m.CopySubModel(path)
+		if err != nil {
+			return nil, err
+		}
+		return sub.ToGeneralObject(), nil
+	case basicapi.DataTypeArray:
+		length, err := m.ArrayLength(path)
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, length)
+		for i := range arr {
+			if arr[i], err = getValue(m, elementPath(path, i)); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	default:
+		return m.GetField(path)
+	}
+}
+
+// setValue sets value to the path. Array elements are appended to the existing array.
+func setValue(m basicapi.Model, path []string, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			if err := setValue(m, childPath(path, key), v[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		for _, elem := range v {
+			if err := appendValue(m, path, elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if len(path) == 0 {
+			return errors.New("root of Model should be object")
+		}
+		return m.AddOrUpdateField0(path, v)
+	}
+}
+
+func appendValue(m basicapi.Model, path []string, elem interface{}) error {
+	switch v := elem.(type) {
+	case nil:
+		return errors.New("null array element is not supported")
+	case []interface{}:
+		return errors.New("nested array is not supported")
+	case map[string]interface{}:
+		idx, err := m.AppendArrayElement(path, nil)
+		if err != nil {
+			return err
+		}
+		return setValue(m, elementPath(path, idx), v)
+	default:
+		_, err := m.AppendArrayElement(path, v)
+		return err
+	}
+}
+
+// replaceValue deletes the path and sets the value
+func replaceValue(m basicapi.Model, path []string, value interface{}) error {
+	if len(path) == 0 {
+		keys, err := m.Keys(nil)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := m.DeleteField([]string{key}); err != nil {
+				return err
+			}
+		}
+	} else if err := m.DeleteField(path); err != nil {
+		return err
+	}
+	return setValue(m, path, value)
+}
+
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func unescapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
+
+// location is the resolved JSON Pointer(RFC 6901) on a Model
+type location struct {
+	pointer string
+	// parent is the path of the container, nil for the root
+	parent     []string
+	parentType basicapi.DataType
+	// name is the member name when parent is object
+	name string
+	// index is the element index when parent is array, -1 for the end of the array('-')
+	index int
+	root  bool
+}
+
+func (l location) path() []string {
+	if l.root {
+		return nil
+	}
+	if l.parentType == basicapi.DataTypeArray {
+		return elementPath(l.parent, l.index)
+	}
+	return childPath(l.parent, l.name)
+}
+
+func resolvePointer(m basicapi.Model, pointer string) (location, error) {
+	loc := location{pointer: pointer}
+	if pointer == "" {
+		loc.root = true
+		return loc, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return loc, errors.New("invalid json pointer:" + pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	var path []string
+	currentType := basicapi.DataTypeObject
+	for i, token := range tokens {
+		token = unescapePointerToken(token)
+		last := i == len(tokens)-1
+		switch currentType {
+		case basicapi.DataTypeObject:
+			if strings.ContainsAny(token, "[]") {
+				return loc, errors.New("unsupported field name in json pointer:" + pointer)
+			}
+			if last {
+				loc.parent, loc.parentType, loc.name = path, currentType, token
+				return loc, nil
+			}
+			path = childPath(path, token)
+		case basicapi.DataTypeArray:
+			idx := -1
+			if token != "-" || !last {
+				n, err := strconv.Atoi(token)
+				if err != nil || n < 0 || (len(token) > 1 && token[0] == '0') {
+					return loc, errors.New("invalid array index in json pointer:" + pointer)
+				}
+				idx = n
+			}
+			if last {
+				loc.parent, loc.parentType, loc.index = path, currentType, idx
+				return loc, nil
+			}
+			path = elementPath(path, idx)
+		default:
+			return loc, fmt.Errorf("%w: json pointer=[%s]", basicapi.ErrFieldNotFound, pointer)
+		}
+		dataType, err := m.FieldType(path)
+		if err != nil {
+			return loc, err
+		}
+		currentType = dataType
+	}
+	return loc, nil
+}
@@ -0,0 +1,117 @@
+package modelpatch
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimcore/modelinst"
+)
+
+func newModel(t *testing.T, data string) basicapi.Model {
+	m := modelinst.ModelInstHelper{}.NewInst()
+	obj, err := decodeJson([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := setValue(m, nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestDiffAndApply(t *testing.T) {
+	src := newModel(t, `{"user":{"name":"alice","age":18,"tags":["a","b"]},"posts":[{"id":1},{"id":2}],"removed":true}`)
+	dst := newModel(t, `{"user":{"name":"bob","age":18,"tags":["a","b","c"]},"posts":[{"id":1},{"id":3,"title":"t"}],"added":1.5}`)
+	patch, err := Diff(src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := patch.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodePatch(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := decoded.Apply(src); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(src.ToGeneralObject(), dst.ToGeneralObject()) {
+		t.Fatal("models mismatch after applying diff:", string(data))
+	}
+	if patch, err := Diff(src, dst); err != nil || len(patch) != 0 {
+		t.Fatal("diff of same models should be empty:", patch, err)
+	}
+
+	// values of different types are replaced
+	patch, err = Diff(newModel(t, `{"count":1,"price":2.0}`), newModel(t, `{"count":1.0,"price":2.0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 || patch[0].Op != OpReplace || patch[0].Path != "/count" || patch[0].Value != float64(1) {
+		t.Fatal("int and float should be different:", patch)
+	}
+}
+
+func TestJsonPatch(t *testing.T) {
+	m := newModel(t, `{"user":{"name":"alice","tags":["a","c"]}}`)
+	patch, err := DecodePatch([]byte(`[
+		{"op":"add","path":"/user/tags/1","value":"b"},
+		{"op":"add","path":"/user/tags/-","value":"d"},
+		{"op":"test","path":"/user/name","value":"alice"},
+		{"op":"copy","from":"/user/name","path":"/owner"},
+		{"op":"move","from":"/user/tags","path":"/tags"},
+		{"op":"replace","path":"/user/name","value":{"first":"alice"}},
+		{"op":"remove","path":"/tags/0"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := patch.Apply(m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"user":  map[string]interface{}{"name": map[string]interface{}{"first": "alice"}},
+		"owner": "alice",
+		"tags":  []interface{}{"b", "c", "d"},
+	}
+	if !reflect.DeepEqual(m.ToGeneralObject(), expected) {
+		t.Fatal("unexpected model:", m.ToGeneralObject())
+	}
+	err = Patch{{Op: OpTest, Path: "/owner", Value: "bob"}}.Apply(m)
+	if !errors.Is(err, ErrPatchTestFailed) {
+		t.Fatal("test operation should fail:", err)
+	}
+	if err := (Patch{{Op: OpRemove, Path: "/missing"}}).Apply(m); !errors.Is(err, basicapi.ErrFieldNotFound) {
+		t.Fatal("remove missing field should fail:", err)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	m := newModel(t, `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`)
+	if err := ApplyMergePatch(m, []byte(`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	expected := newModel(t, `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`)
+	if !reflect.DeepEqual(m.ToGeneralObject(), expected.ToGeneralObject()) {
+		t.Fatal("unexpected model:", m.ToGeneralObject())
+	}
+}
+
+func TestMergePatchGeneralObject(t *testing.T) {
+	target := map[string]interface{}{"name": "alice", "address": map[string]interface{}{"city": "a", "zip": "1"}}
+	r, err := MergePatchGeneralObject(target, []byte(`{"name":null,"address":{"zip":null,"street":"s"},"age":18}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"address": map[string]interface{}{"city": "a", "street": "s"}, "age": int64(18)}
+	if !reflect.DeepEqual(r, expected) {
+		t.Fatal("unexpected result:", r)
+	}
+	if target["name"] != "alice" || len(target["address"].(map[string]interface{})) != 2 {
+		t.Fatal("target should not be modified:", target)
+	}
+}
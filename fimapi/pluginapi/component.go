@@ -5,3 +5,27 @@ import "github.com/FimGroup/fim/fimapi/basicapi"
 type Fn = basicapi.Fn
 
 type FnGen = basicapi.FnGen
+
+type PathTypeResolver = basicapi.PathTypeResolver
+
+type TypedFnGen = basicapi.TypedFnGen
+
+type PreOutOperation = basicapi.PreOutOperation
+
+type PreOutOperationGen = basicapi.PreOutOperationGen
+
+type ParamKind = basicapi.ParamKind
+
+const (
+	ParamKindPath   = basicapi.ParamKindPath
+	ParamKindString = basicapi.ParamKindString
+	ParamKindInt    = basicapi.ParamKindInt
+	ParamKindNumber = basicapi.ParamKindNumber
+	ParamKindBool   = basicapi.ParamKindBool
+	ParamKindArray  = basicapi.ParamKindArray
+	ParamKindAny    = basicapi.ParamKindAny
+)
+
+type ParamSchema = basicapi.ParamSchema
+
+type FnSchema = basicapi.FnSchema
@@ -4,16 +4,16 @@ import (
 	"github.com/FimGroup/fim/fimapi/basicapi"
 )
 
-type DataType int
+type DataType = basicapi.DataType
 
 const (
-	DataTypeUnavailable DataType = 0
-	DataTypeInt         DataType = 1
-	DataTypeString      DataType = 2
-	DataTypeBool        DataType = 3
-	DataTypeFloat       DataType = 4
-	DataTypeArray       DataType = 51
-	DataTypeObject      DataType = 52
+	DataTypeUnavailable = basicapi.DataTypeUnavailable
+	DataTypeInt         = basicapi.DataTypeInt
+	DataTypeString      = basicapi.DataTypeString
+	DataTypeBool        = basicapi.DataTypeBool
+	DataTypeFloat       = basicapi.DataTypeFloat
+	DataTypeArray       = basicapi.DataTypeArray
+	DataTypeObject      = basicapi.DataTypeObject
 )
 
 const (
@@ -30,19 +30,61 @@ const (
 )
 
 type Model = basicapi.Model
+type FieldAccessor = basicapi.FieldAccessor
+
+func NewFieldAccessor(path []string) *FieldAccessor {
+	return basicapi.NewFieldAccessor(path)
+}
+
 type ModelCopy interface {
 	Transfer(dst Model) error
 }
 type ModelEncoding interface {
 	ToToml() ([]byte, error)
 	FromToml([]byte) error
+	Encode(codec ModelCodec) ([]byte, error)
+	Decode(codec ModelCodec, data []byte) error
+}
+
+// ModelReadonly is implemented by readonly Models, e.g. Models from Container.WrapReadonlyModelFromMap
+// Modifications on readonly Models return ErrModelReadonly
+type ModelReadonly interface {
+	// CopyOnWrite returns a writable Model which copies the readonly data on the first modification
+	CopyOnWrite() Model
+}
+
+// ModelCodec encodes and decodes the general object of a Model(see Model.ToGeneralObject)
+// Decoded object should only contain map[string]interface{}, []interface{} and primitive values(int64/float64/string/bool)
+type ModelCodec interface {
+	Name() string
+	Marshal(obj interface{}) ([]byte, error)
+	Unmarshal(data []byte) (interface{}, error)
 }
 
 type Container interface {
 	RegisterBuiltinFn(name string, fnGen FnGen) error
 	RegisterCustomFn(name string, fnGen FnGen) error
+	// RegisterBuiltinTypedFn registers builtin function which requires data types of the Model, e.g. to check decoded data
+	RegisterBuiltinTypedFn(name string, fnGen TypedFnGen) error
+	// RegisterBuiltinFnWithSchema registers builtin function whose parameters are validated against the schema when flows are loaded
+	RegisterBuiltinFnWithSchema(name string, schema FnSchema, fnGen FnGen) error
+	// RegisterBuiltinTypedFnWithSchema registers typed builtin function(see RegisterBuiltinTypedFn) with parameter schema
+	RegisterBuiltinTypedFnWithSchema(name string, schema FnSchema, fnGen TypedFnGen) error
+	// RegisterCustomFnWithSchema registers custom function whose parameters are validated against the schema when flows are loaded
+	RegisterCustomFnWithSchema(name string, schema FnSchema, fnGen FnGen) error
+	// FnReferenceDoc generates markdown reference of functions registered with schemas
+	FnReferenceDoc() string
+	// RegisterPreOutOperation registers operation which can be used in pre_out and post_out of flows, name should have @ as prefix
+	RegisterPreOutOperation(name string, gen PreOutOperationGen) error
+	// ConfigureManager resolves configure placeholders of the container, e.g. keys used by functions
+	ConfigureManager() basicapi.ConfigureManager
+	// GetFileResourceManager returns file resource manager of the application, nil if not found
+	GetFileResourceManager(name string) FileResourceManager
 
 	NewModel() Model
+	// ReleaseModel returns Model created by NewModel when it is no longer used, e.g. after responding the request
+	ReleaseModel(m Model)
+	// WrapReadonlyModelFromMap wraps a deep copy of the map as readonly Model
 	WrapReadonlyModelFromMap(map[string]interface{}) (Model, error)
 
 	LoadFlowModel(tomlContent string) error
@@ -61,6 +103,13 @@ type MappingDefinition struct {
 	ReqArgPaths  []string
 	ResConverter func(src, dst Model) error
 	ResArgPaths  []string
+	// ReqConnectorPaths and ResConnectorPaths are leaf paths on the connector side of the mappings
+	// Connectors may use them as hints, e.g. to decide arrays when decoding xml
+	ReqConnectorPaths []string
+	ResConnectorPaths []string
+	// ReqConnectorTypes are primitive data types of ReqConnectorPaths resolved from the mapped FlowModel paths
+	// Connectors may use them to convert untyped values, e.g. text of xml
+	ReqConnectorTypes map[string]DataType
 }
 
 type Connector interface {
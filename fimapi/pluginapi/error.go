@@ -1,16 +1,43 @@
 package pluginapi
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+)
+
+const (
+	FlowErrorKeyValidationFailed = "validation_failed"
+	FlowErrorKeyDivisionByZero   = "division_by_zero"
+	FlowErrorKeyNumericOverflow  = "numeric_overflow"
+)
+
+// ErrModelReadonly is returned(wrapped) when modifying a readonly Model
+var ErrModelReadonly = errors.New("model is readonly")
+
+var (
+	ErrFieldNotFound     = basicapi.ErrFieldNotFound
+	ErrFieldTypeMismatch = basicapi.ErrFieldTypeMismatch
+)
 
 type FlowError struct {
 	Key     string
 	Message string
+	// Details contains per-field information, e.g. field constraint violations
+	Details []FieldErrorDetail `json:",omitempty"`
 }
 
 func (f FlowError) Error() string {
 	return fmt.Sprint(f.Key, "::", f.Message)
 }
 
+type FieldErrorDetail struct {
+	Path    string
+	Rule    string
+	Message string
+}
+
 type FlowStop struct {
 	Key     string
 	Message string
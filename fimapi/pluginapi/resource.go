@@ -1,5 +1,8 @@
 package pluginapi
 
+// FileResourcePrefix is the prefix of file placeholders, format: resource-file://<file resource manager name>/<path>
+const FileResourcePrefix = "resource-file://"
+
 // FileResourceManager defines file resource accessing provider
 // Note: more functionality may be provided including:
 //   - http.FileSystem
@@ -3,6 +3,7 @@ package rule
 import (
 	"strings"
 
+	"github.com/FimGroup/fim/fimapi/basicapi"
 	"github.com/FimGroup/fim/fimapi/pluginapi"
 )
 
@@ -54,6 +55,18 @@ func ValidateFullPathOfDefinition(in string) bool {
 	return true
 }
 
+// ValidateQuery validates query of fields, see basicapi.Query for the syntax
+func ValidateQuery(in string) bool {
+	_, err := basicapi.ParseQuery(in)
+	return err == nil
+}
+
+// IsQuery returns true if the path contains wildcards or predicates
+// Note: ExtractArrayPath and other path functions only support concrete paths
+func IsQuery(in string) bool {
+	return basicapi.IsQuery(in)
+}
+
 func SplitFullPath(in string) []string {
 	return strings.Split(in, pluginapi.PathSeparator)
 }
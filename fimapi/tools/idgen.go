@@ -0,0 +1,255 @@
+package tools
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// Time-ordered ID generators
+// Generators are safe for concurrent use and IDs of the same generator are increasing unless specified
+
+// IdGenerator generates ID in string form
+type IdGenerator interface {
+	NextId() (string, error)
+}
+
+const (
+	IdAlgorithmUuidV4    = "uuidv4"
+	IdAlgorithmUuidV7    = "uuidv7"
+	IdAlgorithmUlid      = "ulid"
+	IdAlgorithmKsuid     = "ksuid"
+	IdAlgorithmSnowflake = "snowflake"
+)
+
+// NewIdGenerator creates generator of the algorithm, node is used by snowflake only
+// clock could be nil for the system clock
+func NewIdGenerator(algorithm string, node int64, clock func() time.Time) (IdGenerator, error) {
+	switch algorithm {
+	case IdAlgorithmUuidV4:
+		return uuidV4Generator{}, nil
+	case IdAlgorithmUuidV7:
+		return NewUuidV7Generator(clock), nil
+	case IdAlgorithmUlid:
+		return NewUlidGenerator(clock), nil
+	case IdAlgorithmKsuid:
+		return NewKsuidGenerator(clock), nil
+	case IdAlgorithmSnowflake:
+		return NewSnowflakeGenerator(node, clock)
+	default:
+		return nil, errors.New("unknown id algorithm:" + algorithm)
+	}
+}
+
+func systemClockIfNil(clock func() time.Time) func() time.Time {
+	if clock == nil {
+		return time.Now
+	}
+	return clock
+}
+
+type uuidV4Generator struct {
+}
+
+// NextId random UUID which is not time-ordered
+func (u uuidV4Generator) NextId() (string, error) {
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// UuidV7Generator generates UUID version 7(RFC 9562): unix milliseconds and random bits
+type UuidV7Generator struct {
+	gen *uuid.Gen
+}
+
+func NewUuidV7Generator(clock func() time.Time) *UuidV7Generator {
+	clock = systemClockIfNil(clock)
+	return &UuidV7Generator{
+		gen: uuid.NewGenWithOptions(uuid.WithEpochFunc(func() time.Time {
+			return clock()
+		})),
+	}
+}
+
+func (u *UuidV7Generator) NextId() (string, error) {
+	id, err := u.gen.NewV7()
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// encodeBase encodes big-endian bytes in the alphabet with fixed length, leading digits are padded with zero
+func encodeBase(data []byte, alphabet string, length int) string {
+	n := new(big.Int).SetBytes(data)
+	base := big.NewInt(int64(len(alphabet)))
+	mod := new(big.Int)
+	r := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		n.DivMod(n, base, mod)
+		r[i] = alphabet[mod.Int64()]
+	}
+	return string(r)
+}
+
+// UlidGenerator generates ULID: 48 bits unix milliseconds and 80 bits random in 26 characters of Crockford's base32
+// Random bits are incremented for IDs in the same millisecond so that IDs are increasing
+type UlidGenerator struct {
+	clock func() time.Time
+
+	lock       sync.Mutex
+	lastMillis int64
+	lastRandom [10]byte
+}
+
+func NewUlidGenerator(clock func() time.Time) *UlidGenerator {
+	return &UlidGenerator{
+		clock: systemClockIfNil(clock),
+	}
+}
+
+func (u *UlidGenerator) NextId() (string, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	millis := u.clock().UnixMilli()
+	if millis <= u.lastMillis {
+		// same millisecond or clock rollback: keep increasing from the last ID
+		millis = u.lastMillis
+		i := len(u.lastRandom) - 1
+		for ; i >= 0; i-- {
+			u.lastRandom[i]++
+			if u.lastRandom[i] != 0 {
+				break
+			}
+		}
+		if i < 0 {
+			return "", errors.New("ulid random bits overflow in the same millisecond")
+		}
+	} else if _, err := rand.Read(u.lastRandom[:]); err != nil {
+		return "", err
+	}
+	u.lastMillis = millis
+
+	var data [16]byte
+	for i := 0; i < 6; i++ {
+		data[i] = byte(millis >> (8 * (5 - i)))
+	}
+	copy(data[6:], u.lastRandom[:])
+	return encodeBase(data[:], crockfordBase32, 26), nil
+}
+
+// ksuidEpoch is the epoch of KSUID timestamps in unix seconds
+const ksuidEpoch = 1400000000
+
+// KsuidGenerator generates KSUID: 32 bits seconds since 2014-05-13 and 128 bits random in 27 characters of base62
+// IDs are ordered by seconds only
+type KsuidGenerator struct {
+	clock func() time.Time
+}
+
+func NewKsuidGenerator(clock func() time.Time) *KsuidGenerator {
+	return &KsuidGenerator{
+		clock: systemClockIfNil(clock),
+	}
+}
+
+func (k *KsuidGenerator) NextId() (string, error) {
+	ts := k.clock().Unix() - ksuidEpoch
+	if ts < 0 || ts > 0xFFFFFFFF {
+		return "", errors.New("ksuid timestamp out of range")
+	}
+	var data [20]byte
+	data[0], data[1], data[2], data[3] = byte(ts>>24), byte(ts>>16), byte(ts>>8), byte(ts)
+	if _, err := rand.Read(data[4:]); err != nil {
+		return "", err
+	}
+	return encodeBase(data[:], base62, 27), nil
+}
+
+const (
+	// SnowflakeEpoch is the epoch of snowflake timestamps: 2020-01-01T00:00:00Z in unix milliseconds
+	SnowflakeEpoch = 1577836800000
+	// SnowflakeMaxNode is the max node ID(10 bits)
+	SnowflakeMaxNode = 1<<10 - 1
+	// SnowflakeMaxRollback is the max clock rollback tolerated by snowflake generators
+	SnowflakeMaxRollback = 5 * time.Second
+
+	snowflakeSequenceBits = 12
+	snowflakeNodeBits     = 10
+	snowflakeMaxSequence  = 1<<snowflakeSequenceBits - 1
+)
+
+// ErrClockRollback is returned when the clock moves backwards more than SnowflakeMaxRollback
+var ErrClockRollback = errors.New("clock moved backwards")
+
+// SnowflakeGenerator generates positive int64: 41 bits milliseconds since SnowflakeEpoch, 10 bits node and 12 bits sequence
+// Node should be unique among running generators, e.g. from configuration of each instance
+// When the sequence is exhausted or the clock moves backwards within SnowflakeMaxRollback, milliseconds after
+// the last ID are used so that IDs are still increasing
+type SnowflakeGenerator struct {
+	clock func() time.Time
+	node  int64
+
+	lock       sync.Mutex
+	lastMillis int64
+	sequence   int64
+}
+
+func NewSnowflakeGenerator(node int64, clock func() time.Time) (*SnowflakeGenerator, error) {
+	if node < 0 || node > SnowflakeMaxNode {
+		return nil, errors.New(fmt.Sprintf("snowflake node should be in range [0, %d]", SnowflakeMaxNode))
+	}
+	return &SnowflakeGenerator{
+		clock:      systemClockIfNil(clock),
+		node:       node,
+		lastMillis: -1,
+	}, nil
+}
+
+func (s *SnowflakeGenerator) Next() (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	millis := s.clock().UnixMilli() - SnowflakeEpoch
+	if millis < 0 {
+		return 0, errors.New("snowflake clock is before the epoch")
+	}
+	switch {
+	case millis > s.lastMillis:
+		s.lastMillis = millis
+		s.sequence = 0
+	case s.lastMillis-millis > SnowflakeMaxRollback.Milliseconds():
+		return 0, fmt.Errorf("%w: %dms", ErrClockRollback, s.lastMillis-millis)
+	case s.sequence < snowflakeMaxSequence:
+		s.sequence++
+	default:
+		s.lastMillis++
+		s.sequence = 0
+	}
+	if s.lastMillis >= 1<<41 {
+		return 0, errors.New("snowflake timestamp out of range")
+	}
+	return s.lastMillis<<(snowflakeNodeBits+snowflakeSequenceBits) | s.node<<snowflakeSequenceBits | s.sequence, nil
+}
+
+func (s *SnowflakeGenerator) NextId() (string, error) {
+	id, err := s.Next()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(id, 10), nil
+}
@@ -0,0 +1,89 @@
+package tools
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUlidGenerator(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	g := NewUlidGenerator(func() time.Time {
+		return now
+	})
+	last := ""
+	for i := 0; i < 100; i++ {
+		id, err := g.NextId()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(id) != 26 || id <= last {
+			t.Fatal("ulid should be increasing:", last, id)
+		}
+		last = id
+	}
+	// rollback keeps increasing
+	now = now.Add(-time.Second)
+	if id, err := g.NextId(); err != nil {
+		t.Fatal(err)
+	} else if id <= last {
+		t.Fatal("ulid should be increasing after rollback:", last, id)
+	}
+}
+
+func TestKsuidGenerator(t *testing.T) {
+	g := NewKsuidGenerator(func() time.Time {
+		return time.Unix(ksuidEpoch, 0)
+	})
+	id, err := g.NextId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 27 || id[:5] != "00000" {
+		t.Fatal("ksuid of epoch should start with zeros:", id)
+	}
+}
+
+func TestSnowflakeGenerator(t *testing.T) {
+	if _, err := NewSnowflakeGenerator(SnowflakeMaxNode+1, nil); err == nil {
+		t.Fatal("node out of range should fail")
+	}
+	now := time.UnixMilli(SnowflakeEpoch + 10000)
+	g, err := NewSnowflakeGenerator(3, func() time.Time {
+		return now
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := g.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 10000<<22|3<<12 {
+		t.Fatal("unexpected id:", first)
+	}
+	// sequence overflow moves to the next millisecond
+	last := first
+	for i := 0; i < snowflakeMaxSequence+1; i++ {
+		id, err := g.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= last {
+			t.Fatal("id should be increasing:", last, id)
+		}
+		last = id
+	}
+	if last>>22 != 10001 || last&snowflakeMaxSequence != 0 {
+		t.Fatal("sequence overflow should use the next millisecond:", last)
+	}
+	// small rollback is tolerated
+	now = now.Add(-time.Second)
+	if id, err := g.Next(); err != nil || id <= last {
+		t.Fatal("small rollback should be tolerated:", id, err)
+	}
+	now = now.Add(-SnowflakeMaxRollback)
+	if _, err := g.Next(); !errors.Is(err, ErrClockRollback) {
+		t.Fatal("large rollback should fail:", err)
+	}
+}
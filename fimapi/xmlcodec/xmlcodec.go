@@ -0,0 +1,398 @@
+package xmlcodec
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+// XML is mapped onto Model following the path rules:
+//
+//	element             -> field with the local name of the element
+//	attribute           -> '#name' field of the element
+//	namespace           -> '@xmlns' field for the namespace of the element if it differs from its parent
+//	                       '@prefix' field for each prefixed namespace declaration
+//	text                -> value of the element, or '#text' field if the element has attributes or children
+//	repeated elements   -> array
+//
+// Values are decoded as strings since XML has no data types, unless data types of the paths are given
+// Empty values of non-string data types are regarded as absent
+// Element prefixes are not kept. Namespaces are written as default namespace declarations when encoding.
+
+const (
+	Name = "xml"
+
+	ContentTypeXml     = "application/xml"
+	ContentTypeTextXml = "text/xml"
+
+	TextField      = "#text"
+	NamespaceField = "@xmlns"
+
+	maxNestingLevel = 512
+)
+
+// IsXmlContentType checks content types of xml, e.g. application/xml, text/xml, application/soap+xml
+func IsXmlContentType(contentType string) bool {
+	mediaType := strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	return mediaType == ContentTypeXml || mediaType == ContentTypeTextXml || strings.HasSuffix(mediaType, "+xml")
+}
+
+// Codec implements ModelCodec for xml
+// Note: it is not registered as model wire encoding since xml requires exactly one root element and values are strings
+// ArrayPaths are definitions(e.g. order/items[]/id) to decode elements as arrays even if only one element exists
+// Types are primitive data types of definitions to convert values from text
+type Codec struct {
+	ArrayPaths []string
+	Types      map[string]basicapi.DataType
+}
+
+func (c Codec) Name() string {
+	return Name
+}
+
+func (c Codec) Marshal(obj interface{}) ([]byte, error) {
+	objMap, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("xml document should be object")
+	}
+	return Marshal(objMap)
+}
+
+func (c Codec) Unmarshal(data []byte) (interface{}, error) {
+	return UnmarshalWithTypes(data, c.ArrayPaths, c.Types)
+}
+
+// TrimPathPrefix selects paths with the prefix and removes the prefix
+// e.g. prefix=http/body/ path=http/body/order/items[]/id -> order/items[]/id
+func TrimPathPrefix(prefix string, paths []string) []string {
+	var r []string
+	for _, path := range paths {
+		if strings.HasPrefix(path, prefix) {
+			r = append(r, path[len(prefix):])
+		}
+	}
+	return r
+}
+
+// TrimTypePathPrefix selects data types of paths with the prefix and removes the prefix
+func TrimTypePathPrefix(prefix string, types map[string]basicapi.DataType) map[string]basicapi.DataType {
+	r := map[string]basicapi.DataType{}
+	for path, dt := range types {
+		if strings.HasPrefix(path, prefix) {
+			r[path[len(prefix):]] = dt
+		}
+	}
+	return r
+}
+
+// typeHints removes array levels from paths of definitions
+// e.g. order/items[]/id -> order/items/id
+func typeHints(types map[string]basicapi.DataType) map[string]basicapi.DataType {
+	hints := map[string]basicapi.DataType{}
+	for path, dt := range types {
+		levels := rule.SplitFullPath(path)
+		for i, level := range levels {
+			levels[i], _ = rule.ExtractArrayPath(level)
+		}
+		hints[rule.ConcatFullPath(levels)] = dt
+	}
+	return hints
+}
+
+// convertText converts text to the data type of the path, nil is returned for empty text of non-string data types
+func convertText(types map[string]basicapi.DataType, path, text string) (interface{}, error) {
+	dt, ok := types[path]
+	if !ok || dt == basicapi.DataTypeString {
+		return text, nil
+	}
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return nil, nil
+	}
+	var r interface{}
+	var err error
+	var typeName string
+	switch dt {
+	case basicapi.DataTypeInt:
+		r, err = strconv.ParseInt(trimmed, 10, 64)
+		typeName = "int"
+	case basicapi.DataTypeFloat:
+		r, err = strconv.ParseFloat(trimmed, 64)
+		typeName = "float"
+	case basicapi.DataTypeBool:
+		r, err = strconv.ParseBool(trimmed)
+		typeName = "bool"
+	default:
+		return text, nil
+	}
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("path=[%s] value=[%s] cannot be converted to %s", path, text, typeName))
+	}
+	return r, nil
+}
+
+// arrayHints collects paths of array levels from definitions
+// e.g. order/items[]/id -> order/items
+func arrayHints(paths []string) map[string]struct{} {
+	hints := map[string]struct{}{}
+	for _, path := range paths {
+		levels := rule.SplitFullPath(path)
+		for i, level := range levels {
+			if rule.IsPathArray(level) {
+				name, _ := rule.ExtractArrayPath(level)
+				hints[rule.ConcatFullPath(append(append([]string{}, levels[:i]...), name))] = struct{}{}
+			}
+		}
+	}
+	return hints
+}
+
+type decodeFrame struct {
+	name string
+	path string
+	ns   string
+	obj  map[string]interface{}
+	text strings.Builder
+}
+
+// Unmarshal decodes xml document to object with the root element as the only field
+func Unmarshal(data []byte, arrayPaths []string) (map[string]interface{}, error) {
+	return UnmarshalWithTypes(data, arrayPaths, nil)
+}
+
+// UnmarshalWithTypes decodes xml document and converts values to data types of the paths
+// Paths of types are definitions, e.g. order/items[]/id, order/#id
+func UnmarshalWithTypes(data []byte, arrayPaths []string, types map[string]basicapi.DataType) (map[string]interface{}, error) {
+	hints := arrayHints(arrayPaths)
+	valueTypes := typeHints(types)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	result := map[string]interface{}{}
+	var stack []*decodeFrame
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && len(result) > 0 {
+				return nil, errors.New("xml document should have exactly one root element")
+			}
+			if len(stack) >= maxNestingLevel {
+				return nil, errors.New("xml: exceed max nesting level")
+			}
+			frame := &decodeFrame{name: t.Name.Local, ns: t.Name.Space, obj: map[string]interface{}{}}
+			parentNs := ""
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parentNs = parent.ns
+				frame.path = parent.path + "/" + frame.name
+			} else {
+				frame.path = frame.name
+			}
+			if frame.ns != parentNs {
+				frame.obj[NamespaceField] = frame.ns
+			}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					// default namespace is resolved in the element name
+				case attr.Name.Space == "xmlns":
+					frame.obj["@"+attr.Name.Local] = attr.Value
+				default:
+					v, err := convertText(valueTypes, frame.path+"/#"+attr.Name.Local, attr.Value)
+					if err != nil {
+						return nil, err
+					}
+					if v != nil {
+						frame.obj["#"+attr.Name.Local] = v
+					}
+				}
+			}
+			stack = append(stack, frame)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		case xml.EndElement:
+			frame := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			var value interface{}
+			text := frame.text.String()
+			if strings.TrimSpace(text) == "" {
+				text = ""
+			}
+			if len(frame.obj) == 0 {
+				v, err := convertText(valueTypes, frame.path, text)
+				if err != nil {
+					return nil, err
+				}
+				if v == nil {
+					continue
+				}
+				value = v
+			} else {
+				if text != "" {
+					v, err := convertText(valueTypes, frame.path+"/"+TextField, text)
+					if err != nil {
+						return nil, err
+					}
+					if v != nil {
+						frame.obj[TextField] = v
+					}
+				}
+				value = frame.obj
+			}
+			parent := result
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1].obj
+			}
+			_, isArray := hints[frame.path]
+			if existing, ok := parent[frame.name]; ok {
+				if arr, ok := existing.([]interface{}); ok {
+					parent[frame.name] = append(arr, value)
+				} else {
+					parent[frame.name] = []interface{}{existing, value}
+				}
+			} else if isArray {
+				parent[frame.name] = []interface{}{value}
+			} else {
+				parent[frame.name] = value
+			}
+		}
+	}
+	if len(stack) > 0 || len(result) == 0 {
+		return nil, errors.New("incomplete xml document")
+	}
+	return result, nil
+}
+
+// Marshal encodes object with exactly one field as the root element
+func Marshal(obj map[string]interface{}) ([]byte, error) {
+	if len(obj) != 1 {
+		return nil, errors.New("xml document should have exactly one root element")
+	}
+	buf := bytes.NewBufferString(xml.Header)
+	for name, value := range obj {
+		if _, ok := value.([]interface{}); ok {
+			return nil, errors.New("root element should not be array")
+		}
+		if err := writeElement(buf, name, value, 0); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func writeElement(buf *bytes.Buffer, name string, value interface{}, level int) error {
+	if level > maxNestingLevel {
+		return errors.New("xml: exceed max nesting level")
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			if _, ok := elem.([]interface{}); ok {
+				return errors.New("nested array is not supported")
+			}
+			if err := writeElement(buf, name, elem, level+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<" + name)
+		for _, key := range keys {
+			if key == TextField || v[key] == nil {
+				continue
+			}
+			var attrName string
+			switch {
+			case key == NamespaceField:
+				attrName = "xmlns"
+			case strings.HasPrefix(key, "@"):
+				attrName = "xmlns:" + key[1:]
+			case strings.HasPrefix(key, "#"):
+				attrName = key[1:]
+			default:
+				continue
+			}
+			text, err := formatValue(v[key])
+			if err != nil {
+				return errors.New(fmt.Sprintf("attribute=[%s] %s", key, err))
+			}
+			buf.WriteString(" " + attrName + "=\"")
+			if err := xml.EscapeText(buf, []byte(text)); err != nil {
+				return err
+			}
+			buf.WriteString("\"")
+		}
+		buf.WriteString(">")
+		if text, ok := v[TextField]; ok && text != nil {
+			s, err := formatValue(text)
+			if err != nil {
+				return err
+			}
+			if err := xml.EscapeText(buf, []byte(s)); err != nil {
+				return err
+			}
+		}
+		for _, key := range keys {
+			if strings.HasPrefix(key, "#") || strings.HasPrefix(key, "@") {
+				continue
+			}
+			if err := writeElement(buf, key, v[key], level+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</" + name + ">")
+		return nil
+	case nil:
+		buf.WriteString("<" + name + "/>")
+		return nil
+	default:
+		text, err := formatValue(v)
+		if err != nil {
+			return errors.New(fmt.Sprintf("element=[%s] %s", name, err))
+		}
+		buf.WriteString("<" + name + ">")
+		if err := xml.EscapeText(buf, []byte(text)); err != nil {
+			return err
+		}
+		buf.WriteString("</" + name + ">")
+		return nil
+	}
+}
+
+func formatValue(v interface{}) (string, error) {
+	switch tv := v.(type) {
+	case string:
+		return tv, nil
+	case bool:
+		return strconv.FormatBool(tv), nil
+	case int:
+		return strconv.Itoa(tv), nil
+	case int64:
+		return strconv.FormatInt(tv, 10), nil
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64), nil
+	default:
+		return "", errors.New("unsupported value type for xml:" + fmt.Sprint(reflect.TypeOf(v)))
+	}
+}
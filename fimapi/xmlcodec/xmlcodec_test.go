@@ -0,0 +1,90 @@
+package xmlcodec
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+)
+
+func TestUnmarshalAndMarshal(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<order xmlns="urn:order" xmlns:ext="urn:ext" id="1">
+	<customer><name>alice</name></customer>
+	<items><item sku="a">apple &amp; pear</item></items>
+	<tags>x</tags><tags>y</tags>
+	<ext:note/>
+</order>`
+	obj, err := Unmarshal([]byte(data), []string{"order/items/item[]/#sku"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"order": map[string]interface{}{
+			"@xmlns":   "urn:order",
+			"@ext":     "urn:ext",
+			"#id":      "1",
+			"customer": map[string]interface{}{"name": "alice"},
+			"items": map[string]interface{}{
+				"item": []interface{}{map[string]interface{}{"#sku": "a", "#text": "apple & pear"}},
+			},
+			"tags": []interface{}{"x", "y"},
+			"note": map[string]interface{}{"@xmlns": "urn:ext"},
+		},
+	}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Fatal("unexpected result:", obj)
+	}
+
+	out, err := Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj2, err := Unmarshal(out, []string{"order/items/item[]/#sku"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(obj2, expected) {
+		t.Fatal("unexpected round trip result:", string(out))
+	}
+
+	if _, err := Unmarshal([]byte("<a></a><b></b>"), nil); err == nil {
+		t.Fatal("multiple root elements should fail")
+	}
+	if _, err := Marshal(map[string]interface{}{"a": "1", "b": "2"}); err == nil {
+		t.Fatal("multiple root elements should fail")
+	}
+}
+
+func TestUnmarshalWithTypes(t *testing.T) {
+	data := `<order id="7"><paid>true</paid><items><price> 1.5 </price><qty>2</qty><qty>3</qty></items><count></count><note>01</note></order>`
+	types := map[string]basicapi.DataType{
+		"order/#id":         basicapi.DataTypeInt,
+		"order/paid":        basicapi.DataTypeBool,
+		"order/items/price": basicapi.DataTypeFloat,
+		"order/items/qty[]": basicapi.DataTypeInt,
+		"order/count":       basicapi.DataTypeInt,
+		"order/note":        basicapi.DataTypeString,
+	}
+	obj, err := UnmarshalWithTypes([]byte(data), []string{"order/items/qty[]"}, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"order": map[string]interface{}{
+			"#id":   int64(7),
+			"paid":  true,
+			"items": map[string]interface{}{"price": 1.5, "qty": []interface{}{int64(2), int64(3)}},
+			"note":  "01",
+		},
+	}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Fatal("unexpected result:", obj)
+	}
+	if _, err := UnmarshalWithTypes([]byte("<order><paid>yes</paid></order>"), nil, types); err == nil {
+		t.Fatal("value not matching data type should fail")
+	}
+	if r := TrimTypePathPrefix("http/body/", map[string]basicapi.DataType{"http/body/a": basicapi.DataTypeInt, "http/query/b": basicapi.DataTypeInt}); !reflect.DeepEqual(r, map[string]basicapi.DataType{"a": basicapi.DataTypeInt}) {
+		t.Fatal("unexpected trimmed types:", r)
+	}
+}
@@ -3,7 +3,7 @@ package fimcore
 import "testing"
 
 func TestParseSubConnectorToml(t *testing.T) {
-	app := newApplication()
+	app := newApplication("test_application")
 
 	if err := app.AddSubConnectorGeneratorDefinitions(`
 
@@ -8,8 +8,8 @@ import (
 )
 
 const (
-	ConfigurePrefixStatic  = "configure-static://"
-	ConfigurePrefixDynamic = "configure-dynamic://"
+	ConfigurePrefixStatic  = basicapi.ConfigurePrefixStatic
+	ConfigurePrefixDynamic = basicapi.ConfigurePrefixDynamic
 )
 
 type NestedConfigureManager struct {
@@ -11,14 +11,32 @@ func (c *ContainerInst) RegisterBuiltinFn(methodName string, fg pluginapi.FnGen)
 	if !strings.HasPrefix(methodName, "@") {
 		return errors.New("builtin functions should have @ as prefix")
 	}
-	_, ok := c.builtinGenFnMap[methodName]
-	if ok {
+	if c.builtinFnRegistered(methodName) {
 		return errors.New("method already registered:" + methodName)
 	}
 	c.builtinGenFnMap[methodName] = fg
 	return nil
 }
 
+func (c *ContainerInst) RegisterBuiltinTypedFn(methodName string, fg pluginapi.TypedFnGen) error {
+	if !strings.HasPrefix(methodName, "@") {
+		return errors.New("builtin functions should have @ as prefix")
+	}
+	if c.builtinFnRegistered(methodName) {
+		return errors.New("method already registered:" + methodName)
+	}
+	c.builtinTypedGenFnMap[methodName] = fg
+	return nil
+}
+
+func (c *ContainerInst) builtinFnRegistered(methodName string) bool {
+	if _, ok := c.builtinGenFnMap[methodName]; ok {
+		return true
+	}
+	_, ok := c.builtinTypedGenFnMap[methodName]
+	return ok
+}
+
 func (c *ContainerInst) RegisterCustomFn(name string, fn pluginapi.FnGen) error {
 	if !strings.HasPrefix(name, "#") {
 		return errors.New("custom functions should have # as prefix")
@@ -30,3 +48,15 @@ func (c *ContainerInst) RegisterCustomFn(name string, fn pluginapi.FnGen) error
 	c.customGenFnMap[name] = fn
 	return nil
 }
+
+func (c *ContainerInst) RegisterPreOutOperation(name string, gen pluginapi.PreOutOperationGen) error {
+	if !strings.HasPrefix(name, "@") {
+		return errors.New("pre_out operations should have @ as prefix")
+	}
+	_, ok := c.preOutOperationGenMap[name]
+	if ok {
+		return errors.New("pre_out operation already registered:" + name)
+	}
+	c.preOutOperationGenMap[name] = gen
+	return nil
+}
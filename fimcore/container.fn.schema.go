@@ -0,0 +1,196 @@
+package fimcore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+func (c *ContainerInst) RegisterBuiltinFnWithSchema(methodName string, schema pluginapi.FnSchema, fg pluginapi.FnGen) error {
+	if err := schema.Validate(); err != nil {
+		return errors.New(fmt.Sprintf("schema of function=[%s] invalid:%s", methodName, err))
+	}
+	if err := c.RegisterBuiltinFn(methodName, fg); err != nil {
+		return err
+	}
+	c.fnSchemaMap[methodName] = schema
+	return nil
+}
+
+func (c *ContainerInst) RegisterBuiltinTypedFnWithSchema(methodName string, schema pluginapi.FnSchema, fg pluginapi.TypedFnGen) error {
+	if err := schema.Validate(); err != nil {
+		return errors.New(fmt.Sprintf("schema of function=[%s] invalid:%s", methodName, err))
+	}
+	if err := c.RegisterBuiltinTypedFn(methodName, fg); err != nil {
+		return err
+	}
+	c.fnSchemaMap[methodName] = schema
+	return nil
+}
+
+func (c *ContainerInst) RegisterCustomFnWithSchema(name string, schema pluginapi.FnSchema, fn pluginapi.FnGen) error {
+	if err := schema.Validate(); err != nil {
+		return errors.New(fmt.Sprintf("schema of function=[%s] invalid:%s", name, err))
+	}
+	if err := c.RegisterCustomFn(name, fn); err != nil {
+		return err
+	}
+	c.fnSchemaMap[name] = schema
+	return nil
+}
+
+// validateFnParams checks parameters of the step against the schema
+// resolver resolves data types of paths defined for the flow, used by MustExist parameters
+func validateFnParams(fn string, schema pluginapi.FnSchema, params []interface{}, resolver pluginapi.PathTypeResolver) error {
+	least, most := schema.ParamCountRange()
+	if len(params) < least || (most >= 0 && len(params) > most) {
+		var expected string
+		switch {
+		case most < 0:
+			expected = fmt.Sprintf("at least %d", least)
+		case least == most:
+			expected = fmt.Sprint(least)
+		default:
+			expected = fmt.Sprintf("%d to %d", least, most)
+		}
+		return errors.New(fmt.Sprintf("function=[%s] requires %s parameters(%s) but got %d", fn, expected, paramNames(schema), len(params)))
+	}
+	for idx, v := range params {
+		p := schema.Params[schema.ParamIndex(idx, len(params))]
+		if err := validateFnParam(p, v, resolver); err != nil {
+			return errors.New(fmt.Sprintf("function=[%s] parameter %d(%s) %s", fn, idx, p.Name, err))
+		}
+	}
+	return nil
+}
+
+func validateFnParam(p pluginapi.ParamSchema, v interface{}, resolver pluginapi.PathTypeResolver) error {
+	switch p.Kind {
+	case pluginapi.ParamKindAny:
+		return nil
+	case pluginapi.ParamKindArray:
+		if _, ok := v.([]interface{}); !ok {
+			return errors.New(fmt.Sprintf("should be array:%v", v))
+		}
+		return nil
+	}
+
+	val, err := basicapi.ConvertPrimitive(v)
+	if err != nil {
+		return errors.New(fmt.Sprintf("should be %s:%v", p.Kind, v))
+	}
+	switch p.Kind {
+	case pluginapi.ParamKindString:
+		if _, ok := val.(string); ok {
+			return nil
+		}
+	case pluginapi.ParamKindBool:
+		if _, ok := val.(bool); ok {
+			return nil
+		}
+	case pluginapi.ParamKindNumber:
+		switch val.(type) {
+		case int64, float64:
+			return nil
+		}
+	case pluginapi.ParamKindInt:
+		switch i := val.(type) {
+		case int64:
+			return nil
+		case float64:
+			// numbers decoded from json
+			if i == float64(int64(i)) {
+				return nil
+			}
+		}
+	case pluginapi.ParamKindPath:
+		path, ok := val.(string)
+		if !ok {
+			break
+		}
+		if !rule.ValidateFullPath(path) && !rule.ValidateFullPathOfDefinition(path) {
+			return errors.New("path invalid:" + path)
+		}
+		if p.MustExist {
+			return checkPathDefined(path, resolver)
+		}
+		return nil
+	}
+	return errors.New(fmt.Sprintf("should be %s:%v", p.Kind, v))
+}
+
+// checkPathDefined checks the path in definitions, array access levels are converted to array definitions
+func checkPathDefined(path string, resolver pluginapi.PathTypeResolver) error {
+	paths := rule.SplitFullPath(path)
+	for idx, pLv := range paths {
+		if rule.IsArrayAccess(pLv) {
+			name, _ := rule.ExtractArrayPath(pLv)
+			paths[idx] = name + "[]"
+		}
+	}
+	dt, _, err := resolver.TypeOfDefinitionPath(rule.ConcatFullPath(paths))
+	if err != nil {
+		return err
+	}
+	if dt == pluginapi.DataTypeUnavailable {
+		return errors.New("path is not defined in FlowModel through mappings or local variables:" + path)
+	}
+	return nil
+}
+
+func paramNames(schema pluginapi.FnSchema) string {
+	names := make([]string, 0, len(schema.Params))
+	for _, p := range schema.Params {
+		names = append(names, paramSignature(p))
+	}
+	return strings.Join(names, ", ")
+}
+
+func paramSignature(p pluginapi.ParamSchema) string {
+	s := p.Name
+	if p.Variadic {
+		s += "..."
+	}
+	if p.Optional {
+		s = "[" + s + "]"
+	}
+	return s
+}
+
+// FnReferenceDoc generates markdown reference of functions registered with schemas, sorted by name
+func (c *ContainerInst) FnReferenceDoc() string {
+	var names []string
+	for name := range c.fnSchemaMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sb := new(strings.Builder)
+	for _, name := range names {
+		schema := c.fnSchemaMap[name]
+		sb.WriteString("##### `" + name + "`\n\n")
+		if schema.Description != "" {
+			sb.WriteString(schema.Description + "\n\n")
+		}
+		sb.WriteString("`[" + paramNames(schema) + "]`\n\n")
+		if len(schema.Params) == 0 {
+			continue
+		}
+		sb.WriteString("| parameter | kind | description |\n")
+		sb.WriteString("|---|---|---|\n")
+		for _, p := range schema.Params {
+			kind := string(p.Kind)
+			if p.MustExist {
+				kind += ", defined in FlowModel"
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n", paramSignature(p), kind, p.Description))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -14,6 +14,11 @@ func (c *ContainerInst) LoadMerged(content string) error {
 }
 
 func (c *ContainerInst) loadMerged0(m *MergedDefinition) error {
+	// all FlowModel files are loaded before flows and pipelines
+	if err := c.flowModel.ResolveConstraints(); err != nil {
+		return err
+	}
+
 	// load flow
 	for name, tf := range m.Flows {
 		_, ok := c.flowMap[name]
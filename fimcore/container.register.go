@@ -1,6 +1,8 @@
 package fimcore
 
 import (
+	"errors"
+
 	"github.com/FimGroup/fim/fimapi/basicapi"
 	"github.com/FimGroup/fim/fimapi/pluginapi"
 	"github.com/FimGroup/fim/fimapi/providers"
@@ -25,8 +27,12 @@ func newContainer(application *Application, businessName string) *ContainerInst
 		pipelineMap:        map[string]*Pipeline{},
 		pipelineRawContent: map[string]struct{ *Pipeline }{},
 
-		builtinGenFnMap: map[string]pluginapi.FnGen{},
-		customGenFnMap:  map[string]pluginapi.FnGen{},
+		builtinGenFnMap:      map[string]pluginapi.FnGen{},
+		builtinTypedGenFnMap: map[string]pluginapi.TypedFnGen{},
+		customGenFnMap:       map[string]pluginapi.FnGen{},
+		fnSchemaMap:          map[string]pluginapi.FnSchema{},
+
+		preOutOperationGenMap: newBuiltinPreOutOperationGenMap(),
 
 		connectorMap: map[string]pluginapi.Connector{},
 
@@ -49,14 +55,20 @@ type ContainerInst struct {
 
 	flowModelRawContents [][]byte
 	flowModel            *DataTypeDefinitions
+	compiledModel        bool
+	modelSchema          *modelinst.CompiledSchema
 
 	pipelineRawContent map[string]struct {
 		*Pipeline
 	}
 	pipelineMap map[string]*Pipeline
 
-	builtinGenFnMap map[string]pluginapi.FnGen
-	customGenFnMap  map[string]pluginapi.FnGen
+	builtinGenFnMap      map[string]pluginapi.FnGen
+	builtinTypedGenFnMap map[string]pluginapi.TypedFnGen
+	customGenFnMap       map[string]pluginapi.FnGen
+	fnSchemaMap          map[string]pluginapi.FnSchema
+
+	preOutOperationGenMap map[string]pluginapi.PreOutOperationGen
 
 	connectorMap map[string]pluginapi.Connector
 
@@ -90,7 +102,15 @@ func (c *ContainerInst) GetContainerLoggerManager() providers.LoggerManager {
 
 func (c *ContainerInst) LoadFlowModel(tomlContent string) error {
 
-	if err := c.flowModel.MergeToml(tomlContent); err != nil {
+	m, err := parseFlowModelToml(tomlContent)
+	if err != nil {
+		return err
+	}
+	// validators of pipelines are created when pipelines are loaded
+	if m.hasConstraints() && len(c.pipelineMap) > 0 {
+		return errors.New("FlowModel with constraints should be loaded before pipelines")
+	}
+	if err := c.flowModel.AddTypeDefinitions(m); err != nil {
 		return err
 	}
 	c.flowModelRawContents = append(c.flowModelRawContents, []byte(tomlContent))
@@ -118,6 +138,17 @@ func (c *ContainerInst) StartContainer() error {
 	// internal mechanism registration
 	c.AddLifecycleListener(generateDispatchDeciderLifecycleListener(c))
 
+	if err := c.flowModel.ResolveConstraints(); err != nil {
+		return err
+	}
+
+	// compile models after all definitions are loaded
+	if c.compiledModel {
+		if err := c.compileModels(); err != nil {
+			return err
+		}
+	}
+
 	// setup pipelines
 	for _, p := range c.pipelineMap {
 		if err := p.combinePipelineAndSourceConnector(); err != nil {
@@ -176,15 +207,56 @@ func (c *ContainerInst) StartContainer() error {
 }
 
 func (c *ContainerInst) NewModel() pluginapi.Model {
+	if c.modelSchema != nil {
+		return c.modelSchema.NewInst()
+	}
 	return modelinst.ModelInstHelper{}.NewInst()
 }
 
+func (c *ContainerInst) ReleaseModel(m pluginapi.Model) {
+	if c.modelSchema != nil {
+		c.modelSchema.Release(m)
+	}
+}
+
+// EnableCompiledModel makes the container use schema-compiled and pooled Models
+// Schema is compiled from FlowModel and flow definitions when starting the container
+func (c *ContainerInst) EnableCompiledModel() error {
+	if c.stopFunction != nil {
+		return errors.New("compiled model should be enabled before starting container")
+	}
+	c.compiledModel = true
+	return nil
+}
+
+func (c *ContainerInst) compileModels() error {
+	schema, err := modelinst.CompileSchema(c.flowModel.leafPaths())
+	if err != nil {
+		return err
+	}
+	c.modelSchema = schema
+	for _, f := range c.flowMap {
+		f.compileLocalModel()
+	}
+	return nil
+}
+
 func (c *ContainerInst) WrapReadonlyModelFromMap(m map[string]interface{}) (pluginapi.Model, error) {
-	//FIXME need to make sure readonly
-	return modelinst.ModelInstHelper{}.WrapReadonlyMap(m), nil
+	return modelinst.ModelInstHelper{}.WrapReadonlyMapView(m)
 }
 
 func (c *ContainerInst) AddConfigureManager(manager basicapi.ConfigureManager) error {
 	c.configureManager.addSubConfigureManager(manager)
 	return nil
 }
+
+func (c *ContainerInst) ConfigureManager() basicapi.ConfigureManager {
+	return c.configureManager
+}
+
+func (c *ContainerInst) GetFileResourceManager(name string) pluginapi.FileResourceManager {
+	if c.application == nil {
+		return nil
+	}
+	return c.application.GetFileResourceManager(name)
+}
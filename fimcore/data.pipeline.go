@@ -3,8 +3,10 @@ package fimcore
 import (
 	"encoding/json"
 	"errors"
+	"sort"
 	"strings"
 
+	"github.com/FimGroup/fim/fimapi/basicapi"
 	"github.com/FimGroup/fim/fimapi/pluginapi"
 	"github.com/FimGroup/fim/fimapi/providers"
 	"github.com/FimGroup/fim/fimapi/rule"
@@ -157,12 +159,28 @@ func initPipeline(p *Pipeline, container *ContainerInst, application *Applicatio
 			if err != nil {
 				return nil, err
 			}
+			// connector side is not defined in FlowModel
+			if err := reqConverter.Compile(nil, container.flowModel); err != nil {
+				return nil, err
+			}
+			if err := resConverter.Compile(container.flowModel, nil); err != nil {
+				return nil, err
+			}
+			reqTransfer := reqConverter.GeneralTransfer
+			// validate field constraints once request is filled into the model
+			if validator := container.flowModel.newFieldValidator(reqConverter.TargetLeafPathList); validator != nil {
+				reqTransfer = validator.wrapTransfer(reqTransfer)
+			}
 			mappdingDef := &pluginapi.MappingDefinition{
-				ReqConverter: reqConverter.GeneralTransfer,
+				ReqConverter: reqTransfer,
 				ReqArgPaths:  reqConverter.TargetLeafPathList,
 				ResConverter: resConverter.GeneralTransfer,
 				ResArgPaths:  resConverter.SourceLeafPathList,
 				ErrSimple:    s.ErrSimple,
+
+				ReqConnectorPaths: reqConverter.SourceLeafPathList,
+				ResConnectorPaths: resConverter.TargetLeafPathList,
+				ReqConnectorTypes: container.flowModel.connectorPathTypes(reqConverter.SourceLeafPathList, reqConverter.TargetLeafPathList),
 			}
 
 			if f, err := container.application.internalGenerateSourceConnectorInstance(connectorName, instanceName, container, v, mappdingDef); err != nil {
@@ -180,14 +198,16 @@ func initPipeline(p *Pipeline, container *ContainerInst, application *Applicatio
 		// build pipeline.steps maps
 		var stepsMapList []map[string]string
 		var stepsMappingList []struct {
-			Req modelinst.MappingRuleRaw
-			Res modelinst.MappingRuleRaw
+			Req       modelinst.MappingRuleRaw
+			Res       modelinst.MappingRuleRaw
+			Transform modelinst.MappingRuleRaw
 		}
 		for _, v := range p.Pipeline.Steps {
 			m := map[string]string{}
 			var mapping struct {
-				Req modelinst.MappingRuleRaw
-				Res modelinst.MappingRuleRaw
+				Req       modelinst.MappingRuleRaw
+				Res       modelinst.MappingRuleRaw
+				Transform modelinst.MappingRuleRaw
 			}
 			for _, vv := range v {
 				if len(vv) < 2 {
@@ -216,6 +236,18 @@ func initPipeline(p *Pipeline, container *ContainerInst, application *Applicatio
 					}
 					mapping.Req = req
 					mapping.Res = res
+				} else if k == "@transform" {
+					if len(vv) != 2 {
+						return nil, errors.New("@transform should have mapping rule in pipeline.steps")
+					}
+					if mapping.Transform != nil {
+						return nil, errors.New("duplicated @transform in pipeline.steps definition")
+					}
+					transform, err := convertToMappingRule(vv[1])
+					if err != nil {
+						return nil, err
+					}
+					mapping.Transform = transform
 				} else {
 					if len(vv) != 2 {
 						return nil, errors.New("2 parameter config is allowed in pipeline.steps definition")
@@ -240,7 +272,18 @@ func initPipeline(p *Pipeline, container *ContainerInst, application *Applicatio
 			flowS, okS := v["@flow"]
 			flowA, okA := v["#flow"]
 			var flow string
-			if okS && okA {
+			if transform := stepsMappingList[idx].Transform; transform != nil {
+				// transform step
+				if okS || okA {
+					return nil, errors.New("should not make a pipeline step both invoking flow and transforming")
+				}
+				step, err := newTransformStep(transform, container.flowModel, v)
+				if err != nil {
+					return nil, err
+				}
+				p.steps = append(p.steps, step)
+				continue
+			} else if okS && okA {
 				return nil, errors.New("should not make a pipeline step both invoking flow and triggering event step")
 			} else if okS {
 				flow = flowS
@@ -278,12 +321,22 @@ func initPipeline(p *Pipeline, container *ContainerInst, application *Applicatio
 				if err != nil {
 					return nil, err
 				}
+				// connector side is not defined in FlowModel
+				if err := reqConverter.Compile(container.flowModel, nil); err != nil {
+					return nil, err
+				}
+				if err := resConverter.Compile(nil, container.flowModel); err != nil {
+					return nil, err
+				}
 				mappdingDef := &pluginapi.MappingDefinition{
 					ReqConverter: reqConverter.GeneralTransfer,
 					ReqArgPaths:  reqConverter.TargetLeafPathList,
 					ResConverter: resConverter.GeneralTransfer,
 					ResArgPaths:  resConverter.SourceLeafPathList,
 					ErrSimple:    []map[string]string{},
+
+					ReqConnectorPaths: reqConverter.TargetLeafPathList,
+					ResConnectorPaths: resConverter.SourceLeafPathList,
 				}
 				//FIXME support parameter data mapping for target connector
 
@@ -324,7 +377,10 @@ func initPipeline(p *Pipeline, container *ContainerInst, application *Applicatio
 									return nil
 								}
 							}
-							return flowInst(g, p.container.NewModel())
+							// the response of trigger is dropped, release the local model once invoked
+							local := p.container.NewModel()
+							defer p.container.ReleaseModel(local)
+							return flowInst(g, local)
 						}
 					})
 				}
@@ -349,6 +405,121 @@ func initPipeline(p *Pipeline, container *ContainerInst, application *Applicatio
 	return p, nil
 }
 
+// newTransformStep generates pipeline step applying the mapping from the global model to itself
+// Source of the mapping is a snapshot of the mapped subtrees so that updated fields are not read again
+func newTransformStep(transform modelinst.MappingRuleRaw, flowModel *DataTypeDefinitions, v map[string]string) (func() func(g pluginapi.Model) error, error) {
+	converter, err := transform.ToConverter()
+	if err != nil {
+		return nil, err
+	}
+	// both sides are defined in FlowModel
+	if err := converter.Compile(flowModel, flowModel); err != nil {
+		return nil, err
+	}
+	targetArrays := outermostObjectArrays(converter.TargetLeafPathList)
+	sourcePaths := transformSourcePaths(converter)
+	var casePreFn func(m pluginapi.Model) (bool, error)
+	caseOperator, caseValue := findCaseClause(v)
+	if caseOperator != "" {
+		casePreFn, err = generateCasePreFn(caseOperator, caseValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return func() func(g pluginapi.Model) error {
+		return func(g pluginapi.Model) error {
+			if casePreFn != nil {
+				match, err := casePreFn(g)
+				if err != nil {
+					return err
+				}
+				if !match {
+					return nil
+				}
+			}
+			snapshot, err := transformSnapshot(g, sourcePaths)
+			if err != nil {
+				return err
+			}
+			// elements are appended to object arrays by transfer, so target arrays are replaced by source arrays
+			for _, path := range targetArrays {
+				if err := g.DeleteField(path); err != nil {
+					return err
+				}
+			}
+			return converter.GeneralTransfer(snapshot, g)
+		}
+	}, nil
+}
+
+// transformSourcePaths returns paths of the source subtrees of the mapping, e.g. ["user", "user", [...]] -> user
+// Paths under other paths are skipped to avoid copying twice. nil is returned if the root is the source.
+func transformSourcePaths(converter *modelinst.ModelConverter) [][]string {
+	var paths []string
+	for _, lp := range converter.LevelPair {
+		if lp.SrcPath == "" {
+			return nil
+		}
+		var levels []string
+		for _, lv := range rule.SplitFullPath(lp.SrcPath) {
+			name, _ := rule.ExtractArrayPath(lv)
+			levels = append(levels, name)
+		}
+		paths = append(paths, rule.ConcatFullPath(levels))
+	}
+	sort.Strings(paths)
+	r := [][]string{}
+	var last string
+	for _, path := range paths {
+		if len(r) > 0 && (path == last || strings.HasPrefix(path, last+pluginapi.PathSeparator)) {
+			continue
+		}
+		last = path
+		r = append(r, rule.SplitFullPath(path))
+	}
+	return r
+}
+
+// transformSnapshot copies the source subtrees of the global model, the whole model is copied if sourcePaths is nil
+func transformSnapshot(g pluginapi.Model, sourcePaths [][]string) (pluginapi.Model, error) {
+	if sourcePaths == nil {
+		return g.CopySubModel(nil)
+	}
+	snapshot := modelinst.ModelInstHelper{}.NewInst()
+	for _, path := range sourcePaths {
+		obj, err := basicapi.GetGeneralObject(g, path)
+		if err != nil {
+			return nil, err
+		}
+		if err := basicapi.PutGeneralObject(snapshot, path, obj); err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// outermostObjectArrays returns paths of the outermost object arrays of leaf definition paths, e.g. user/items[]/id -> user/items
+func outermostObjectArrays(leafPaths []string) [][]string {
+	var r [][]string
+	visited := map[string]struct{}{}
+	for _, leaf := range leafPaths {
+		levels := rule.SplitFullPath(leaf)
+		for idx, lv := range levels[:len(levels)-1] {
+			if !rule.IsArrayDefinition(lv) {
+				continue
+			}
+			path := append([]string{}, levels[:idx+1]...)
+			path[idx], _ = rule.ExtractArrayPath(lv)
+			if _, ok := visited[rule.ConcatFullPath(path)]; !ok {
+				visited[rule.ConcatFullPath(path)] = struct{}{}
+				r = append(r, path)
+			}
+			break
+		}
+	}
+	return r
+}
+
 func generateCasePreFn(operator string, value string) (func(m pluginapi.Model) (bool, error), error) {
 	paths := rule.SplitFullPath(value)
 	switch operator {
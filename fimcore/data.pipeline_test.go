@@ -0,0 +1,99 @@
+package fimcore
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/FimGroup/fim/fimcore/modelinst"
+)
+
+func TestTransformStep(t *testing.T) {
+	def, err := loadDef()
+	if err != nil {
+		t.Fatal(err)
+	}
+	transform := modelinst.MappingRuleRaw{
+		{"user", "user", []interface{}{
+			[]interface{}{"username", "nickname"},
+			[]interface{}{"nickname", "username"},
+		}},
+	}
+	step, err := newTransformStep(transform, def, map[string]string{"@case-true": "user/is_login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	global := modelinst.ModelInstHelper{}.NewInst()
+	for path, v := range map[string]interface{}{"username": "alice", "nickname": "ally", "is_login": true} {
+		if err := global.AddOrUpdateField0([]string{"user", path}, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := step()(global); err != nil {
+		t.Fatal(err)
+	}
+	// fields are swapped since source is the snapshot before transform
+	if v := global.GetFieldUnsafe0([]string{"user", "username"}); v != "ally" {
+		t.Fatal("unexpected username:", v)
+	}
+	if v := global.GetFieldUnsafe0([]string{"user", "nickname"}); v != "alice" {
+		t.Fatal("unexpected nickname:", v)
+	}
+
+	// skipped by case clause
+	if err := global.AddOrUpdateField0([]string{"user", "is_login"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := step()(global); err != nil {
+		t.Fatal(err)
+	}
+	if v := global.GetFieldUnsafe0([]string{"user", "username"}); v != "ally" {
+		t.Fatal("transform should be skipped:", v)
+	}
+
+	// object arrays are replaced rather than appended
+	arrays := modelinst.MappingRuleRaw{
+		{"user", "user", []interface{}{
+			[]interface{}{"phone[]", "phone[]", []interface{}{
+				[]interface{}{"area_code", "country_code"},
+			}},
+		}},
+	}
+	step, err = newTransformStep(arrays, def, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for idx, code := range []string{"1", "2"} {
+		if err := global.AddOrUpdateField0([]string{"user", fmt.Sprintf("phone[%d]", idx), "area_code"}, code); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := step()(global); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := global.ArrayLength([]string{"user", "phone"}); err != nil || n != 2 {
+		t.Fatal("object array should be replaced:", n, err)
+	}
+	if v := global.GetFieldUnsafe0([]string{"user", "phone[1]", "country_code"}); v != "2" {
+		t.Fatal("unexpected element:", v)
+	}
+
+	// only the mapped subtrees are copied as the source
+	paths := modelinst.MappingRuleRaw{
+		{"user/phone[]", "user/phone[]", []interface{}{[]interface{}{"area_code", "country_code"}}},
+		{"user", "user", []interface{}{[]interface{}{"username", "nickname"}}},
+	}
+	converter, err := paths.ToConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := transformSourcePaths(converter); !reflect.DeepEqual(r, [][]string{{"user"}}) {
+		t.Fatal("unexpected source paths:", r)
+	}
+
+	// data types are checked against FlowModel
+	mismatch := modelinst.MappingRuleRaw{{"user", "user", []interface{}{[]interface{}{"user_id", "username"}}}}
+	if _, err := newTransformStep(mismatch, def, map[string]string{}); err == nil {
+		t.Fatal("mapping int field to string field should fail")
+	}
+}
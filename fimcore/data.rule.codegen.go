@@ -0,0 +1,267 @@
+package fimcore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+const GoSourceRootType = "FlowModel"
+
+// ToGoSource generates Go source of the definitions:
+// structs with fim tags for modelbind, path constants and typed accessors of primitive fields
+// Struct fields are pointers so that missing fields are distinguished from zero values
+// Indexes of enclosing arrays are parameters of accessors, e.g. GetItemsName(m, i0)
+func (d *DataTypeDefinitions) ToGoSource(packageName string) ([]byte, error) {
+	if !token.IsIdentifier(packageName) {
+		return nil, errors.New("invalid package name:" + packageName)
+	}
+	g := &goSourceGenerator{
+		names: map[string]string{},
+	}
+	if err := g.generateStruct(GoSourceRootType, "", d); err != nil {
+		return nil, err
+	}
+	if err := g.generatePaths(d, nil, nil, nil); err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("// Code generated by fimgen. DO NOT EDIT.\n\n")
+	buf.WriteString("package " + packageName + "\n\n")
+	if g.usesStrconv {
+		buf.WriteString("import (\n\t\"strconv\"\n\n\t\"github.com/FimGroup/fim/fimapi/basicapi\"\n)\n\n")
+	} else {
+		buf.WriteString("import \"github.com/FimGroup/fim/fimapi/basicapi\"\n\n")
+	}
+	buf.WriteString("// Paths of FlowModel definitions\nconst (\n")
+	buf.Write(g.consts.Bytes())
+	buf.WriteString(")\n\n")
+	buf.Write(g.types.Bytes())
+	buf.Write(g.funcs.Bytes())
+	return format.Source(buf.Bytes())
+}
+
+type goSourceGenerator struct {
+	// names holds generated identifiers and their paths to detect conflicts
+	names       map[string]string
+	usesStrconv bool
+
+	consts bytes.Buffer
+	types  bytes.Buffer
+	funcs  bytes.Buffer
+}
+
+func (g *goSourceGenerator) declare(name, path string) error {
+	if p, ok := g.names[name]; ok {
+		return errors.New(fmt.Sprintf("generated name conflict:%s of path:[%s] and path:[%s]", name, p, path))
+	}
+	g.names[name] = path
+	return nil
+}
+
+// goIdentifierOfLevel converts path level to exported Go identifier, e.g. user_name -> UserName, #sku -> AttrSku
+func goIdentifierOfLevel(level string) (string, error) {
+	name := level
+	var prefix string
+	switch name[0] {
+	case '#':
+		prefix, name = "Attr", name[1:]
+	case '@':
+		prefix, name = "Ns", name[1:]
+	}
+	var sb strings.Builder
+	sb.WriteString(prefix)
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+		first, size := utf8.DecodeRuneInString(part)
+		sb.WriteRune(unicode.ToUpper(first))
+		sb.WriteString(part[size:])
+	}
+	ident := sb.String()
+	if len(ident) == len(prefix) || !token.IsIdentifier(ident) || !token.IsExported(ident) {
+		return "", errors.New("cannot derive Go identifier for level:" + level)
+	}
+	return ident, nil
+}
+
+func sortedDataTypeNames(d *DataTypeDefinitions) []string {
+	names := make([]string, 0, len(d.dataTypeMap))
+	for name := range d.dataTypeMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func goTypeOfPrimitive(dataType pluginapi.DataType) (string, string, error) {
+	switch dataType {
+	case pluginapi.DataTypeString:
+		return "string", "String", nil
+	case pluginapi.DataTypeInt:
+		return "int64", "Int", nil
+	case pluginapi.DataTypeFloat:
+		return "float64", "Float", nil
+	case pluginapi.DataTypeBool:
+		return "bool", "Bool", nil
+	default:
+		return "", "", errors.New(fmt.Sprint("unknown primitive data type:", dataType))
+	}
+}
+
+// generateStruct generates struct of the object and structs of its sub objects
+func (g *goSourceGenerator) generateStruct(typeName, path string, d *DataTypeDefinitions) error {
+	if err := g.declare(typeName, path); err != nil {
+		return err
+	}
+	var sb strings.Builder
+	if path == "" {
+		sb.WriteString("// " + typeName + " is the root object of FlowModel\n")
+	} else {
+		sb.WriteString("// " + typeName + " is the object of path: " + path + "\n")
+	}
+	sb.WriteString("type " + typeName + " struct {\n")
+	var subs []func() error
+	for _, name := range sortedDataTypeNames(d) {
+		sub := d.dataTypeMap[name]
+		fieldName, err := goIdentifierOfLevel(name)
+		if err != nil {
+			return err
+		}
+		subTypeName := typeName + fieldName
+		if path == "" {
+			subTypeName = fieldName
+		}
+		subPath := name
+		if path != "" {
+			subPath = path + pluginapi.PathSeparator + name
+		}
+		var fieldType string
+		switch {
+		case sub.DataType == pluginapi.DataTypeObject:
+			fieldType = "*" + subTypeName
+		case sub.DataType == pluginapi.DataTypeArray && sub.PrimitiveArrayElementType == pluginapi.DataTypeUnavailable:
+			fieldType = "[]" + subTypeName
+			subPath = subPath + "[]"
+		case sub.DataType == pluginapi.DataTypeArray:
+			t, _, err := goTypeOfPrimitive(sub.PrimitiveArrayElementType)
+			if err != nil {
+				return err
+			}
+			fieldType = "[]" + t
+		default:
+			t, _, err := goTypeOfPrimitive(sub.DataType)
+			if err != nil {
+				return err
+			}
+			fieldType = "*" + t
+		}
+		sb.WriteString(fmt.Sprintf("\t%s %s `fim:%s`\n", fieldName, fieldType, strconv.Quote(name)))
+		if sub.DataType == pluginapi.DataTypeObject || (sub.DataType == pluginapi.DataTypeArray && sub.PrimitiveArrayElementType == pluginapi.DataTypeUnavailable) {
+			s, t, p := sub, subTypeName, subPath
+			subs = append(subs, func() error {
+				return g.generateStruct(t, p, s)
+			})
+		}
+	}
+	sb.WriteString("}\n\n")
+	g.types.WriteString(sb.String())
+	for _, f := range subs {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generatePaths generates path constants and accessors of the object recursively
+// levels are definition levels, names are identifiers of levels and arrays are indexes of array levels
+func (g *goSourceGenerator) generatePaths(d *DataTypeDefinitions, levels []string, names []string, arrays []int) error {
+	for _, name := range sortedDataTypeNames(d) {
+		sub := d.dataTypeMap[name]
+		levelIdent, err := goIdentifierOfLevel(name)
+		if err != nil {
+			return err
+		}
+		subNames := append(append([]string{}, names...), levelIdent)
+		subArrays := append([]int{}, arrays...)
+		level := name
+		if sub.DataType == pluginapi.DataTypeArray {
+			level = name + "[]"
+			subArrays = append(subArrays, len(levels))
+		}
+		subLevels := append(append([]string{}, levels...), level)
+		ident := strings.Join(subNames, "")
+		fullPath := rule.ConcatFullPath(subLevels)
+		if err := g.declare("Path"+ident, fullPath); err != nil {
+			return err
+		}
+		g.consts.WriteString(fmt.Sprintf("\tPath%s = %s\n", ident, strconv.Quote(fullPath)))
+
+		switch {
+		case sub.DataType == pluginapi.DataTypeObject:
+			if err := g.generatePaths(sub, subLevels, subNames, subArrays); err != nil {
+				return err
+			}
+		case sub.DataType == pluginapi.DataTypeArray && sub.PrimitiveArrayElementType == pluginapi.DataTypeUnavailable:
+			if err := g.generatePaths(sub, subLevels, subNames, subArrays); err != nil {
+				return err
+			}
+		case sub.DataType == pluginapi.DataTypeArray:
+			if err := g.generateAccessors(ident, fullPath, subLevels, subArrays, sub.PrimitiveArrayElementType); err != nil {
+				return err
+			}
+		default:
+			if err := g.generateAccessors(ident, fullPath, subLevels, subArrays, sub.DataType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// generateAccessors generates typed getter and setter of the primitive field or element of primitive array
+func (g *goSourceGenerator) generateAccessors(ident, fullPath string, levels []string, arrays []int, dataType pluginapi.DataType) error {
+	goType, getter, err := goTypeOfPrimitive(dataType)
+	if err != nil {
+		return err
+	}
+	// path expression with indexes of arrays as parameters
+	var params []string
+	pathElems := make([]string, len(levels))
+	for i, level := range levels {
+		pathElems[i] = strconv.Quote(level)
+	}
+	for n, idx := range arrays {
+		name, _ := rule.ExtractArrayPath(levels[idx])
+		param := "i" + strconv.Itoa(n)
+		params = append(params, param)
+		pathElems[idx] = fmt.Sprintf("%s+strconv.Itoa(%s)+\"]\"", strconv.Quote(name+"["), param)
+		g.usesStrconv = true
+	}
+	var paramDecl string
+	if len(params) > 0 {
+		paramDecl = ", " + strings.Join(params, ", ") + " int"
+	}
+	pathExpr := "[]string{" + strings.Join(pathElems, ", ") + "}"
+
+	for _, fn := range []string{"Get" + ident, "Set" + ident} {
+		if err := g.declare(fn, fullPath); err != nil {
+			return err
+		}
+	}
+	g.funcs.WriteString(fmt.Sprintf("// Get%s returns the value of path: %s\n", ident, fullPath))
+	g.funcs.WriteString(fmt.Sprintf("func Get%s(m basicapi.Model%s) (%s, error) {\n\treturn basicapi.Get%s(m, %s)\n}\n\n", ident, paramDecl, goType, getter, pathExpr))
+	g.funcs.WriteString(fmt.Sprintf("// Set%s updates the value of path: %s\n", ident, fullPath))
+	g.funcs.WriteString(fmt.Sprintf("func Set%s(m basicapi.Model%s, v %s) error {\n\treturn m.AddOrUpdateField0(%s, v)\n}\n\n", ident, paramDecl, goType, pathExpr))
+	return nil
+}
@@ -0,0 +1,57 @@
+package fimcore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToGoSource(t *testing.T) {
+	def := NewDataTypeDefinitions()
+	if err := def.MergeToml(`
+[model]
+"user/name" = "string"
+"user/tags[]" = "string"
+"items[]/sku" = "string"
+"items[]/parts[]/#code" = "string"
+`); err != nil {
+		t.Fatal(err)
+	}
+	src, err := def.ToGoSource("model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"type FlowModel struct",
+		"Items []Items `fim:\"items\"`",
+		"AttrCode *string `fim:\"#code\"`",
+		"PathUserName",
+		"func GetItemsPartsAttrCode(m basicapi.Model, i0, i1 int) (string, error)",
+		"func SetUserTags(m basicapi.Model, i0 int, v string) error",
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Fatal("expected:", expected, "in generated source:\n", string(src))
+		}
+	}
+
+	conflict := NewDataTypeDefinitions()
+	if err := conflict.MergeToml(`
+[model]
+"user_name" = "string"
+"user-name" = "string"
+`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conflict.ToGoSource("model"); err == nil {
+		t.Fatal("name conflict should fail")
+	}
+
+	for _, level := range []string{"_", "__", "#__"} {
+		invalid := NewDataTypeDefinitions()
+		if err := invalid.MergeToml("[model]\n\"" + level + "\" = \"string\"\n"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := invalid.ToGoSource("model"); err == nil || !strings.Contains(err.Error(), "cannot derive Go identifier") {
+			t.Fatal("level without Go identifier should fail:", level, err)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package fimcore
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+const (
+	ConstraintFormatEmail = "email"
+	ConstraintFormatUuid  = "uuid"
+	ConstraintFormatUri   = "uri"
+)
+
+var uuidFormatRegexp = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// FieldConstraint defines constraints of a field beyond its data type
+// For array definitions(xxx[]), constraints apply to each element of the array
+type FieldConstraint struct {
+	Required  bool          `toml:"required"`
+	Min       interface{}   `toml:"min"` // int or float
+	Max       interface{}   `toml:"max"` // int or float
+	MinLength *int          `toml:"min_length"`
+	MaxLength *int          `toml:"max_length"`
+	Pattern   string        `toml:"pattern"`
+	Enum      []interface{} `toml:"enum"`
+	Format    string        `toml:"format"`
+
+	min     *float64
+	max     *float64
+	pattern *regexp.Regexp
+}
+
+func (c *FieldConstraint) prepare(path string, dataType pluginapi.DataType) error {
+	isNumber := dataType == pluginapi.DataTypeInt || dataType == pluginapi.DataTypeFloat
+	isString := dataType == pluginapi.DataTypeString
+	if (c.Min != nil || c.Max != nil) && !isNumber {
+		return errors.New(fmt.Sprintf("constraint min/max requires int or float field:%s", path))
+	}
+	if c.Min != nil {
+		v, ok := toFloat64(c.Min)
+		if !ok {
+			return errors.New(fmt.Sprintf("constraint min is not a number:%s", path))
+		}
+		c.min = &v
+	}
+	if c.Max != nil {
+		v, ok := toFloat64(c.Max)
+		if !ok {
+			return errors.New(fmt.Sprintf("constraint max is not a number:%s", path))
+		}
+		c.max = &v
+	}
+	if c.min != nil && c.max != nil && *c.min > *c.max {
+		return errors.New(fmt.Sprintf("constraint min is greater than max:%s", path))
+	}
+	if (c.MinLength != nil || c.MaxLength != nil || c.Pattern != "" || c.Format != "") && !isString {
+		return errors.New(fmt.Sprintf("constraint min_length/max_length/pattern/format requires string field:%s", path))
+	}
+	if c.MinLength != nil && c.MaxLength != nil && *c.MinLength > *c.MaxLength {
+		return errors.New(fmt.Sprintf("constraint min_length is greater than max_length:%s", path))
+	}
+	if c.Pattern != "" {
+		p, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return errors.New(fmt.Sprintf("constraint pattern of path:%s invalid:%s", path, err))
+		}
+		c.pattern = p
+	}
+	switch c.Format {
+	case "":
+	case ConstraintFormatEmail:
+	case ConstraintFormatUuid:
+	case ConstraintFormatUri:
+	default:
+		return errors.New(fmt.Sprintf("unknown constraint format:%s of path:%s", c.Format, path))
+	}
+	for idx, v := range c.Enum {
+		val, err := convertPrimitive(v)
+		if err != nil {
+			return errors.New(fmt.Sprintf("constraint enum of path:%s has non-primitive value:%s", path, err))
+		}
+		c.Enum[idx] = val
+	}
+	return nil
+}
+
+// check returns violated rule and message, empty rule means passed
+func (c *FieldConstraint) check(val interface{}) (string, string) {
+	if val == nil {
+		if c.Required {
+			return "required", "field is required"
+		}
+		return "", ""
+	}
+	if c.min != nil || c.max != nil {
+		num, ok := toFloat64(val)
+		if !ok {
+			return "type", "field is not a number"
+		}
+		if c.min != nil && num < *c.min {
+			return "min", fmt.Sprint("field should not be less than ", c.Min)
+		}
+		if c.max != nil && num > *c.max {
+			return "max", fmt.Sprint("field should not be greater than ", c.Max)
+		}
+	}
+	if c.MinLength != nil || c.MaxLength != nil || c.pattern != nil || c.Format != "" {
+		s, ok := val.(string)
+		if !ok {
+			return "type", "field is not a string"
+		}
+		length := utf8.RuneCountInString(s)
+		if c.MinLength != nil && length < *c.MinLength {
+			return "min_length", fmt.Sprint("field length should not be less than ", *c.MinLength)
+		}
+		if c.MaxLength != nil && length > *c.MaxLength {
+			return "max_length", fmt.Sprint("field length should not be greater than ", *c.MaxLength)
+		}
+		if c.pattern != nil && !c.pattern.MatchString(s) {
+			return "pattern", "field does not match pattern " + c.Pattern
+		}
+		if c.Format != "" && !checkFormat(c.Format, s) {
+			return "format", "field is not a valid " + c.Format
+		}
+	}
+	if len(c.Enum) > 0 {
+		found := false
+		for _, e := range c.Enum {
+			if compareInterfaceValue(e, val) {
+				found = true
+				break
+			}
+			// numbers from external input may be float while enum is int
+			if ef, ok := toFloat64(e); ok {
+				if vf, ok := toFloat64(val); ok && ef == vf {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return "enum", fmt.Sprint("field should be one of ", c.Enum)
+		}
+	}
+	return "", ""
+}
+
+func checkFormat(format, s string) bool {
+	switch format {
+	case ConstraintFormatEmail:
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case ConstraintFormatUuid:
+		return uuidFormatRegexp.MatchString(s)
+	case ConstraintFormatUri:
+		u, err := url.ParseRequestURI(s)
+		return err == nil && u.Scheme != ""
+	default:
+		return false
+	}
+}
+
+func toFloat64(val interface{}) (float64, bool) {
+	switch v := val.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func convertPrimitive(in interface{}) (interface{}, error) {
+	return basicapi.ConvertPrimitive(in)
+}
+
+func (d *DataTypeDefinitions) addConstraints(constraints map[string]*FieldConstraint) error {
+	for path, c := range constraints {
+		if !rule.ValidateFullPathOfDefinition(path) {
+			return errors.New(fmt.Sprint("constraint path:", path, " illegal"))
+		}
+		def := d.definitionOfPath(path)
+		if def == nil {
+			return errors.New(fmt.Sprint("constraint path:", path, " is not defined in model"))
+		}
+		dataType := def.DataType
+		if dataType == pluginapi.DataTypeArray {
+			dataType = def.PrimitiveArrayElementType
+		}
+		if _, ok := primitiveType[dataType]; !ok {
+			return errors.New(fmt.Sprint("constraint path:", path, " is not a primitive field"))
+		}
+		if def.Constraint != nil {
+			return errors.New(fmt.Sprint("duplicated constraint of path:", path))
+		}
+		if err := c.prepare(path, dataType); err != nil {
+			return err
+		}
+		def.Constraint = c
+	}
+	return nil
+}
+
+// definitionOfPath returns the definition of the given definition path(array levels as xxx[])
+func (d *DataTypeDefinitions) definitionOfPath(path string) *DataTypeDefinitions {
+	dtd := d
+	for _, pLv := range rule.SplitFullPath(path) {
+		name, _ := rule.ExtractArrayPath(pLv)
+		sub, ok := dtd.dataTypeMap[name]
+		if !ok {
+			return nil
+		}
+		dtd = sub
+	}
+	return dtd
+}
+
+type fieldValidatorItem struct {
+	levels     []string
+	arrayLevel []bool
+	constraint *FieldConstraint
+}
+
+// fieldValidator checks field constraints on a Model
+type fieldValidator struct {
+	items []fieldValidatorItem
+}
+
+// newFieldValidator creates validator of every constrained path under the top level fields of the given leaf paths,
+// so that required fields not filled by the mapping are checked as well.
+// nil will be returned if no constraint applies to the paths
+func (d *DataTypeDefinitions) newFieldValidator(paths []string) *fieldValidator {
+	roots := map[string]struct{}{}
+	for _, path := range paths {
+		name, _ := rule.ExtractArrayPath(rule.SplitFullPath(path)[0])
+		roots[name] = struct{}{}
+	}
+	var constrainedPaths []string
+	for name := range roots {
+		sub, ok := d.dataTypeMap[name]
+		if !ok {
+			continue
+		}
+		constrainedPaths = append(constrainedPaths, sub.constrainedPaths(name)...)
+	}
+	if len(constrainedPaths) == 0 {
+		return nil
+	}
+	sort.Strings(constrainedPaths)
+	var items []fieldValidatorItem
+	for _, path := range constrainedPaths {
+		item := fieldValidatorItem{constraint: d.definitionOfPath(path).Constraint}
+		for _, pLv := range rule.SplitFullPath(path) {
+			name, _ := rule.ExtractArrayPath(pLv)
+			item.levels = append(item.levels, name)
+			item.arrayLevel = append(item.arrayLevel, rule.IsPathArray(pLv))
+		}
+		items = append(items, item)
+	}
+	return &fieldValidator{items: items}
+}
+
+// constrainedPaths returns definition paths of fields with constraints, path is the definition path of d
+func (d *DataTypeDefinitions) constrainedPaths(path string) []string {
+	if d.DataType == pluginapi.DataTypeArray {
+		path += "[]"
+	}
+	if d.Constraint != nil {
+		return []string{path}
+	}
+	var r []string
+	for name, sub := range d.dataTypeMap {
+		r = append(r, sub.constrainedPaths(rule.ConcatFullPath([]string{path, name}))...)
+	}
+	return r
+}
+
+func (f *fieldValidator) Validate(m pluginapi.Model) error {
+	var details []pluginapi.FieldErrorDetail
+	for _, item := range f.items {
+		details = item.validate(m, 0, nil, details)
+	}
+	if len(details) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, v := range details {
+		msgs = append(msgs, v.Path+":"+v.Message)
+	}
+	return &pluginapi.FlowError{
+		Key:     pluginapi.FlowErrorKeyValidationFailed,
+		Message: strings.Join(msgs, "; "),
+		Details: details,
+	}
+}
+
+func (f *fieldValidator) wrapTransfer(transfer func(src, dst pluginapi.Model) error) func(src, dst pluginapi.Model) error {
+	return func(src, dst pluginapi.Model) error {
+		if err := transfer(src, dst); err != nil {
+			return err
+		}
+		return f.Validate(dst)
+	}
+}
+
+func (i fieldValidatorItem) validate(m pluginapi.Model, lv int, concretePaths []string, details []pluginapi.FieldErrorDetail) []pluginapi.FieldErrorDetail {
+	name := i.levels[lv]
+	lastLevel := lv == len(i.levels)-1
+	if !i.arrayLevel[lv] {
+		concrete := appendLevel(concretePaths, name)
+		if lastLevel {
+			return i.appendField(details, m, concrete)
+		}
+		if dt, err := m.FieldType(concrete); err != nil || dt != pluginapi.DataTypeObject {
+			// missing parent object, the field is missing as well unless array levels exist in between
+			for _, isArr := range i.arrayLevel[lv+1:] {
+				if isArr {
+					return details
+				}
+			}
+			return i.appendDetail(details, append(concrete, i.levels[lv+1:]...), nil)
+		}
+		return i.validate(m, lv+1, concrete, details)
+	}
+	// array level: check each element
+	length, err := m.ArrayLength(appendLevel(concretePaths, name))
+	if err != nil {
+		return details
+	}
+	for idx := 0; idx < length; idx++ {
+		concrete := appendLevel(concretePaths, fmt.Sprint(name, "[", idx, "]"))
+		if lastLevel {
+			details = i.appendField(details, m, concrete)
+		} else {
+			details = i.validate(m, lv+1, concrete, details)
+		}
+	}
+	return details
+}
+
+// appendLevel returns a new path so that paths of sibling levels do not share the underlying array
+func appendLevel(paths []string, level string) []string {
+	r := make([]string, len(paths), len(paths)+1)
+	copy(r, paths)
+	return append(r, level)
+}
+
+func (i fieldValidatorItem) appendField(details []pluginapi.FieldErrorDetail, m pluginapi.Model, concretePaths []string) []pluginapi.FieldErrorDetail {
+	val, err := m.GetField(concretePaths)
+	if err != nil {
+		return append(details, pluginapi.FieldErrorDetail{
+			Path:    rule.ConcatFullPath(concretePaths),
+			Rule:    "type",
+			Message: "field is not a primitive value",
+		})
+	}
+	return i.appendDetail(details, concretePaths, val)
+}
+
+func (i fieldValidatorItem) appendDetail(details []pluginapi.FieldErrorDetail, concretePaths []string, val interface{}) []pluginapi.FieldErrorDetail {
+	ruleName, msg := i.constraint.check(val)
+	if ruleName == "" {
+		return details
+	}
+	return append(details, pluginapi.FieldErrorDetail{
+		Path:    rule.ConcatFullPath(concretePaths),
+		Rule:    ruleName,
+		Message: msg,
+	})
+}
@@ -0,0 +1,165 @@
+package fimcore
+
+import (
+	"testing"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimcore/modelinst"
+)
+
+var constraintFlowModelContent = `
+[model]
+"user/username" = "string"
+"user/email" = "string"
+"user/age" = "int"
+"user/role" = "string"
+"user/tags[]" = "string"
+"user/phone[]/country_code" = "string"
+"group/name" = "string"
+
+[constraints]
+"user/username" = { required = true, min_length = 3, max_length = 16, pattern = "^[a-z0-9_]+$" }
+"user/email" = { format = "email" }
+"user/age" = { min = 0, max = 150 }
+"user/role" = { enum = ["admin", "member"] }
+"user/phone[]/country_code" = { required = true, pattern = "^[0-9]+$" }
+`
+
+func TestFieldConstraintDefinition(t *testing.T) {
+	def := NewDataTypeDefinitions()
+	if err := def.MergeToml(constraintFlowModelContent); err != nil {
+		t.Fatal(err)
+	}
+	if err := def.ResolveConstraints(); err != nil {
+		t.Fatal(err)
+	}
+
+	failed := []string{
+		"[model]\n\"a\" = \"int\"\n[constraints]\n\"a\" = { min_length = 1 }\n",
+		"[model]\n\"a\" = \"string\"\n[constraints]\n\"a\" = { min = 1 }\n",
+		"[model]\n\"a\" = \"string\"\n[constraints]\n\"b\" = { required = true }\n",
+		"[model]\n\"a\" = \"string\"\n[constraints]\n\"a\" = { format = \"unknown\" }\n",
+		"[model]\n\"a\" = \"string\"\n[constraints]\n\"a\" = { pattern = \"[\" }\n",
+		"[model]\n\"a/b\" = \"string\"\n[constraints]\n\"a\" = { required = true }\n",
+	}
+	// constraints are resolved after all FlowModel files are merged
+	split := NewDataTypeDefinitions()
+	if err := split.MergeToml("[constraints]\n\"a\" = { min_length = 1 }\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := split.MergeToml("[model]\n\"a\" = \"string\"\n[constraints]\n\"b\" = { min = 0 }\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := split.MergeToml("[model]\n\"b\" = \"int\"\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := split.ResolveConstraints(); err != nil {
+		t.Fatal("constraints before their model definitions should be resolved:", err)
+	}
+	if split.definitionOfPath("a").Constraint == nil || split.definitionOfPath("b").Constraint == nil {
+		t.Fatal("constraints should be applied")
+	}
+	if err := split.MergeToml("[constraints]\n\"a\" = { max_length = 1 }\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := split.ResolveConstraints(); err == nil {
+		t.Fatal("duplicated constraint should fail")
+	}
+
+	for _, v := range failed {
+		def := NewDataTypeDefinitions()
+		if err := def.MergeToml(v); err == nil && def.ResolveConstraints() == nil {
+			t.Fatal("constraint definition should fail:", v)
+		}
+	}
+}
+
+func TestFieldConstraintValidate(t *testing.T) {
+	def := NewDataTypeDefinitions()
+	if err := def.MergeToml(constraintFlowModelContent); err != nil {
+		t.Fatal(err)
+	}
+	if err := def.ResolveConstraints(); err != nil {
+		t.Fatal(err)
+	}
+	// constrained fields not filled by mapping are checked as well
+	validator := def.newFieldValidator([]string{"user/tags[]"})
+	if validator == nil || len(validator.items) != 5 {
+		t.Fatal("validator should cover all constrained fields of user")
+	}
+	if def.newFieldValidator([]string{"group/name"}) != nil {
+		t.Fatal("validator should be nil when no constraint applies")
+	}
+
+	valid := modelinst.ModelInstHelper{}.WrapReadonlyMap(map[string]interface{}{
+		"user": map[string]interface{}{
+			"username": "user_01",
+			"email":    "user@example.com",
+			"age":      float64(30),
+			"role":     "admin",
+			"phone": []interface{}{
+				map[string]interface{}{"country_code": "86"},
+			},
+		},
+	})
+	if err := validator.Validate(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := modelinst.ModelInstHelper{}.WrapReadonlyMap(map[string]interface{}{
+		"user": map[string]interface{}{
+			"username": "A",
+			"email":    "not an email",
+			"age":      int64(200),
+			"role":     "guest",
+			"phone": []interface{}{
+				map[string]interface{}{"country_code": "86"},
+				map[string]interface{}{},
+			},
+		},
+	})
+	err := validator.Validate(invalid)
+	flowErr, ok := err.(*pluginapi.FlowError)
+	if !ok {
+		t.Fatal("error should be FlowError:", err)
+	}
+	if flowErr.Key != pluginapi.FlowErrorKeyValidationFailed {
+		t.Fatal("unexpected error key:", flowErr.Key)
+	}
+	expected := map[string]string{
+		"user/username":              "min_length",
+		"user/email":                 "format",
+		"user/age":                   "max",
+		"user/role":                  "enum",
+		"user/phone[1]/country_code": "required",
+	}
+	if len(flowErr.Details) != len(expected) {
+		t.Fatal("unexpected error details:", flowErr.Details)
+	}
+	for _, v := range flowErr.Details {
+		if expected[v.Path] != v.Rule {
+			t.Fatal("unexpected error detail:", v)
+		}
+	}
+
+	missing := modelinst.ModelInstHelper{}.WrapReadonlyMap(map[string]interface{}{})
+	if err := validator.Validate(missing); err == nil {
+		t.Fatal("required field missing should fail")
+	} else if details := err.(*pluginapi.FlowError).Details; len(details) != 1 || details[0].Path != "user/username" {
+		t.Fatal("unexpected error details:", details)
+	}
+}
+
+func TestLoadFlowModelConstraintsAfterPipelines(t *testing.T) {
+	c := newContainer(nil, "test")
+	c.pipelineMap["loaded"] = &Pipeline{}
+	if err := c.LoadFlowModel(constraintFlowModelContent); err == nil {
+		t.Fatal("FlowModel with constraints after pipelines should fail")
+	}
+	if c.flowModel.definitionOfPath("user/username") != nil || len(c.flowModelRawContents) != 0 {
+		t.Fatal("FlowModel should not be merged when rejected")
+	}
+	if err := c.LoadFlowModel("[model]\n\"group/name\" = \"string\"\n"); err != nil {
+		t.Fatal(err)
+	}
+}
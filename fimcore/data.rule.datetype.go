@@ -13,7 +13,8 @@ import (
 )
 
 type templateFlowModel struct {
-	Model map[string]string `toml:"model"`
+	Model       map[string]string           `toml:"model"`
+	Constraints map[string]*FieldConstraint `toml:"constraints"`
 }
 
 var primitiveType = map[pluginapi.DataType]struct{}{}
@@ -28,7 +29,10 @@ func init() {
 type DataTypeDefinitions struct {
 	pluginapi.DataType
 	PrimitiveArrayElementType pluginapi.DataType // exists only when the element data type is primitive
+	Constraint                *FieldConstraint   // exists only when constraints are defined on the primitive field
 	dataTypeMap               map[string]*DataTypeDefinitions
+	// pendingConstraints are constraints not resolved yet, see ResolveConstraints
+	pendingConstraints map[string]*FieldConstraint
 }
 
 func NewDataTypeDefinitions() *DataTypeDefinitions {
@@ -48,8 +52,7 @@ func newLastLevelDataTypeDefinitions() *DataTypeDefinitions {
 }
 
 func (d *DataTypeDefinitions) MergeToml(data string) error {
-	m := new(templateFlowModel)
-	err := toml.NewDecoder(bytes.NewBufferString(data)).DisallowUnknownFields().Decode(m)
+	m, err := parseFlowModelToml(data)
 	if err != nil {
 		return err
 	}
@@ -57,6 +60,23 @@ func (d *DataTypeDefinitions) MergeToml(data string) error {
 	return d.AddTypeDefinitions(m)
 }
 
+func parseFlowModelToml(data string) (*templateFlowModel, error) {
+	m := new(templateFlowModel)
+	if err := toml.NewDecoder(bytes.NewBufferString(data)).DisallowUnknownFields().Decode(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *templateFlowModel) hasConstraints() bool {
+	for _, c := range m.Constraints {
+		if c != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *DataTypeDefinitions) AddTypeDefinitions(m *templateFlowModel) error {
 	// validate
 	for path, dataTypeStr := range m.Model {
@@ -79,9 +99,29 @@ func (d *DataTypeDefinitions) AddTypeDefinitions(m *templateFlowModel) error {
 			return err
 		}
 	}
+	// constraints may refer to items of other FlowModel files, resolve them after all files are loaded
+	for path, c := range m.Constraints {
+		if c == nil {
+			continue
+		}
+		if _, ok := d.pendingConstraints[path]; ok {
+			return errors.New(fmt.Sprint("duplicated constraint of path:", path))
+		}
+		if d.pendingConstraints == nil {
+			d.pendingConstraints = map[string]*FieldConstraint{}
+		}
+		d.pendingConstraints[path] = c
+	}
 	return nil
 }
 
+// ResolveConstraints applies constraints of all merged FlowModel files, should be called after all files are merged
+func (d *DataTypeDefinitions) ResolveConstraints() error {
+	constraints := d.pendingConstraints
+	d.pendingConstraints = nil
+	return d.addConstraints(constraints)
+}
+
 func (d *DataTypeDefinitions) addTypeDefinitionOfPath(path string, dataTypeStr string) error {
 	var dataType pluginapi.DataType
 	switch dataTypeStr {
@@ -96,7 +136,31 @@ func (d *DataTypeDefinitions) addTypeDefinitionOfPath(path string, dataTypeStr s
 	default:
 		return errors.New(fmt.Sprint("unknown dataType:", dataTypeStr))
 	}
+	return d.addDataTypeOfPath(path, dataType)
+}
 
+// dataTypeName returns name of the data type used in definitions, e.g. string, int
+func dataTypeName(dataType pluginapi.DataType) string {
+	switch dataType {
+	case pluginapi.DataTypeString:
+		return "string"
+	case pluginapi.DataTypeInt:
+		return "int"
+	case pluginapi.DataTypeFloat:
+		return "float"
+	case pluginapi.DataTypeBool:
+		return "bool"
+	case pluginapi.DataTypeArray:
+		return "array"
+	case pluginapi.DataTypeObject:
+		return "object"
+	default:
+		return "unavailable"
+	}
+}
+
+// addDataTypeOfPath adds primitive data type of the path, data type is element data type for primitive array
+func (d *DataTypeDefinitions) addDataTypeOfPath(path string, dataType pluginapi.DataType) error {
 	paths := rule.SplitFullPath(path)
 	objMap := d.dataTypeMap
 	// process each level
@@ -174,6 +238,46 @@ func (d *DataTypeDefinitions) TypeOfPath(path string) (pluginapi.DataType, plugi
 	return d.typeOfPaths(splits)
 }
 
+// TypeOfDefinitionPath returns data type of the definition path(e.g. items[]/id, tags[]) and primitive array element data type
+// DataTypeUnavailable is returned if the path is not defined. Array levels should be consistent with the definitions.
+func (d *DataTypeDefinitions) TypeOfDefinitionPath(path string) (pluginapi.DataType, pluginapi.DataType, error) {
+	if !rule.ValidateFullPathOfDefinition(path) {
+		return pluginapi.DataTypeUnavailable, pluginapi.DataTypeUnavailable, errors.New(fmt.Sprint("path:", path, " illegal"))
+	}
+	dtd := d
+	for _, pLv := range rule.SplitFullPath(path) {
+		name, _ := rule.ExtractArrayPath(pLv)
+		subDtd, ok := dtd.dataTypeMap[name]
+		if !ok {
+			return pluginapi.DataTypeUnavailable, pluginapi.DataTypeUnavailable, nil
+		}
+		if rule.IsArrayDefinition(pLv) != (subDtd.DataType == pluginapi.DataTypeArray) {
+			return pluginapi.DataTypeUnavailable, pluginapi.DataTypeUnavailable, errors.New(fmt.Sprintf("array level mismatch of path:%s at level:%s", path, pLv))
+		}
+		dtd = subDtd
+	}
+	return dtd.DataType, dtd.PrimitiveArrayElementType, nil
+}
+
+// connectorPathTypes resolves primitive data types(element data types of primitive arrays) of connector side leaf paths
+// from the mapped paths defined in FlowModel. Paths not defined are skipped.
+func (d *DataTypeDefinitions) connectorPathTypes(connectorPaths, modelPaths []string) map[string]pluginapi.DataType {
+	r := map[string]pluginapi.DataType{}
+	for idx, path := range modelPaths {
+		dt, elemDt, err := d.TypeOfDefinitionPath(path)
+		if err != nil {
+			continue
+		}
+		if dt == pluginapi.DataTypeArray {
+			dt = elemDt
+		}
+		if _, ok := primitiveType[dt]; ok {
+			r[connectorPaths[idx]] = dt
+		}
+	}
+	return r
+}
+
 func (d *DataTypeDefinitions) typeOfPaths(paths []string) (pluginapi.DataType, pluginapi.DataType, error) {
 	dtd := d
 	isAccessArrElem := false
@@ -204,3 +308,25 @@ func (d *DataTypeDefinitions) typeOfPaths(paths []string) (pluginapi.DataType, p
 
 	return dataType, pDataType, nil
 }
+
+// leafPaths returns all leaf definition paths, e.g. user/name, user/tags[], items[]/id
+func (d *DataTypeDefinitions) leafPaths() []string {
+	var r []string
+	for name, sub := range d.dataTypeMap {
+		switch {
+		case sub.DataType == pluginapi.DataTypeObject:
+			for _, p := range sub.leafPaths() {
+				r = append(r, rule.ConcatFullPath([]string{name, p}))
+			}
+		case sub.DataType == pluginapi.DataTypeArray && sub.PrimitiveArrayElementType == pluginapi.DataTypeUnavailable:
+			for _, p := range sub.leafPaths() {
+				r = append(r, rule.ConcatFullPath([]string{name + "[]", p}))
+			}
+		case sub.DataType == pluginapi.DataTypeArray:
+			r = append(r, name+"[]")
+		default:
+			r = append(r, name)
+		}
+	}
+	return r
+}
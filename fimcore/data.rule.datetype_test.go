@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimcore/modelinst"
 )
 
 var flowModelFileContent = `
@@ -89,3 +90,62 @@ func AssertNonExistOfPath(t *testing.T, def *DataTypeDefinitions, path string) {
 		t.Fatalf("type of path:%s should not exist. context: %d %d %s", path, dt, pdt, err)
 	}
 }
+
+func TestConverterCompile(t *testing.T) {
+	def, err := loadDef()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dt, pdt, err := def.TypeOfDefinitionPath("user/risk/matrix[]/sub_matrix[]"); err != nil || dt != pluginapi.DataTypeArray || pdt != pluginapi.DataTypeFloat {
+		t.Fatal("unexpected type of definition path:", dt, pdt, err)
+	}
+	if _, _, err := def.TypeOfDefinitionPath("user/phone/area_code"); err == nil {
+		t.Fatal("array level mismatch should fail")
+	}
+
+	valid := modelinst.MappingRuleRaw{
+		{"user", "u", []interface{}{
+			[]interface{}{"user_id", "id"},
+			[]interface{}{"phone[]", "phones[]", []interface{}{
+				[]interface{}{"area_code", "area"},
+			}},
+			[]interface{}{"login", "", []interface{}{
+				[]interface{}{"lastLoginTime[]", "times[]", []interface{}{}},
+			}},
+		}},
+	}
+	c, err := valid.ToConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Compile(def, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range []modelinst.MappingRuleRaw{
+		{{"user", "u"}},
+		{{"user/phone[]", "p[]", []interface{}{}}},
+		{{"user", "u", []interface{}{[]interface{}{"login", "l"}}}},
+		{{"user", "u", []interface{}{[]interface{}{"user_id", "id", []interface{}{}}}}},
+	} {
+		c, err := invalid.ToConverter()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Compile(def, nil); err == nil {
+			t.Fatal("type mismatch should fail:", invalid)
+		}
+	}
+
+	// empty mapping, e.g. in = []
+	empty, err := modelinst.MappingRuleRaw{}.ToConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.Compile(def, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := empty.Transfer(modelinst.ModelInstHelper{}.NewInst(), modelinst.ModelInstHelper{}.NewInst()); err != nil {
+		t.Fatal("empty mapping should be compiled:", err)
+	}
+}
@@ -13,44 +13,60 @@ import (
 )
 
 type templateFlow struct {
-	In     modelinst.MappingRuleRaw              `toml:"in"`
-	Out    modelinst.MappingRuleRaw              `toml:"out"`
-	PreOut [][]string                            `toml:"pre_out"`
-	Flow   map[string][]map[string][]interface{} `toml:"flow"`
+	In      modelinst.MappingRuleRaw              `toml:"in"`
+	Out     modelinst.MappingRuleRaw              `toml:"out"`
+	PreOut  [][]string                            `toml:"pre_out"`
+	PostOut [][]string                            `toml:"post_out"`
+	Local   map[string]string                     `toml:"local"`
+	Flow    map[string][]map[string][]interface{} `toml:"flow"`
 }
 
 type Flow struct {
 	dtd       *DataTypeDefinitions
 	container *ContainerInst
 
-	inConverter           *modelinst.ModelConverter
-	outConverter          *modelinst.ModelConverter
-	localPreOutOperations map[string]struct {
-		Operation string
-		SplitPath []string
-	}
+	inConverter  *modelinst.ModelConverter
+	outConverter *modelinst.ModelConverter
+	// preOutOperations and postOutOperations are applied in the order of definitions before and after out mapping
+	preOutOperations  []preOutOperation
+	postOutOperations []preOutOperation
 
 	fnList []pluginapi.Fn
+
+	localSchema *modelinst.CompiledSchema
+
+	localDtd       *DataTypeDefinitions
+	localVariables []localVariable
+	// stepDtd is built on demand by stepDataTypes
+	stepDtd *DataTypeDefinitions
+}
+
+type preOutOperation struct {
+	Operation string
+	SplitPath []string
+	// Query is available when the path contains wildcards or predicates
+	Query *basicapi.Query
+	Fn    pluginapi.PreOutOperation
 }
 
 func NewFlow(dtd *DataTypeDefinitions, c *ContainerInst) *Flow {
 	return &Flow{
 		dtd:       dtd,
 		container: c,
-
-		localPreOutOperations: map[string]struct {
-			Operation string
-			SplitPath []string
-		}{},
 	}
 }
 
 func (f *Flow) mergeToml(tf *templateFlow) error {
 
+	if err := f.addLocal(tf.Local); err != nil {
+		return err
+	}
 	if inConverter, err := tf.In.ToConverter(); err != nil {
 		return err
 	} else if err := f.checkInDtd(inConverter.SourceLeafPathList); err != nil {
 		return err
+	} else if err := inConverter.Compile(f.dtd, f.localResolver()); err != nil {
+		return err
 	} else {
 		f.inConverter = inConverter
 	}
@@ -58,17 +74,20 @@ func (f *Flow) mergeToml(tf *templateFlow) error {
 		return err
 	} else if err := f.checkInDtd(outConverter.TargetLeafPathList); err != nil {
 		return err
+	} else if err := outConverter.Compile(f.localResolver(), f.dtd); err != nil {
+		return err
 	} else {
 		f.outConverter = outConverter
 	}
-	for _, paramPair := range tf.PreOut {
-		if len(paramPair) != 2 {
-			return errors.New("parameter pair should have 2 items")
-		}
-		// operation -> path
-		if err := f.addPreOut(paramPair[0], paramPair[1]); err != nil {
-			return err
-		}
+	if ops, err := f.newPreOutOperations("pre_out", tf.PreOut); err != nil {
+		return err
+	} else {
+		f.preOutOperations = ops
+	}
+	if ops, err := f.newPreOutOperations("post_out", tf.PostOut); err != nil {
+		return err
+	} else {
+		f.postOutOperations = ops
 	}
 	// validation
 	if err := f.validateRule(); err != nil {
@@ -96,15 +115,15 @@ func (f *Flow) validateRule() error {
 		if !ok {
 			continue
 		}
-		sdt, _, err := f.dtd.TypeOfPath(path)
+		sdt, sElemDt, err := f.dtd.TypeOfDefinitionPath(path)
 		if err != nil {
 			return err
 		}
-		ddt, _, err := f.dtd.TypeOfPath(oPath)
+		ddt, dElemDt, err := f.dtd.TypeOfDefinitionPath(oPath)
 		if err != nil {
 			return err
 		}
-		if sdt != ddt {
+		if sdt != ddt || sElemDt != dElemDt {
 			return errors.New(fmt.Sprintf("flow parameter=[%s] input and output mapping types are not the same", key))
 		}
 	}
@@ -116,7 +135,7 @@ func (f *Flow) checkInDtd(paths []string) error {
 		if !rule.ValidateFullPathOfDefinition(path) {
 			return errors.New("parameter path invalid:" + path)
 		}
-		if dt, _, err := f.dtd.TypeOfPath(path); err != nil {
+		if dt, _, err := f.dtd.TypeOfDefinitionPath(path); err != nil {
 			return err
 		} else if dt == pluginapi.DataTypeUnavailable {
 			return errors.New("cannot find path:" + path)
@@ -133,27 +152,54 @@ func (f *Flow) inConv() func(source, local modelinst.ModelInst2) error {
 
 func (f *Flow) outConv() func(local, out modelinst.ModelInst2) error {
 	return func(local, out modelinst.ModelInst2) error {
+		if err := f.checkLocalVariables(local); err != nil {
+			return err
+		}
 		// process pre_out
-		for _, op := range f.localPreOutOperations {
-			switch op.Operation {
-			case "@remove-object":
-				if err := out.RemoveObjectByPath(op.SplitPath); err != nil {
-					return err
-				}
-			default:
-				return errors.New("unknown pre_out operation:" + op.Operation)
-			}
+		if err := applyPreOutOperations(out, f.preOutOperations); err != nil {
+			return err
 		}
-
 		// process out
-		return f.outConverter.Transfer(local, out)
+		if err := f.outConverter.Transfer(local, out); err != nil {
+			return err
+		}
+		// process post_out
+		return applyPreOutOperations(out, f.postOutOperations)
+	}
+}
+
+// compileLocalModel compiles schema of local Model from in/out mappings
+// Flows with conflicting local paths keep using non-compiled Model
+func (f *Flow) compileLocalModel() {
+	var paths []string
+	paths = append(paths, f.inConverter.TargetLeafPathList...)
+	paths = append(paths, f.outConverter.SourceLeafPathList...)
+	if f.localDtd != nil {
+		paths = append(paths, f.localDtd.leafPaths()...)
+	}
+	if schema, err := modelinst.CompileSchema(paths); err == nil {
+		f.localSchema = schema
+	}
+}
+
+func (f *Flow) newLocalModel() modelinst.ModelInst2 {
+	if f.localSchema != nil {
+		return f.localSchema.NewInst()
+	}
+	return modelinst.ModelInstHelper{}.NewInst()
+}
+
+func (f *Flow) releaseLocalModel(local modelinst.ModelInst2) {
+	if f.localSchema != nil {
+		f.localSchema.Release(local)
 	}
 }
 
 func (f *Flow) FlowFn(casePreFn func(m pluginapi.Model) (bool, error)) func() func(global pluginapi.Model) error {
 	return func() func(global pluginapi.Model) error {
-		local := modelinst.ModelInstHelper{}.NewInst()
+		local := f.newLocalModel()
 		return func(global pluginapi.Model) error {
+			defer f.releaseLocalModel(local)
 			if casePreFn != nil {
 				val, err := casePreFn(global)
 				if err != nil {
@@ -186,8 +232,9 @@ func (f *Flow) FlowFn(casePreFn func(m pluginapi.Model) (bool, error)) func() fu
 
 func (f *Flow) FlowFnNoResp(casePreFn func(m pluginapi.Model) (bool, error)) func() func(global pluginapi.Model) error {
 	return func() func(global pluginapi.Model) error {
-		local := modelinst.ModelInstHelper{}.NewInst()
+		local := f.newLocalModel()
 		return func(global pluginapi.Model) error {
+			defer f.releaseLocalModel(local)
 			if casePreFn != nil {
 				val, err := casePreFn(global)
 				if err != nil {
@@ -227,6 +274,11 @@ func (f *Flow) addFlow(tf *templateFlow) error {
 		var wrapperFn func(fn pluginapi.Fn) pluginapi.Fn
 		for fn, params := range step {
 			// to make sure every step struct only contains one step, so overwrite may happen when duplicated definition
+			if schema, ok := f.container.fnSchemaMap[fn]; ok {
+				if err := validateFnParams(fn, schema, params, f.stepDataTypes()); err != nil {
+					return err
+				}
+			}
 			if strings.HasPrefix(fn, "@case-") {
 				f, err := f.prepareCaseClause(fn, params)
 				if err != nil {
@@ -235,11 +287,15 @@ func (f *Flow) addFlow(tf *templateFlow) error {
 				wrapperFn = f
 			} else if fn[0] == '@' {
 				//builtin function
-				fngen, ok := f.container.builtinGenFnMap[fn]
-				if !ok {
+				var fnInst pluginapi.Fn
+				var err error
+				if fngen, ok := f.container.builtinGenFnMap[fn]; ok {
+					fnInst, err = fngen(params)
+				} else if typedFngen, ok := f.container.builtinTypedGenFnMap[fn]; ok {
+					fnInst, err = typedFngen(f.stepDataTypes(), params)
+				} else {
 					return errors.New("builtin function not found:" + fn)
 				}
-				fnInst, err := fngen(params)
 				if err != nil {
 					return err
 				}
@@ -271,30 +327,163 @@ func (f *Flow) addFlow(tf *templateFlow) error {
 	return nil
 }
 
-func (f *Flow) addPreOut(op string, path string) error {
-	_, ok := f.localPreOutOperations[path]
-	if ok {
-		return errors.New("path registered:" + path)
+// newPreOutOperations creates operations of pre_out or post_out, format: [operation, path, parameters...]
+func (f *Flow) newPreOutOperations(phase string, items [][]string) ([]preOutOperation, error) {
+	var ops []preOutOperation
+	for _, params := range items {
+		if len(params) < 2 {
+			return nil, errors.New(phase + " should have operation and path")
+		}
+		op, err := f.newPreOutOperation(params[0], params[1], params[2:])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s operation=[%s] path=[%s] %s", phase, params[0], params[1], err))
+		}
+		ops = append(ops, op)
 	}
+	return ops, nil
+}
 
-	if !rule.ValidateFullPath(path) {
-		return errors.New("path invalid:" + path)
+func (f *Flow) newPreOutOperation(op string, path string, params []string) (preOutOperation, error) {
+	gen, ok := f.container.preOutOperationGenMap[op]
+	if !ok {
+		return preOutOperation{}, errors.New("unknown operation")
 	}
 
-	if dt, _, err := f.dtd.TypeOfPath(path); err != nil {
-		return err
-	} else if dt == pluginapi.DataTypeUnavailable {
-		return errors.New("cannot find path:" + path)
+	var definitionPath string
+	var query *basicapi.Query
+	dt := pluginapi.DataTypeUnavailable
+	if rule.IsQuery(path) {
+		// only levels before wildcards can be checked against FlowModel
+		q, err := basicapi.ParseQuery(path)
+		if err != nil {
+			return preOutOperation{}, err
+		}
+		prefix, complete := q.DefinitionPrefix()
+		if prefix != "" {
+			if pdt, _, err := f.dtd.TypeOfDefinitionPath(prefix); err != nil {
+				return preOutOperation{}, err
+			} else if pdt == pluginapi.DataTypeUnavailable {
+				return preOutOperation{}, errors.New("cannot find path:" + prefix)
+			}
+		}
+		if complete {
+			definitionPath = prefix
+		}
+		query = q
 	} else {
-		f.localPreOutOperations[path] = struct {
-			Operation string
-			SplitPath []string
-		}{
-			Operation: op,
-			SplitPath: rule.SplitFullPath(path),
+		if !rule.ValidateFullPath(path) {
+			return preOutOperation{}, errors.New("path invalid:" + path)
+		}
+		var err error
+		if dt, _, err = f.dtd.TypeOfPath(path); err != nil {
+			return preOutOperation{}, err
+		} else if dt == pluginapi.DataTypeUnavailable {
+			return preOutOperation{}, errors.New("cannot find path:" + path)
+		}
+		definitionPath = toDefinitionPath(path)
+	}
+
+	fn, err := gen(dt, params)
+	if err != nil {
+		return preOutOperation{}, err
+	}
+	if op == "@rename" {
+		if err := f.checkRenameTarget(definitionPath, params[0]); err != nil {
+			return preOutOperation{}, err
+		}
+	}
+	r := preOutOperation{
+		Operation: op,
+		Query:     query,
+		Fn:        fn,
+	}
+	if query == nil {
+		r.SplitPath = rule.SplitFullPath(path)
+	}
+	return r, nil
+}
+
+// toDefinitionPath converts array access levels to array definitions, e.g. items[0]/id -> items[]/id
+func toDefinitionPath(path string) string {
+	paths := rule.SplitFullPath(path)
+	for idx, pLv := range paths {
+		if rule.IsArrayAccess(pLv) {
+			name, _ := rule.ExtractArrayPath(pLv)
+			paths[idx] = name + "[]"
+		}
+	}
+	return rule.ConcatFullPath(paths)
+}
+
+// checkRenameTarget checks the renamed field is defined in FlowModel with the same data type
+func (f *Flow) checkRenameTarget(definitionPath string, name string) error {
+	if definitionPath == "" {
+		return errors.New("@rename requires the field of path rather than wildcards")
+	}
+	paths := rule.SplitFullPath(definitionPath)
+	last := paths[len(paths)-1]
+	dt, elemDt, err := f.dtd.TypeOfDefinitionPath(definitionPath)
+	if err != nil {
+		return err
+	}
+	paths[len(paths)-1] = name
+	if rule.IsArrayDefinition(last) {
+		paths[len(paths)-1] = name + "[]"
+	}
+	target := rule.ConcatFullPath(paths)
+	tdt, tElemDt, err := f.dtd.TypeOfDefinitionPath(target)
+	if err != nil {
+		return err
+	}
+	if tdt == pluginapi.DataTypeUnavailable {
+		return errors.New("cannot find path of renamed field:" + target)
+	}
+	if tdt != dt || tElemDt != elemDt {
+		return errors.New(fmt.Sprintf("renamed field=[%s] data type mismatch: expected=[%s] actual=[%s]", target, dataTypeName(dt), dataTypeName(tdt)))
+	}
+	if dt == pluginapi.DataTypeObject || dt == pluginapi.DataTypeArray {
+		// fields of the object or object array elements should match as well
+		for _, leaf := range f.dtd.definitionOfPath(definitionPath).leafPaths() {
+			tLeaf := target + pluginapi.PathSeparator + leaf
+			sLeaf := definitionPath + pluginapi.PathSeparator + leaf
+			sdt, sElemDt, _ := f.dtd.TypeOfDefinitionPath(sLeaf)
+			ldt, lElemDt, err := f.dtd.TypeOfDefinitionPath(tLeaf)
+			if err != nil {
+				return err
+			}
+			if ldt != sdt || lElemDt != sElemDt {
+				return errors.New("renamed field mismatches the definition of path:" + tLeaf)
+			}
+		}
+	}
+	return nil
+}
+
+func applyPreOutOperations(out modelinst.ModelInst2, ops []preOutOperation) error {
+	for _, op := range ops {
+		if op.Query != nil {
+			if err := applyPreOutByQuery(out, op); err != nil {
+				return err
+			}
+		} else if err := op.Fn(out, op.SplitPath); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
+// applyPreOutByQuery applies the operation to all matched fields or array elements
+// Matches are processed in reverse order so that indexes of remaining array elements are not changed by removals
+func applyPreOutByQuery(out modelinst.ModelInst2, op preOutOperation) error {
+	paths, err := op.Query.Select(out)
+	if err != nil {
+		return err
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := op.Fn(out, paths[i]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,162 @@
+package fimcore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+	"github.com/FimGroup/fim/fimcore/modelinst"
+)
+
+// localVariable is a typed temporary variable declared in [local] section of the flow
+// Variables are visible to steps of the flow only and discarded after out mapping
+type localVariable struct {
+	path     string
+	levels   []string
+	dataType pluginapi.DataType
+	// array is true for primitive array
+	array bool
+}
+
+// addLocal adds local variable definitions of the flow, format is the same as [model] of FlowModel
+// Variables are type checked before out mapping. Variables under object arrays are not supported.
+func (f *Flow) addLocal(local map[string]string) error {
+	if len(local) == 0 {
+		return nil
+	}
+	dtd := NewDataTypeDefinitions()
+	if err := dtd.AddTypeDefinitions(&templateFlowModel{Model: local}); err != nil {
+		return errors.New("local definition error:" + err.Error())
+	}
+	var paths []string
+	for path := range local {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		levels := rule.SplitFullPath(path)
+		for _, lv := range levels[:len(levels)-1] {
+			if rule.IsPathArray(lv) {
+				return errors.New("local variable under object array is not supported:" + path)
+			}
+		}
+		dt, elemDt, err := dtd.TypeOfDefinitionPath(path)
+		if err != nil {
+			return err
+		}
+		v := localVariable{
+			path:     path,
+			levels:   levels,
+			dataType: dt,
+		}
+		if dt == pluginapi.DataTypeArray {
+			v.levels[len(levels)-1], _ = rule.ExtractArrayPath(levels[len(levels)-1])
+			v.dataType = elemDt
+			v.array = true
+		}
+		f.localVariables = append(f.localVariables, v)
+	}
+	f.localDtd = dtd
+	return nil
+}
+
+// localResolver returns resolver of local variables, nil if no variable is declared
+func (f *Flow) localResolver() pluginapi.PathTypeResolver {
+	if f.localDtd == nil {
+		return nil
+	}
+	return f.localDtd
+}
+
+// checkLocalVariables checks data types of local variables after steps of the flow
+func (f *Flow) checkLocalVariables(local modelinst.ModelInst2) error {
+	for _, v := range f.localVariables {
+		if !v.array {
+			if err := checkLocalVariableType(local, v.path, v.levels, v.dataType); err != nil {
+				return err
+			}
+			continue
+		}
+		n, err := local.ArrayLength(v.levels)
+		if err != nil {
+			return errors.New(fmt.Sprintf("local variable=[%s] %s", v.path, err))
+		}
+		last := v.levels[len(v.levels)-1]
+		for i := 0; i < n; i++ {
+			levels := append(append([]string{}, v.levels[:len(v.levels)-1]...), fmt.Sprintf("%s[%d]", last, i))
+			if err := checkLocalVariableType(local, v.path, levels, v.dataType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkLocalVariableType(local modelinst.ModelInst2, path string, levels []string, dataType pluginapi.DataType) error {
+	dt, err := local.FieldType(levels)
+	if err != nil {
+		return errors.New(fmt.Sprintf("local variable=[%s] %s", path, err))
+	}
+	if dt != pluginapi.DataTypeUnavailable && dt != dataType {
+		return errors.New(fmt.Sprintf("local variable=[%s] data type mismatch: expected=[%s] actual=[%s]", path, dataTypeName(dataType), dataTypeName(dt)))
+	}
+	return nil
+}
+
+// stepDataTypes returns data types of the Model of steps, which are built from in/out mappings and local variables
+// Paths with conflicting data types are not defined
+func (f *Flow) stepDataTypes() *DataTypeDefinitions {
+	if f.stepDtd != nil {
+		return f.stepDtd
+	}
+	types := map[string]pluginapi.DataType{}
+	conflicts := map[string]struct{}{}
+	addType := func(path string, dt pluginapi.DataType) {
+		if existing, ok := types[path]; ok && existing != dt {
+			conflicts[path] = struct{}{}
+		}
+		types[path] = dt
+	}
+	addMapping := func(localPaths, flowModelPaths []string) {
+		for idx, path := range localPaths {
+			dt, elemDt, err := f.dtd.TypeOfDefinitionPath(flowModelPaths[idx])
+			if err != nil {
+				continue
+			}
+			if dt == pluginapi.DataTypeArray {
+				dt = elemDt
+			}
+			if _, ok := primitiveType[dt]; ok {
+				addType(path, dt)
+			}
+		}
+	}
+	addMapping(f.inConverter.TargetLeafPathList, f.inConverter.SourceLeafPathList)
+	addMapping(f.outConverter.SourceLeafPathList, f.outConverter.TargetLeafPathList)
+	if f.localDtd != nil {
+		for _, path := range f.localDtd.leafPaths() {
+			dt, elemDt, _ := f.localDtd.TypeOfDefinitionPath(path)
+			if dt == pluginapi.DataTypeArray {
+				dt = elemDt
+			}
+			addType(path, dt)
+		}
+	}
+
+	var paths []string
+	for path := range types {
+		if _, ok := conflicts[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	dtd := NewDataTypeDefinitions()
+	for _, path := range paths {
+		// paths conflicting with defined levels, e.g. object and array of the same name, are ignored
+		_ = dtd.addDataTypeOfPath(path, types[path])
+	}
+	f.stepDtd = dtd
+	return dtd
+}
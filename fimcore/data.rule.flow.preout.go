@@ -0,0 +1,209 @@
+package fimcore
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+// Builtin pre_out operations
+// Format of pre_out item: [operation, path, parameters...]
+func newBuiltinPreOutOperationGenMap() map[string]pluginapi.PreOutOperationGen {
+	return map[string]pluginapi.PreOutOperationGen{
+		"@remove-object": preOutRemoveObject,
+		"@remove-field":  preOutRemoveField,
+		"@clear-array":   preOutClearArray,
+		"@set-default":   preOutSetDefault,
+		"@rename":        preOutRename,
+		"@mask":          preOutMask,
+	}
+}
+
+func requirePreOutParams(name string, params []string, cnt int) error {
+	if len(params) != cnt {
+		return errors.New(fmt.Sprintf("%s requires %d parameters after path", name, cnt))
+	}
+	return nil
+}
+
+// preOutRemoveObject removes field, object or array of the path
+func preOutRemoveObject(dataType pluginapi.DataType, params []string) (pluginapi.PreOutOperation, error) {
+	if err := requirePreOutParams("@remove-object", params, 0); err != nil {
+		return nil, err
+	}
+	return func(m basicapi.Model, path []string) error {
+		return m.DeleteField(path)
+	}, nil
+}
+
+// preOutRemoveField removes primitive field of the path
+func preOutRemoveField(dataType pluginapi.DataType, params []string) (pluginapi.PreOutOperation, error) {
+	if err := requirePreOutParams("@remove-field", params, 0); err != nil {
+		return nil, err
+	}
+	if dataType == pluginapi.DataTypeObject || dataType == pluginapi.DataTypeArray {
+		return nil, errors.New("@remove-field requires primitive field, use @remove-object instead")
+	}
+	return func(m basicapi.Model, path []string) error {
+		dt, err := m.FieldType(path)
+		if err != nil {
+			return err
+		}
+		switch dt {
+		case pluginapi.DataTypeUnavailable:
+			return nil
+		case pluginapi.DataTypeObject, pluginapi.DataTypeArray:
+			return errors.New("@remove-field requires primitive field:" + rule.ConcatFullPath(path))
+		}
+		return m.DeleteField(path)
+	}, nil
+}
+
+// preOutClearArray removes all elements of the array and keeps the empty array
+func preOutClearArray(dataType pluginapi.DataType, params []string) (pluginapi.PreOutOperation, error) {
+	if err := requirePreOutParams("@clear-array", params, 0); err != nil {
+		return nil, err
+	}
+	if dataType != pluginapi.DataTypeUnavailable && dataType != pluginapi.DataTypeArray {
+		return nil, errors.New("@clear-array requires array")
+	}
+	return func(m basicapi.Model, path []string) error {
+		n, err := m.ArrayLength(path)
+		if err != nil {
+			return err
+		}
+		last := path[len(path)-1]
+		elemPath := append([]string{}, path...)
+		for i := n - 1; i >= 0; i-- {
+			elemPath[len(elemPath)-1] = last + "[" + strconv.Itoa(i) + "]"
+			if err := m.DeleteField(elemPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// parsePreOutValue parses literal of the parameter according to the data type in FlowModel
+func parsePreOutValue(dataType pluginapi.DataType, literal string) (interface{}, error) {
+	switch dataType {
+	case pluginapi.DataTypeString:
+		return literal, nil
+	case pluginapi.DataTypeInt:
+		return strconv.ParseInt(literal, 10, 64)
+	case pluginapi.DataTypeFloat:
+		return strconv.ParseFloat(literal, 64)
+	case pluginapi.DataTypeBool:
+		return strconv.ParseBool(literal)
+	default:
+		return nil, errors.New("default value requires primitive field")
+	}
+}
+
+// preOutSetDefault sets the value if the field does not exist, e.g. ["@set-default", "user/nickname", "guest"]
+func preOutSetDefault(dataType pluginapi.DataType, params []string) (pluginapi.PreOutOperation, error) {
+	if err := requirePreOutParams("@set-default", params, 1); err != nil {
+		return nil, err
+	}
+	if dataType == pluginapi.DataTypeUnavailable {
+		dataType = pluginapi.DataTypeString
+	}
+	value, err := parsePreOutValue(dataType, params[0])
+	if err != nil {
+		return nil, err
+	}
+	return func(m basicapi.Model, path []string) error {
+		dt, err := m.FieldType(path)
+		if err != nil {
+			return err
+		}
+		if dt != pluginapi.DataTypeUnavailable {
+			return nil
+		}
+		return m.AddOrUpdateField0(path, value)
+	}, nil
+}
+
+// preOutRename moves the field, object or array to the new name at the same level, e.g. ["@rename", "user/nick", "nickname"]
+// The new name should be defined in FlowModel with the same data type, which is checked by the flow
+func preOutRename(dataType pluginapi.DataType, params []string) (pluginapi.PreOutOperation, error) {
+	if err := requirePreOutParams("@rename", params, 1); err != nil {
+		return nil, err
+	}
+	name := params[0]
+	if !rule.ValidateFullPath(name) || strings.Contains(name, pluginapi.PathSeparator) || rule.IsPathArray(name) {
+		return nil, errors.New("@rename requires field name:" + name)
+	}
+	return func(m basicapi.Model, path []string) error {
+		dt, err := m.FieldType(path)
+		if err != nil {
+			return err
+		}
+		if dt == pluginapi.DataTypeUnavailable {
+			return nil
+		}
+		target := append(append([]string{}, path[:len(path)-1]...), name)
+		if dt == pluginapi.DataTypeObject || dt == pluginapi.DataTypeArray {
+			obj, err := basicapi.GetGeneralObject(m, path)
+			if err != nil {
+				return err
+			}
+			if err := m.DeleteField(target); err != nil {
+				return err
+			}
+			if err := basicapi.PutGeneralObject(m, target, obj); err != nil {
+				return err
+			}
+		} else {
+			v, err := m.GetField(path)
+			if err != nil {
+				return err
+			}
+			if err := m.AddOrUpdateField0(target, v); err != nil {
+				return err
+			}
+		}
+		return m.DeleteField(path)
+	}, nil
+}
+
+// preOutMask replaces characters of string except the last n(default 4) with '*', e.g. ["@mask", "user/phone", "4"]
+func preOutMask(dataType pluginapi.DataType, params []string) (pluginapi.PreOutOperation, error) {
+	if len(params) > 1 {
+		return nil, errors.New("@mask requires at most 1 parameter after path")
+	}
+	if dataType != pluginapi.DataTypeUnavailable && dataType != pluginapi.DataTypeString {
+		return nil, errors.New("@mask requires string field")
+	}
+	keep := 4
+	if len(params) == 1 {
+		n, err := strconv.Atoi(params[0])
+		if err != nil || n < 0 {
+			return nil, errors.New("@mask parameter should be non-negative integer:" + params[0])
+		}
+		keep = n
+	}
+	return func(m basicapi.Model, path []string) error {
+		v, err := m.GetField(path)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			return nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return errors.New("@mask requires string field:" + rule.ConcatFullPath(path))
+		}
+		runes := []rune(s)
+		for i := 0; i < len(runes)-keep; i++ {
+			runes[i] = '*'
+		}
+		return m.AddOrUpdateField0(path, string(runes))
+	}, nil
+}
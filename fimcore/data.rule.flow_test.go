@@ -0,0 +1,433 @@
+package fimcore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimcore/modelinst"
+)
+
+const localFlowContent = `
+in = [
+  ["user", "", [
+    ["username", "name"],
+  ]],
+]
+out = [
+  ["", "user", [
+    ["greeting", "nickname"],
+  ]],
+]
+[local]
+"greeting" = "string"
+"counter" = "int"
+"scratch/tags[]" = "string"
+[flow]
+steps = [
+  { "#greet" = [] },
+]
+`
+
+func TestFlowLocalVariables(t *testing.T) {
+	def, err := loadDef()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newContainer(nil, "test")
+	c.flowModel = def
+	var counter interface{}
+	if err := c.RegisterCustomFn("#greet", func(params []interface{}) (pluginapi.Fn, error) {
+		return func(m pluginapi.Model) error {
+			counter = m.GetFieldUnsafe0([]string{"counter"})
+			if err := m.AddOrUpdateField0([]string{"scratch", "tags[0]"}, "x"); err != nil {
+				return err
+			}
+			return m.AddOrUpdateField0([]string{"greeting"}, "hello "+m.GetFieldUnsafe0([]string{"name"}).(string))
+		}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tf := new(templateFlow)
+	if err := toml.NewDecoder(bytes.NewBufferString(localFlowContent)).DisallowUnknownFields().Decode(tf); err != nil {
+		t.Fatal(err)
+	}
+	f := NewFlow(def, c)
+	if err := f.mergeToml(tf); err != nil {
+		t.Fatal(err)
+	}
+	global := modelinst.ModelInstHelper{}.NewInst()
+	if err := global.AddOrUpdateField0([]string{"user", "username"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.FlowFn(nil)()(global); err != nil {
+		t.Fatal(err)
+	}
+	if v := global.GetFieldUnsafe0([]string{"user", "nickname"}); v != "hello alice" {
+		t.Fatal("unexpected output:", v)
+	}
+	if counter != nil {
+		t.Fatal("local variable should not be initialized:", counter)
+	}
+	if v := global.GetFieldUnsafe0([]string{"greeting"}); v != nil {
+		t.Fatal("local variable should not be visible in global model:", v)
+	}
+
+	// type mismatch of local variable
+	mismatch := NewFlow(def, c)
+	tf.Out = modelinst.MappingRuleRaw{{"", "user", []interface{}{[]interface{}{"counter", "nickname"}}}}
+	if err := mismatch.mergeToml(tf); err == nil {
+		t.Fatal("out mapping of int local variable to string field should fail")
+	}
+
+	// data type checked after steps
+	local := modelinst.ModelInstHelper{}.NewInst()
+	if err := local.AddOrUpdateField0([]string{"counter"}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.checkLocalVariables(local); err == nil || !strings.Contains(err.Error(), "expected=[int] actual=[string]") {
+		t.Fatal("unexpected type check error:", err)
+	}
+
+	// local variables under object arrays
+	if err := NewFlow(def, c).addLocal(map[string]string{"items[]/id": "int"}); err == nil {
+		t.Fatal("local variable under object array should fail")
+	}
+}
+
+func TestFlowBuiltinTypedFn(t *testing.T) {
+	def, err := loadDef()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newContainer(nil, "test")
+	c.flowModel = def
+	var resolver pluginapi.PathTypeResolver
+	if err := c.RegisterBuiltinTypedFn("@types", func(r pluginapi.PathTypeResolver, params []interface{}) (pluginapi.Fn, error) {
+		resolver = r
+		return func(m pluginapi.Model) error {
+			return nil
+		}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterBuiltinFn("@types", nil); err == nil {
+		t.Fatal("typed function name should not be registered again")
+	}
+
+	tf := new(templateFlow)
+	if err := toml.NewDecoder(bytes.NewBufferString(strings.Replace(localFlowContent, `"#greet"`, `"@types"`, 1))).DisallowUnknownFields().Decode(tf); err != nil {
+		t.Fatal(err)
+	}
+	tf.In = append(tf.In, []interface{}{"user", "account", []interface{}{
+		[]interface{}{"phone[]", "phones[]", []interface{}{[]interface{}{"country_code", "code"}}},
+		[]interface{}{"login", "login", []interface{}{[]interface{}{"lastLoginTime[]", "times[]", []interface{}{}}}},
+	}})
+	f := NewFlow(def, c)
+	if err := f.mergeToml(tf); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string][2]pluginapi.DataType{
+		"name":                  {pluginapi.DataTypeString, pluginapi.DataTypeUnavailable},
+		"counter":               {pluginapi.DataTypeInt, pluginapi.DataTypeUnavailable},
+		"account":               {pluginapi.DataTypeObject, pluginapi.DataTypeUnavailable},
+		"account/phones[]":      {pluginapi.DataTypeArray, pluginapi.DataTypeUnavailable},
+		"account/phones[]/code": {pluginapi.DataTypeString, pluginapi.DataTypeUnavailable},
+		"account/login/times[]": {pluginapi.DataTypeArray, pluginapi.DataTypeInt},
+		"unknown":               {pluginapi.DataTypeUnavailable, pluginapi.DataTypeUnavailable},
+	} {
+		dt, elemDt, err := resolver.TypeOfDefinitionPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dt != expected[0] || elemDt != expected[1] {
+			t.Fatal("unexpected data types of path:", path, dt, elemDt)
+		}
+	}
+}
+
+const primitiveArrayFlowContent = `
+in = [
+  ["order", "", [
+    ["tags[]", "labels[]", []],
+  ]],
+]
+out = [
+  ["", "order", [
+    ["labels[]", "tags[]", []],
+  ]],
+]
+[flow]
+steps = [
+  { "#label" = [] },
+]
+`
+
+// primitive arrays with empty mapping rules are leaves of in/out mappings
+func TestFlowPrimitiveArrayMapping(t *testing.T) {
+	def := NewDataTypeDefinitions()
+	if err := def.MergeToml(`
+[model]
+"order/tags[]" = "string"
+"order/codes[]" = "int"
+`); err != nil {
+		t.Fatal(err)
+	}
+	c := newContainer(nil, "test")
+	c.flowModel = def
+	if err := c.RegisterCustomFn("#label", func(params []interface{}) (pluginapi.Fn, error) {
+		return func(m pluginapi.Model) error {
+			_, err := m.AppendArrayElement([]string{"labels"}, "checked")
+			return err
+		}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	newTemplateFlow := func(content string) *templateFlow {
+		tf := new(templateFlow)
+		if err := toml.NewDecoder(bytes.NewBufferString(content)).DisallowUnknownFields().Decode(tf); err != nil {
+			t.Fatal(err)
+		}
+		return tf
+	}
+
+	f := NewFlow(def, c)
+	if err := f.mergeToml(newTemplateFlow(primitiveArrayFlowContent)); err != nil {
+		t.Fatal(err)
+	}
+	f.compileLocalModel()
+	if f.localSchema == nil {
+		t.Fatal("local Model should be compiled with primitive array paths")
+	}
+	global := modelinst.ModelInstHelper{}.NewInst()
+	if _, err := global.AppendArrayElement([]string{"order", "tags"}, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.FlowFn(nil)()(global); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := global.ArrayLength([]string{"order", "tags"}); n != 2 || global.GetFieldUnsafe0([]string{"order", "tags[1]"}) != "checked" {
+		t.Fatal("unexpected output:", global.ToGeneralObject())
+	}
+
+	// primitive arrays should be defined in FlowModel
+	if err := NewFlow(def, c).mergeToml(newTemplateFlow(strings.Replace(primitiveArrayFlowContent, `["tags[]", "labels[]", []]`, `["unknown[]", "labels[]", []]`, 1))); err == nil || !strings.Contains(err.Error(), "cannot find path:order/unknown[]") {
+		t.Fatal("undefined primitive array should fail:", err)
+	}
+	// element types of in/out mappings should be the same
+	if err := NewFlow(def, c).mergeToml(newTemplateFlow(strings.Replace(primitiveArrayFlowContent, `["labels[]", "tags[]", []]`, `["labels[]", "codes[]", []]`, 1))); err == nil || !strings.Contains(err.Error(), "input and output mapping types are not the same") {
+		t.Fatal("primitive arrays of different element types should fail:", err)
+	}
+}
+
+func TestFlowFnSchema(t *testing.T) {
+	def, err := loadDef()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newContainer(nil, "test")
+	c.flowModel = def
+	noop := func(params []interface{}) (pluginapi.Fn, error) {
+		return func(m pluginapi.Model) error {
+			return nil
+		}, nil
+	}
+	if err := c.RegisterCustomFnWithSchema("#repeat", pluginapi.FnSchema{
+		Description: "Repeats the source",
+		Params: []pluginapi.ParamSchema{
+			{Name: "source", Kind: pluginapi.ParamKindPath, MustExist: true},
+			{Name: "target", Kind: pluginapi.ParamKindPath},
+			{Name: "times", Kind: pluginapi.ParamKindInt, Optional: true},
+		},
+	}, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterBuiltinFnWithSchema("@join", pluginapi.FnSchema{
+		Params: []pluginapi.ParamSchema{
+			{Name: "sources", Kind: pluginapi.ParamKindPath, Variadic: true},
+			{Name: "target", Kind: pluginapi.ParamKindPath},
+		},
+	}, noop); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterBuiltinTypedFnWithSchema("@format", pluginapi.FnSchema{
+		Params: []pluginapi.ParamSchema{
+			{Name: "format", Kind: pluginapi.ParamKindString},
+			{Name: "args", Kind: pluginapi.ParamKindPath, Optional: true, Variadic: true},
+			{Name: "target", Kind: pluginapi.ParamKindPath, MustExist: true},
+		},
+	}, func(resolver pluginapi.PathTypeResolver, params []interface{}) (pluginapi.Fn, error) {
+		return noop(params)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterBuiltinFnWithSchema("@invalid", pluginapi.FnSchema{
+		Params: []pluginapi.ParamSchema{
+			{Name: "optional", Kind: pluginapi.ParamKindString, Optional: true},
+			{Name: "required", Kind: pluginapi.ParamKindString},
+		},
+	}, noop); err == nil {
+		t.Fatal("required parameter after optional parameter should fail")
+	}
+
+	for steps, expectedErr := range map[string]string{
+		`{ "#repeat" = ["name", "greeting"] }`:                  "",
+		`{ "#repeat" = ["scratch/tags[0]", "greeting", 2] }`:    "",
+		`{ "@join" = ["name", "counter", "greeting"] }`:         "",
+		`{ "#repeat" = ["name"] }`:                              "requires 2 to 3 parameters(source, target, [times]) but got 1",
+		`{ "#repeat" = ["name", "greeting", "2"] }`:             "parameter 2(times) should be int",
+		`{ "#repeat" = [1, "greeting"] }`:                       "parameter 0(source) should be path",
+		`{ "#repeat" = ["unknown", "greeting"] }`:               "parameter 0(source) path is not defined",
+		`{ "#repeat" = ["name", "greeting//"] }`:                "parameter 1(target) path invalid",
+		`{ "@join" = ["greeting"] }`:                            "requires at least 2 parameters",
+		`{ "@join" = ["name", true, "counter", "greeting"] }`:   "parameter 1(sources) should be path",
+		`{ "@format" = ["%s", "greeting"] }`:                    "",
+		`{ "@format" = ["%s-%s", "name", "name", "greeting"] }`: "",
+		`{ "@format" = ["%s", "unknown"] }`:                     "parameter 1(target) path is not defined",
+	} {
+		tf := new(templateFlow)
+		if err := toml.NewDecoder(bytes.NewBufferString(strings.Replace(localFlowContent, `{ "#greet" = [] }`, steps, 1))).DisallowUnknownFields().Decode(tf); err != nil {
+			t.Fatal(err)
+		}
+		err := NewFlow(def, c).mergeToml(tf)
+		if expectedErr == "" && err != nil {
+			t.Fatal(steps, err)
+		} else if expectedErr != "" && (err == nil || !strings.Contains(err.Error(), expectedErr)) {
+			t.Fatal(steps, "expected error:", expectedErr, "actual:", err)
+		}
+	}
+
+	doc := c.FnReferenceDoc()
+	if !strings.Contains(doc, "##### `#repeat`") || !strings.Contains(doc, "`[sources..., target]`") {
+		t.Fatal("unexpected reference doc:", doc)
+	}
+}
+
+const preOutFlowContent = `
+in = [
+  ["user", "", [
+    ["password", "secret"],
+  ]],
+]
+out = [
+  ["", "user", [
+    ["secret", "password"],
+  ]],
+]
+pre_out = [
+  ["@clear-array", "user/login/lastLoginTime"],
+]
+post_out = [
+  ["@set-default", "user/nickname", "guest"],
+  ["@upper", "user/nickname"],
+  ["@mask", "user/password", "2"],
+  ["@remove-field", "user/is_login"],
+  ["@rename", "user/risk", "risk_info"],
+]
+[local]
+"secret" = "string"
+[flow]
+steps = []
+`
+
+func TestFlowPreOutOperations(t *testing.T) {
+	def, err := loadDef()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := def.MergeToml(`
+[model]
+"user/risk_info/rating_core" = "float"
+"user/risk_info/matrix[]/sub_matrix[]" = "float"
+`); err != nil {
+		t.Fatal(err)
+	}
+	c := newContainer(nil, "test")
+	c.flowModel = def
+	if err := c.RegisterPreOutOperation("@upper", func(dataType pluginapi.DataType, params []string) (pluginapi.PreOutOperation, error) {
+		return func(m pluginapi.Model, path []string) error {
+			return m.AddOrUpdateField0(path, strings.ToUpper(m.GetFieldUnsafe0(path).(string)))
+		}, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterPreOutOperation("@upper", nil); err == nil {
+		t.Fatal("duplicated pre_out operation should fail")
+	}
+
+	tf := new(templateFlow)
+	if err := toml.NewDecoder(bytes.NewBufferString(preOutFlowContent)).DisallowUnknownFields().Decode(tf); err != nil {
+		t.Fatal(err)
+	}
+	f := NewFlow(def, c)
+	if err := f.mergeToml(tf); err != nil {
+		t.Fatal(err)
+	}
+	global := modelinst.ModelInstHelper{}.NewInst()
+	for _, field := range []struct {
+		path  []string
+		value interface{}
+	}{
+		{[]string{"user", "password"}, "secret"},
+		{[]string{"user", "is_login"}, true},
+		{[]string{"user", "login", "lastLoginTime[0]"}, int64(1)},
+		{[]string{"user", "login", "lastLoginTime[1]"}, int64(2)},
+		{[]string{"user", "risk", "rating_core"}, 1.5},
+	} {
+		if err := global.AddOrUpdateField0(field.path, field.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.FlowFn(nil)()(global); err != nil {
+		t.Fatal(err)
+	}
+	if v := global.GetFieldUnsafe0([]string{"user", "nickname"}); v != "GUEST" {
+		t.Fatal("post_out operations should be applied in order:", v)
+	}
+	if v := global.GetFieldUnsafe0([]string{"user", "password"}); v != "****et" {
+		t.Fatal("post_out operations should be applied after out mapping:", v)
+	}
+	if v := global.GetFieldUnsafe0([]string{"user", "is_login"}); v != nil {
+		t.Fatal("field should be removed:", v)
+	}
+	if n, err := global.ArrayLength([]string{"user", "login", "lastLoginTime"}); err != nil || n != 0 {
+		t.Fatal("array should be cleared:", n, err)
+	}
+	if v := global.GetFieldUnsafe0([]string{"user", "risk_info", "rating_core"}); v != 1.5 {
+		t.Fatal("object should be renamed:", v)
+	}
+	if v := global.GetFieldUnsafe0([]string{"user", "risk", "rating_core"}); v != nil {
+		t.Fatal("renamed object should be removed:", v)
+	}
+
+	// renamed field should be defined in FlowModel with the same data type
+	tf.PreOut = nil
+	tf.PostOut = [][]string{{"@rename", "user/nickname", "nick"}}
+	if err := NewFlow(def, c).mergeToml(tf); err == nil {
+		t.Fatal("rename to undefined field should fail")
+	}
+	tf.PostOut = [][]string{{"@rename", "user/is_login", "nickname"}}
+	if err := NewFlow(def, c).mergeToml(tf); err == nil {
+		t.Fatal("rename to field of different data type should fail")
+	}
+
+	// invalid parameters of builtin operation
+	tf.PostOut = nil
+	tf.PreOut = [][]string{{"@set-default", "user/user_id", "abc"}}
+	if err := NewFlow(def, c).mergeToml(tf); err == nil {
+		t.Fatal("invalid default value of int field should fail")
+	}
+	// levels before wildcards of queries are checked against FlowModel
+	tf.PreOut = [][]string{{"@remove-field", "user/unknown/*"}}
+	if err := NewFlow(def, c).mergeToml(tf); err == nil {
+		t.Fatal("query of undefined path should fail")
+	}
+	tf.PreOut = [][]string{{"@remove-field", "user/*/lastLoginTime"}}
+	if err := NewFlow(def, c).mergeToml(tf); err != nil {
+		t.Fatal(err)
+	}
+}
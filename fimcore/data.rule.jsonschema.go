@@ -0,0 +1,492 @@
+package fimcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+const (
+	JsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+)
+
+// ToJsonSchema exports the definitions as JSON Schema(draft 2020-12)
+// Note: constraints on array definitions(xxx[]) are exported to the items of the array
+func (d *DataTypeDefinitions) ToJsonSchema() ([]byte, error) {
+	schema, err := d.toJsonSchemaObject()
+	if err != nil {
+		return nil, err
+	}
+	schema["$schema"] = JsonSchemaDialect
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func (d *DataTypeDefinitions) toJsonSchemaObject() (map[string]interface{}, error) {
+	switch d.DataType {
+	case pluginapi.DataTypeObject:
+		properties := map[string]interface{}{}
+		var required []string
+		for name, sub := range d.dataTypeMap {
+			s, err := sub.toJsonSchemaObject()
+			if err != nil {
+				return nil, err
+			}
+			properties[name] = s
+			if sub.Constraint != nil && sub.Constraint.Required && sub.DataType != pluginapi.DataTypeArray {
+				required = append(required, name)
+			}
+		}
+		schema := map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+		if len(required) > 0 {
+			sort.Strings(required)
+			schema["required"] = required
+		}
+		return schema, nil
+	case pluginapi.DataTypeArray:
+		var items map[string]interface{}
+		if d.PrimitiveArrayElementType != pluginapi.DataTypeUnavailable {
+			items = primitiveJsonSchema(d.PrimitiveArrayElementType, d.Constraint)
+		} else {
+			elem := &DataTypeDefinitions{
+				DataType:    pluginapi.DataTypeObject,
+				dataTypeMap: d.dataTypeMap,
+			}
+			s, err := elem.toJsonSchemaObject()
+			if err != nil {
+				return nil, err
+			}
+			items = s
+		}
+		return map[string]interface{}{
+			"type":  "array",
+			"items": items,
+		}, nil
+	default:
+		if _, ok := primitiveType[d.DataType]; !ok {
+			return nil, errors.New(fmt.Sprint("unknown data type for json schema:", d.DataType))
+		}
+		return primitiveJsonSchema(d.DataType, d.Constraint), nil
+	}
+}
+
+func primitiveJsonSchema(dataType pluginapi.DataType, c *FieldConstraint) map[string]interface{} {
+	schema := map[string]interface{}{}
+	switch dataType {
+	case pluginapi.DataTypeString:
+		schema["type"] = "string"
+	case pluginapi.DataTypeInt:
+		schema["type"] = "integer"
+	case pluginapi.DataTypeFloat:
+		schema["type"] = "number"
+	case pluginapi.DataTypeBool:
+		schema["type"] = "boolean"
+	}
+	if c == nil {
+		return schema
+	}
+	if c.Min != nil {
+		schema["minimum"] = c.Min
+	}
+	if c.Max != nil {
+		schema["maximum"] = c.Max
+	}
+	if c.MinLength != nil {
+		schema["minLength"] = *c.MinLength
+	}
+	if c.MaxLength != nil {
+		schema["maxLength"] = *c.MaxLength
+	}
+	if c.Pattern != "" {
+		schema["pattern"] = c.Pattern
+	}
+	if len(c.Enum) > 0 {
+		schema["enum"] = c.Enum
+	}
+	if c.Format != "" {
+		schema["format"] = c.Format
+	}
+	return schema
+}
+
+// ConvertJsonSchemaToFlowModel converts an object JSON Schema(draft 2020-12) into FlowModel toml content
+// Fields will be placed under basePath, empty basePath means top level of the model
+// Local references($ref) to $defs/definitions are supported
+func ConvertJsonSchemaToFlowModel(schema []byte, basePath string) (string, error) {
+	root, err := decodeJsonSchemaDocument(schema)
+	if err != nil {
+		return "", err
+	}
+	return convertJsonSchema(root, root, basePath)
+}
+
+// ConvertOpenApiJsonToFlowModel converts a schema in components section of OpenAPI 3 document into FlowModel toml content
+// Only json documents are supported, yaml documents should be converted to json first
+// Fields will be placed under basePath, empty basePath means top level of the model
+func ConvertOpenApiJsonToFlowModel(document []byte, schemaName, basePath string) (string, error) {
+	if trimmed := bytes.TrimSpace(document); len(trimmed) > 0 && trimmed[0] != '{' {
+		return "", errors.New("OpenAPI document should be json object, yaml documents should be converted to json first")
+	}
+	root, err := decodeJsonSchemaDocument(document)
+	if err != nil {
+		return "", err
+	}
+	schema, err := resolveJsonPointer(root, "#/components/schemas/"+schemaName)
+	if err != nil {
+		return "", err
+	}
+	return convertJsonSchema(root, schema, basePath)
+}
+
+func decodeJsonSchemaDocument(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var root map[string]interface{}
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func convertJsonSchema(root, schema map[string]interface{}, basePath string) (string, error) {
+	if basePath != "" && !rule.ValidateFullPathOfDefinition(basePath) {
+		return "", errors.New("invalid base path:" + basePath)
+	}
+	c := &jsonSchemaConverter{
+		root:        root,
+		model:       map[string]string{},
+		constraints: map[string]*FieldConstraint{},
+		visiting:    map[string]struct{}{},
+	}
+	var paths []string
+	if basePath != "" {
+		paths = rule.SplitFullPath(basePath)
+	}
+	if err := c.convertObject(schema, paths); err != nil {
+		return "", err
+	}
+	if len(c.model) == 0 {
+		return "", errors.New("no field found in json schema")
+	}
+	return c.toToml(), nil
+}
+
+type jsonSchemaConverter struct {
+	root        map[string]interface{}
+	model       map[string]string
+	constraints map[string]*FieldConstraint
+	visiting    map[string]struct{}
+}
+
+func (c *jsonSchemaConverter) resolve(schema map[string]interface{}) (map[string]interface{}, func(), error) {
+	ref, ok := schema["$ref"].(string)
+	if !ok {
+		return schema, func() {}, nil
+	}
+	if _, ok := c.visiting[ref]; ok {
+		return nil, nil, errors.New("recursive json schema reference is not supported:" + ref)
+	}
+	resolved, err := resolveJsonPointer(c.root, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.visiting[ref] = struct{}{}
+	resolved, done, err := c.resolve(resolved)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resolved, func() {
+		done()
+		delete(c.visiting, ref)
+	}, nil
+}
+
+func (c *jsonSchemaConverter) convertObject(schema map[string]interface{}, paths []string) error {
+	schema, done, err := c.resolve(schema)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	if t := jsonSchemaType(schema); t != "object" && t != "" {
+		return errors.New(fmt.Sprintf("json schema of path:%s is not object", rule.ConcatFullPath(paths)))
+	}
+	// allOf - merge all sub schemas into the object
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		for _, v := range allOf {
+			sub, ok := v.(map[string]interface{})
+			if !ok {
+				return errors.New("allOf item is not json schema")
+			}
+			if err := c.convertObject(sub, paths); err != nil {
+				return err
+			}
+		}
+	}
+	if _, ok := schema["oneOf"]; ok {
+		return errors.New(fmt.Sprintf("oneOf is not supported at path:%s", rule.ConcatFullPath(paths)))
+	}
+	if _, ok := schema["anyOf"]; ok {
+		return errors.New(fmt.Sprintf("anyOf is not supported at path:%s", rule.ConcatFullPath(paths)))
+	}
+
+	requiredSet := map[string]struct{}{}
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, v := range required {
+			if name, ok := v.(string); ok {
+				requiredSet[name] = struct{}{}
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for name, v := range properties {
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			return errors.New("property is not json schema:" + name)
+		}
+		_, required := requiredSet[name]
+		if err := c.convertProperty(sub, append(paths, name), required); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *jsonSchemaConverter) convertProperty(schema map[string]interface{}, paths []string, required bool) error {
+	schema, done, err := c.resolve(schema)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	path := rule.ConcatFullPath(paths)
+	if !rule.ValidateFullPathOfDefinition(path) {
+		return errors.New("field name cannot be used as FlowModel path:" + path)
+	}
+	switch t := jsonSchemaType(schema); t {
+	case "object":
+		return c.convertObject(schema, paths)
+	case "array":
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			return errors.New("array items schema is required at path:" + path)
+		}
+		items, itemsDone, err := c.resolve(items)
+		if err != nil {
+			return err
+		}
+		defer itemsDone()
+		arrPaths := make([]string, len(paths))
+		copy(arrPaths, paths)
+		arrPaths[len(arrPaths)-1] = arrPaths[len(arrPaths)-1] + "[]"
+		switch it := jsonSchemaType(items); it {
+		case "object":
+			return c.convertObject(items, arrPaths)
+		case "array":
+			return errors.New("multidimensional array is not supported at path:" + path)
+		default:
+			return c.addPrimitive(items, it, rule.ConcatFullPath(arrPaths), false)
+		}
+	case "":
+		if _, ok := schema["properties"]; ok {
+			return c.convertObject(schema, paths)
+		}
+		if _, ok := schema["allOf"]; ok {
+			return c.convertObject(schema, paths)
+		}
+		return errors.New("type is required at path:" + path)
+	default:
+		return c.addPrimitive(schema, t, path, required)
+	}
+}
+
+func (c *jsonSchemaConverter) addPrimitive(schema map[string]interface{}, schemaType, path string, required bool) error {
+	var dataType string
+	switch schemaType {
+	case "string":
+		dataType = "string"
+	case "integer":
+		dataType = "int"
+	case "number":
+		dataType = "float"
+	case "boolean":
+		dataType = "bool"
+	default:
+		return errors.New(fmt.Sprintf("unsupported json schema type:%s at path:%s", schemaType, path))
+	}
+	if _, ok := c.model[path]; ok {
+		return errors.New("duplicated field at path:" + path)
+	}
+	c.model[path] = dataType
+
+	constraint := new(FieldConstraint)
+	hasConstraint := required
+	constraint.Required = required
+	if v, ok := schema["minimum"].(json.Number); ok {
+		constraint.Min = jsonNumberValue(v)
+		hasConstraint = true
+	}
+	if v, ok := schema["maximum"].(json.Number); ok {
+		constraint.Max = jsonNumberValue(v)
+		hasConstraint = true
+	}
+	if v, ok := schema["minLength"].(json.Number); ok {
+		if i, err := v.Int64(); err == nil {
+			l := int(i)
+			constraint.MinLength = &l
+			hasConstraint = true
+		}
+	}
+	if v, ok := schema["maxLength"].(json.Number); ok {
+		if i, err := v.Int64(); err == nil {
+			l := int(i)
+			constraint.MaxLength = &l
+			hasConstraint = true
+		}
+	}
+	if v, ok := schema["pattern"].(string); ok {
+		constraint.Pattern = v
+		hasConstraint = true
+	}
+	if v, ok := schema["enum"].([]interface{}); ok {
+		for _, e := range v {
+			switch ev := e.(type) {
+			case json.Number:
+				constraint.Enum = append(constraint.Enum, jsonNumberValue(ev))
+			case string, bool:
+				constraint.Enum = append(constraint.Enum, ev)
+			}
+		}
+		hasConstraint = len(constraint.Enum) > 0 || hasConstraint
+	}
+	if v, ok := schema["format"].(string); ok && dataType == "string" {
+		switch v {
+		case ConstraintFormatEmail, ConstraintFormatUuid, ConstraintFormatUri:
+			constraint.Format = v
+			hasConstraint = true
+		}
+	}
+	if hasConstraint {
+		c.constraints[path] = constraint
+	}
+	return nil
+}
+
+func (c *jsonSchemaConverter) toToml() string {
+	buf := new(bytes.Buffer)
+	buf.WriteString("[model]\n")
+	for _, path := range sortedKeys(c.model) {
+		buf.WriteString(fmt.Sprintf("%s = %s\n", tomlString(path), tomlString(c.model[path])))
+	}
+	if len(c.constraints) > 0 {
+		buf.WriteString("\n[constraints]\n")
+		for _, path := range sortedKeys(c.constraints) {
+			constraint := c.constraints[path]
+			var items []string
+			if constraint.Required {
+				items = append(items, "required = true")
+			}
+			if constraint.Min != nil {
+				items = append(items, fmt.Sprint("min = ", constraint.Min))
+			}
+			if constraint.Max != nil {
+				items = append(items, fmt.Sprint("max = ", constraint.Max))
+			}
+			if constraint.MinLength != nil {
+				items = append(items, fmt.Sprint("min_length = ", *constraint.MinLength))
+			}
+			if constraint.MaxLength != nil {
+				items = append(items, fmt.Sprint("max_length = ", *constraint.MaxLength))
+			}
+			if constraint.Pattern != "" {
+				items = append(items, "pattern = "+tomlString(constraint.Pattern))
+			}
+			if len(constraint.Enum) > 0 {
+				var enums []string
+				for _, v := range constraint.Enum {
+					if s, ok := v.(string); ok {
+						enums = append(enums, tomlString(s))
+					} else {
+						enums = append(enums, fmt.Sprint(v))
+					}
+				}
+				items = append(items, "enum = ["+strings.Join(enums, ", ")+"]")
+			}
+			if constraint.Format != "" {
+				items = append(items, "format = "+tomlString(constraint.Format))
+			}
+			buf.WriteString(fmt.Sprintf("%s = { %s }\n", tomlString(path), strings.Join(items, ", ")))
+		}
+	}
+	return buf.String()
+}
+
+func jsonSchemaType(schema map[string]interface{}) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		// e.g. ["string", "null"]
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func jsonNumberValue(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// resolveJsonPointer resolves local reference, e.g. #/$defs/User
+func resolveJsonPointer(root map[string]interface{}, ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, errors.New("only local json schema reference is supported:" + ref)
+	}
+	var current interface{} = root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("cannot resolve json schema reference:" + ref)
+		}
+		current, ok = m[token]
+		if !ok {
+			return nil, errors.New("cannot resolve json schema reference:" + ref)
+		}
+	}
+	schema, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("json schema reference is not schema:" + ref)
+	}
+	return schema, nil
+}
+
+func tomlString(s string) string {
+	// json string escaping is compatible to toml basic string
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
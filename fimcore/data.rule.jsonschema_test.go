@@ -0,0 +1,111 @@
+package fimcore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+)
+
+func TestJsonSchemaRoundTrip(t *testing.T) {
+	def := NewDataTypeDefinitions()
+	if err := def.MergeToml(constraintFlowModelContent); err != nil {
+		t.Fatal(err)
+	}
+	if err := def.ResolveConstraints(); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := def.ToJsonSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(schema, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["$schema"] != JsonSchemaDialect {
+		t.Fatal("dialect should be set")
+	}
+
+	content, err := ConvertJsonSchemaToFlowModel(schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := NewDataTypeDefinitions()
+	if err := imported.MergeToml(content); err != nil {
+		t.Fatal(err, content)
+	}
+	if err := imported.ResolveConstraints(); err != nil {
+		t.Fatal(err)
+	}
+	schema2, err := imported.ToJsonSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(schema) != string(schema2) {
+		t.Fatal("round trip schema mismatch:", string(schema), string(schema2))
+	}
+}
+
+func TestOpenApiSchemaImport(t *testing.T) {
+	document := `{
+  "openapi": "3.0.3",
+  "components": {
+    "schemas": {
+      "Address": {
+        "type": "object",
+        "properties": {
+          "city": { "type": "string", "maxLength": 32 }
+        }
+      },
+      "User": {
+        "type": "object",
+        "required": ["id"],
+        "properties": {
+          "id": { "type": "string", "format": "uuid" },
+          "score": { "type": ["number", "null"], "minimum": 0.5 },
+          "address": { "$ref": "#/components/schemas/Address" },
+          "history": { "type": "array", "items": { "$ref": "#/components/schemas/Address" } },
+          "tags": { "type": "array", "items": { "type": "string", "enum": ["a", "b"] } }
+        }
+      }
+    }
+  }
+}`
+	content, err := ConvertOpenApiJsonToFlowModel([]byte(document), "User", "request/user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := NewDataTypeDefinitions()
+	if err := def.MergeToml(content); err != nil {
+		t.Fatal(err, content)
+	}
+	if err := def.ResolveConstraints(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]pluginapi.DataType{
+		"request/user/id":             pluginapi.DataTypeString,
+		"request/user/score":          pluginapi.DataTypeFloat,
+		"request/user/address/city":   pluginapi.DataTypeString,
+		"request/user/history[]/city": pluginapi.DataTypeString,
+		"request/user/tags[]":         pluginapi.DataTypeArray,
+	}
+	for path, dt := range expected {
+		d := def.definitionOfPath(path)
+		if d == nil || d.DataType != dt {
+			t.Fatal("unexpected type of path:", path)
+		}
+	}
+	if c := def.definitionOfPath("request/user/id").Constraint; c == nil || !c.Required || c.Format != ConstraintFormatUuid {
+		t.Fatal("constraint of id should be imported")
+	}
+
+	if _, err := ConvertOpenApiJsonToFlowModel([]byte("openapi: 3.0.3\n"), "User", ""); err == nil {
+		t.Fatal("yaml document should fail")
+	}
+
+	recursive := `{"$defs":{"Node":{"type":"object","properties":{"next":{"$ref":"#/$defs/Node"},"v":{"type":"string"}}}},"$ref":"#/$defs/Node"}`
+	if _, err := ConvertJsonSchemaToFlowModel([]byte(recursive), ""); err == nil {
+		t.Fatal("recursive schema should fail")
+	}
+}
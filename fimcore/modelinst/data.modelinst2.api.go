@@ -0,0 +1,225 @@
+package modelinst
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+)
+
+func fieldError(err error, path []string, message string) error {
+	return fmt.Errorf("%w: path=[%s] %s", err, strings.Join(path, "/"), message)
+}
+
+func dataTypeOfGeneralObject(v interface{}) pluginapi.DataType {
+	switch v.(type) {
+	case map[string]interface{}:
+		return pluginapi.DataTypeObject
+	case []interface{}:
+		return pluginapi.DataTypeArray
+	case int64:
+		return pluginapi.DataTypeInt
+	case float64:
+		return pluginapi.DataTypeFloat
+	case string:
+		return pluginapi.DataTypeString
+	case bool:
+		return pluginapi.DataTypeBool
+	default:
+		return pluginapi.DataTypeUnavailable
+	}
+}
+
+// generalObjectOfPath returns the value of the path from general object, nil if not exists
+func generalObjectOfPath(obj interface{}, path []string) (interface{}, error) {
+	current := obj
+	for i, p := range path {
+		name, idx := rule.ExtractArrayPath(p)
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fieldError(basicapi.ErrFieldTypeMismatch, path[:i], "is not object")
+		}
+		elem, ok := m[name]
+		if !ok || elem == nil {
+			return nil, nil
+		}
+		if idx >= 0 {
+			arr, ok := elem.([]interface{})
+			if !ok {
+				return nil, fieldError(basicapi.ErrFieldTypeMismatch, path[:i+1], "is not array")
+			}
+			if idx >= len(arr) {
+				return nil, nil
+			}
+			elem = arr[idx]
+		}
+		current = elem
+	}
+	return current, nil
+}
+
+func generalGetField(v interface{}, path []string) (interface{}, error) {
+	if v == nil || isPrimitive(v) {
+		return v, nil
+	}
+	return nil, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not primitive")
+}
+
+func generalKeys(v interface{}, path []string) ([]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not object")
+	}
+	keys := make([]string, 0, len(m))
+	for key, val := range m {
+		if val != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func generalArrayLength(v interface{}, path []string) (int, error) {
+	if v == nil {
+		return 0, nil
+	}
+	arr, ok := v.([]interface{})
+	if !ok {
+		return 0, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not array")
+	}
+	return len(arr), nil
+}
+
+func generalCopySubModel(v interface{}, path []string) (pluginapi.Model, error) {
+	if v == nil {
+		return nil, fieldError(basicapi.ErrFieldNotFound, path, "")
+	}
+	if _, ok := v.(map[string]interface{}); !ok {
+		return nil, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not object")
+	}
+	inst, err := newModelInst2FromGeneralObject(v)
+	if err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
+
+func (m readonlyMapWrapper) GetField(path []string) (interface{}, error) {
+	v, err := generalObjectOfPath(m.m, path)
+	if err != nil {
+		return nil, err
+	}
+	return generalGetField(v, path)
+}
+
+func (m readonlyMapWrapper) FieldType(path []string) (pluginapi.DataType, error) {
+	v, err := generalObjectOfPath(m.m, path)
+	if err != nil {
+		return pluginapi.DataTypeUnavailable, err
+	}
+	return dataTypeOfGeneralObject(v), nil
+}
+
+func (m readonlyMapWrapper) Keys(path []string) ([]string, error) {
+	v, err := generalObjectOfPath(m.m, path)
+	if err != nil {
+		return nil, err
+	}
+	return generalKeys(v, path)
+}
+
+func (m readonlyMapWrapper) ArrayLength(path []string) (int, error) {
+	v, err := generalObjectOfPath(m.m, path)
+	if err != nil {
+		return 0, err
+	}
+	return generalArrayLength(v, path)
+}
+
+func (m readonlyMapWrapper) CopySubModel(path []string) (pluginapi.Model, error) {
+	v, err := generalObjectOfPath(m.m, path)
+	if err != nil {
+		return nil, err
+	}
+	return generalCopySubModel(v, path)
+}
+
+func (r readonlyModelInst2) AppendArrayElement(path []string, value interface{}) (int, error) {
+	return -1, readonlyError("AppendArrayElement")
+}
+
+func (r readonlyModelInst2) DeleteField(path []string) error {
+	return readonlyError("DeleteField")
+}
+
+func (c *copyOnWriteModelInst2) GetField(path []string) (interface{}, error) {
+	return c.current().GetField(path)
+}
+
+func (c *copyOnWriteModelInst2) FieldType(path []string) (pluginapi.DataType, error) {
+	return c.current().FieldType(path)
+}
+
+func (c *copyOnWriteModelInst2) Keys(path []string) ([]string, error) {
+	return c.current().Keys(path)
+}
+
+func (c *copyOnWriteModelInst2) ArrayLength(path []string) (int, error) {
+	return c.current().ArrayLength(path)
+}
+
+func (c *copyOnWriteModelInst2) AppendArrayElement(path []string, value interface{}) (int, error) {
+	w, err := c.write()
+	if err != nil {
+		return -1, err
+	}
+	return w.AppendArrayElement(path, value)
+}
+
+func (c *copyOnWriteModelInst2) DeleteField(path []string) error {
+	w, err := c.write()
+	if err != nil {
+		return err
+	}
+	return w.DeleteField(path)
+}
+
+func (c *copyOnWriteModelInst2) CopySubModel(path []string) (pluginapi.Model, error) {
+	return c.current().CopySubModel(path)
+}
+
+func (d defaultModelInst2) GetField(path []string) (interface{}, error) {
+	return nil, errors.New("operation unsupported")
+}
+
+func (d defaultModelInst2) FieldType(path []string) (pluginapi.DataType, error) {
+	return pluginapi.DataTypeUnavailable, errors.New("operation unsupported")
+}
+
+func (d defaultModelInst2) Keys(path []string) ([]string, error) {
+	return nil, errors.New("operation unsupported")
+}
+
+func (d defaultModelInst2) ArrayLength(path []string) (int, error) {
+	return 0, errors.New("operation unsupported")
+}
+
+func (d defaultModelInst2) AppendArrayElement(path []string, value interface{}) (int, error) {
+	return -1, errors.New("operation unsupported")
+}
+
+func (d defaultModelInst2) DeleteField(path []string) error {
+	return errors.New("operation unsupported")
+}
+
+func (d defaultModelInst2) CopySubModel(path []string) (pluginapi.Model, error) {
+	return nil, errors.New("operation unsupported")
+}
@@ -0,0 +1,309 @@
+package modelinst
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+const (
+	cborMajorUnsigned = 0
+	cborMajorNegative = 1
+	cborMajorBytes    = 2
+	cborMajorText     = 3
+	cborMajorArray    = 4
+	cborMajorMap      = 5
+	cborMajorTag      = 6
+	cborMajorSimple   = 7
+
+	cborIndefinite = 31
+	cborBreak      = 0xff
+)
+
+// cborModelCodec implements subset of CBOR(RFC 8949) which is required by Model
+// float is always encoded as float64 to keep data type; tags are ignored when decoding
+type cborModelCodec struct {
+}
+
+func (c cborModelCodec) Name() string {
+	return ModelCodecCbor
+}
+
+func (c cborModelCodec) Marshal(obj interface{}) ([]byte, error) {
+	return c.encode(make([]byte, 0, 256), obj)
+}
+
+func (c cborModelCodec) encodeHead(buf []byte, major byte, v uint64) []byte {
+	switch {
+	case v < 24:
+		return append(buf, major<<5|byte(v))
+	case v <= math.MaxUint8:
+		return append(buf, major<<5|24, byte(v))
+	case v <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major<<5|25), uint16(v))
+	case v <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major<<5|26), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major<<5|27), v)
+	}
+}
+
+func (c cborModelCodec) encodeInt(buf []byte, v int64) []byte {
+	if v >= 0 {
+		return c.encodeHead(buf, cborMajorUnsigned, uint64(v))
+	}
+	return c.encodeHead(buf, cborMajorNegative, uint64(-(v + 1)))
+}
+
+func (c cborModelCodec) encode(buf []byte, obj interface{}) ([]byte, error) {
+	switch v := obj.(type) {
+	case nil:
+		return append(buf, 0xf6), nil
+	case bool:
+		if v {
+			return append(buf, 0xf5), nil
+		}
+		return append(buf, 0xf4), nil
+	case int:
+		return c.encodeInt(buf, int64(v)), nil
+	case int64:
+		return c.encodeInt(buf, v), nil
+	case float64:
+		return binary.BigEndian.AppendUint64(append(buf, 0xfb), math.Float64bits(v)), nil
+	case string:
+		buf = c.encodeHead(buf, cborMajorText, uint64(len(v)))
+		return append(buf, v...), nil
+	case []interface{}:
+		buf = c.encodeHead(buf, cborMajorArray, uint64(len(v)))
+		var err error
+		for _, elem := range v {
+			if buf, err = c.encode(buf, elem); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		buf = c.encodeHead(buf, cborMajorMap, uint64(len(v)))
+		var err error
+		for key, val := range v {
+			buf = c.encodeHead(buf, cborMajorText, uint64(len(key)))
+			buf = append(buf, key...)
+			if buf, err = c.encode(buf, val); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, errors.New("unsupported value type for cbor codec:" + fmt.Sprint(reflect.TypeOf(obj)))
+	}
+}
+
+func (c cborModelCodec) Unmarshal(data []byte) (interface{}, error) {
+	d := &cborDecoder{data: data}
+	val, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, errors.New("cbor: unexpected trailing data")
+	}
+	return val, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+var errCborShortData = errors.New("cbor: unexpected end of data")
+
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if uint64(len(d.data)-d.pos) < n {
+		return nil, errCborShortData
+	}
+	r := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return r, nil
+}
+
+// readHead returns major type, additional information and argument
+func (d *cborDecoder) readHead() (byte, byte, uint64, error) {
+	b, err := d.read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	major := b[0] >> 5
+	info := b[0] & 0x1f
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info <= 27:
+		n := uint64(1) << (info - 24)
+		v, err := d.read(n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		switch n {
+		case 1:
+			return major, info, uint64(v[0]), nil
+		case 2:
+			return major, info, uint64(binary.BigEndian.Uint16(v)), nil
+		case 4:
+			return major, info, uint64(binary.BigEndian.Uint32(v)), nil
+		default:
+			return major, info, binary.BigEndian.Uint64(v), nil
+		}
+	case info == cborIndefinite:
+		return major, info, 0, nil
+	default:
+		return 0, 0, 0, errors.New(fmt.Sprintf("cbor: invalid additional information:%d", info))
+	}
+}
+
+func (d *cborDecoder) isBreak() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == cborBreak {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+func (d *cborDecoder) decode(level int) (interface{}, error) {
+	if level > maxCodecNestingLevel {
+		return nil, errors.New("cbor: exceed max nesting level")
+	}
+	major, info, arg, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	indefinite := info == cborIndefinite
+	switch major {
+	case cborMajorUnsigned:
+		if indefinite || arg > math.MaxInt64 {
+			return nil, errors.New("cbor: invalid or overflowed unsigned integer")
+		}
+		return int64(arg), nil
+	case cborMajorNegative:
+		if indefinite || arg > math.MaxInt64 {
+			return nil, errors.New("cbor: invalid or overflowed negative integer")
+		}
+		return -1 - int64(arg), nil
+	case cborMajorBytes:
+		return nil, errors.New("cbor: byte string is not supported")
+	case cborMajorText:
+		if !indefinite {
+			b, err := d.read(arg)
+			if err != nil {
+				return nil, err
+			}
+			return string(b), nil
+		}
+		// indefinite length string: concatenation of definite length chunks
+		var r []byte
+		for !d.isBreak() {
+			chunkMajor, chunkInfo, l, err := d.readHead()
+			if err != nil {
+				return nil, err
+			}
+			if chunkMajor != cborMajorText || chunkInfo == cborIndefinite {
+				return nil, errors.New("cbor: invalid chunk of indefinite length string")
+			}
+			b, err := d.read(l)
+			if err != nil {
+				return nil, err
+			}
+			r = append(r, b...)
+		}
+		return string(r), nil
+	case cborMajorArray:
+		var arr []interface{}
+		if !indefinite {
+			// each element takes at least 1 byte
+			if arg > uint64(len(d.data)-d.pos) {
+				return nil, errCborShortData
+			}
+			arr = make([]interface{}, 0, arg)
+		}
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.isBreak() {
+				break
+			}
+			v, err := d.decode(level + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		if arr == nil {
+			arr = []interface{}{}
+		}
+		return arr, nil
+	case cborMajorMap:
+		if !indefinite && arg > uint64(len(d.data)-d.pos)/2 {
+			return nil, errCborShortData
+		}
+		m := map[string]interface{}{}
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.isBreak() {
+				break
+			}
+			k, err := d.decode(level + 1)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, errors.New("cbor: map key is not string")
+			}
+			v, err := d.decode(level + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+	case cborMajorTag:
+		// ignore tag and use the tagged value
+		return d.decode(level + 1)
+	default:
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			// null, undefined
+			return nil, nil
+		case 25:
+			return float16ToFloat64(uint16(arg)), nil
+		case 26:
+			return float64(math.Float32frombits(uint32(arg))), nil
+		case 27:
+			return math.Float64frombits(arg), nil
+		default:
+			return nil, errors.New(fmt.Sprintf("cbor: unsupported simple value:%d", info))
+		}
+	}
+}
+
+func float16ToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1.0
+	}
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	default:
+		return sign * math.Ldexp(mant+1024, exp-25)
+	}
+}
@@ -0,0 +1,189 @@
+package modelinst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	ModelCodecToml    = "toml"
+	ModelCodecJson    = "json"
+	ModelCodecMsgPack = "msgpack"
+	ModelCodecCbor    = "cbor"
+)
+
+// maxCodecNestingLevel limits nesting level of decoded data to protect against malicious input
+const maxCodecNestingLevel = 512
+
+var (
+	modelCodecLock sync.RWMutex
+	modelCodecMap  = map[string]pluginapi.ModelCodec{}
+)
+
+func init() {
+	for _, codec := range []pluginapi.ModelCodec{tomlModelCodec{}, jsonModelCodec{}, msgPackModelCodec{}, cborModelCodec{}} {
+		if err := RegisterModelCodec(codec); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterModelCodec registers codec which can be used by name for transferring Model
+func RegisterModelCodec(codec pluginapi.ModelCodec) error {
+	modelCodecLock.Lock()
+	defer modelCodecLock.Unlock()
+	if _, ok := modelCodecMap[codec.Name()]; ok {
+		return errors.New("model codec already registered:" + codec.Name())
+	}
+	modelCodecMap[codec.Name()] = codec
+	return nil
+}
+
+func GetModelCodec(name string) (pluginapi.ModelCodec, bool) {
+	modelCodecLock.RLock()
+	defer modelCodecLock.RUnlock()
+	codec, ok := modelCodecMap[name]
+	return codec, ok
+}
+
+type tomlModelCodec struct {
+}
+
+func (t tomlModelCodec) Name() string {
+	return ModelCodecToml
+}
+
+func (t tomlModelCodec) Marshal(obj interface{}) ([]byte, error) {
+	return toml.Marshal(obj)
+}
+
+func (t tomlModelCodec) Unmarshal(data []byte) (interface{}, error) {
+	var val interface{}
+	if err := toml.NewDecoder(bytes.NewBuffer(data)).DisallowUnknownFields().Decode(&val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+func (m *modelInst2MapImpl) Encode(codec pluginapi.ModelCodec) ([]byte, error) {
+	return codec.Marshal(m.ToGeneralObject())
+}
+
+// Decode decodes data and merges into the Model
+// Unlike FromToml, empty arrays are kept in the Model
+func (m *modelInst2MapImpl) Decode(codec pluginapi.ModelCodec, data []byte) error {
+	val, err := codec.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	objMap, ok := val.(map[string]interface{})
+	if !ok {
+		return errors.New("Decode produces unexpected data type")
+	}
+	inst, err := newModelInst2FromGeneralObject(objMap)
+	if err != nil {
+		return err
+	}
+	inst.copy(m)
+	return nil
+}
+
+func (m readonlyMapWrapper) Encode(codec pluginapi.ModelCodec) ([]byte, error) {
+	return codec.Marshal(m.m)
+}
+
+// newModelInst2FromGeneralObject builds Model from decoded general object
+// Empty array is regarded as primitive array and will be converted to object array on demand
+func newModelInst2FromGeneralObject(obj interface{}) (*modelInst2MapImpl, error) {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		inst := &modelInst2MapImpl{
+			data:      make(map[string]*modelInst2MapImpl, len(v)),
+			valueType: valueTypeObject,
+		}
+		for key, val := range v {
+			if val == nil {
+				continue
+			}
+			sub, err := newModelInst2FromGeneralObject(val)
+			if err != nil {
+				return nil, err
+			}
+			inst.data[key] = sub
+		}
+		return inst, nil
+	case []interface{}:
+		var objectArray bool
+		for _, elem := range v {
+			if elem == nil {
+				continue
+			}
+			if _, ok := elem.(map[string]interface{}); ok {
+				objectArray = true
+			} else if _, ok := elem.([]interface{}); ok {
+				return nil, errors.New("nested array is not supported")
+			}
+			break
+		}
+		if !objectArray {
+			arr := make([]interface{}, len(v))
+			for i, elem := range v {
+				if elem == nil {
+					continue
+				}
+				val, err := convertCodecPrimitive(elem)
+				if err != nil {
+					return nil, err
+				}
+				arr[i] = val
+			}
+			return &modelInst2MapImpl{
+				primitiveArr: arr,
+				valueType:    valueTypePrimitiveArray,
+			}, nil
+		}
+		arr := make([]*modelInst2MapImpl, len(v))
+		for i, elem := range v {
+			if elem == nil {
+				elem = map[string]interface{}{}
+			}
+			if _, ok := elem.(map[string]interface{}); !ok {
+				return nil, errors.New("object array has non-object element")
+			}
+			sub, err := newModelInst2FromGeneralObject(elem)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = sub
+		}
+		return &modelInst2MapImpl{
+			array:     arr,
+			valueType: valueTypeArray,
+		}, nil
+	default:
+		val, err := convertCodecPrimitive(v)
+		if err != nil {
+			return nil, err
+		}
+		return &modelInst2MapImpl{
+			value:     val,
+			valueType: valueTypePrimitive,
+		}, nil
+	}
+}
+
+func convertCodecPrimitive(in interface{}) (interface{}, error) {
+	switch in.(type) {
+	case float32, float64, bool, string, int, int8, int16, int32, int64, uint8, uint16, uint32:
+	default:
+		return nil, errors.New("unsupported value type:" + fmt.Sprint(reflect.TypeOf(in)))
+	}
+	return mustConvertPrimitive(in), nil
+}
@@ -0,0 +1,132 @@
+package modelinst
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// jsonModelCodec keeps data type of numbers: float is always encoded with fraction or exponent part
+// while number without them is decoded as int
+type jsonModelCodec struct {
+}
+
+func (j jsonModelCodec) Name() string {
+	return ModelCodecJson
+}
+
+func (j jsonModelCodec) Marshal(obj interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := j.encode(buf, obj); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (j jsonModelCodec) encode(buf *bytes.Buffer, obj interface{}) error {
+	switch v := obj.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case string:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case int:
+		buf.WriteString(strconv.Itoa(v))
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("unsupported float value:" + fmt.Sprint(v))
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		buf.WriteString(s)
+		if !strings.ContainsAny(s, ".eE") {
+			buf.WriteString(".0")
+		}
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := j.encode(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		buf.WriteByte('{')
+		first := true
+		for key, val := range v {
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			if err := j.encode(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := j.encode(buf, val); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return errors.New("unsupported value type for json codec:" + fmt.Sprint(reflect.TypeOf(obj)))
+	}
+	return nil
+}
+
+func (j jsonModelCodec) Unmarshal(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var val interface{}
+	if err := decoder.Decode(&val); err != nil {
+		return nil, err
+	}
+	return j.convertNumber(val, 0)
+}
+
+func (j jsonModelCodec) convertNumber(obj interface{}, level int) (interface{}, error) {
+	if level > maxCodecNestingLevel {
+		return nil, errors.New("exceed max nesting level")
+	}
+	switch v := obj.(type) {
+	case json.Number:
+		s := string(v)
+		if strings.ContainsAny(s, ".eE") {
+			return v.Float64()
+		}
+		return v.Int64()
+	case []interface{}:
+		for i, elem := range v {
+			val, err := j.convertNumber(elem, level+1)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = val
+		}
+		return v, nil
+	case map[string]interface{}:
+		for key, elem := range v {
+			val, err := j.convertNumber(elem, level+1)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = val
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,287 @@
+package modelinst
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// msgPackModelCodec implements subset of MessagePack specification which is required by Model
+// bin/ext families are not supported
+type msgPackModelCodec struct {
+}
+
+func (c msgPackModelCodec) Name() string {
+	return ModelCodecMsgPack
+}
+
+func (c msgPackModelCodec) Marshal(obj interface{}) ([]byte, error) {
+	return c.encode(make([]byte, 0, 256), obj)
+}
+
+func (c msgPackModelCodec) encode(buf []byte, obj interface{}) ([]byte, error) {
+	switch v := obj.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case int:
+		return c.encodeInt(buf, int64(v)), nil
+	case int64:
+		return c.encodeInt(buf, v), nil
+	case float64:
+		buf = append(buf, 0xcb)
+		return binary.BigEndian.AppendUint64(buf, math.Float64bits(v)), nil
+	case string:
+		l := len(v)
+		switch {
+		case l < 32:
+			buf = append(buf, 0xa0|byte(l))
+		case l <= math.MaxUint8:
+			buf = append(buf, 0xd9, byte(l))
+		case l <= math.MaxUint16:
+			buf = binary.BigEndian.AppendUint16(append(buf, 0xda), uint16(l))
+		default:
+			buf = binary.BigEndian.AppendUint32(append(buf, 0xdb), uint32(l))
+		}
+		return append(buf, v...), nil
+	case []interface{}:
+		l := len(v)
+		switch {
+		case l < 16:
+			buf = append(buf, 0x90|byte(l))
+		case l <= math.MaxUint16:
+			buf = binary.BigEndian.AppendUint16(append(buf, 0xdc), uint16(l))
+		default:
+			buf = binary.BigEndian.AppendUint32(append(buf, 0xdd), uint32(l))
+		}
+		var err error
+		for _, elem := range v {
+			if buf, err = c.encode(buf, elem); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		l := len(v)
+		switch {
+		case l < 16:
+			buf = append(buf, 0x80|byte(l))
+		case l <= math.MaxUint16:
+			buf = binary.BigEndian.AppendUint16(append(buf, 0xde), uint16(l))
+		default:
+			buf = binary.BigEndian.AppendUint32(append(buf, 0xdf), uint32(l))
+		}
+		var err error
+		for key, val := range v {
+			if buf, err = c.encode(buf, key); err != nil {
+				return nil, err
+			}
+			if buf, err = c.encode(buf, val); err != nil {
+				return nil, err
+			}
+		}
+		return buf, nil
+	default:
+		return nil, errors.New("unsupported value type for msgpack codec:" + fmt.Sprint(reflect.TypeOf(obj)))
+	}
+}
+
+func (c msgPackModelCodec) encodeInt(buf []byte, v int64) []byte {
+	switch {
+	case v >= 0 && v <= 0x7f:
+		return append(buf, byte(v))
+	case v < 0 && v >= -32:
+		return append(buf, byte(v))
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return append(buf, 0xd0, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(buf, 0xd1), uint16(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(buf, 0xd2), uint32(v))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, 0xd3), uint64(v))
+	}
+}
+
+func (c msgPackModelCodec) Unmarshal(data []byte) (interface{}, error) {
+	d := &msgPackDecoder{data: data}
+	val, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, errors.New("msgpack: unexpected trailing data")
+	}
+	return val, nil
+}
+
+type msgPackDecoder struct {
+	data []byte
+	pos  int
+}
+
+var errMsgPackShortData = errors.New("msgpack: unexpected end of data")
+
+func (d *msgPackDecoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, errMsgPackShortData
+	}
+	r := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return r, nil
+}
+
+func (d *msgPackDecoder) readUint(n int) (uint64, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return 0, err
+	}
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *msgPackDecoder) decode(level int) (interface{}, error) {
+	if level > maxCodecNestingLevel {
+		return nil, errors.New("msgpack: exceed max nesting level")
+	}
+	b, err := d.read(1)
+	if err != nil {
+		return nil, err
+	}
+	t := b[0]
+	switch {
+	case t <= 0x7f:
+		return int64(t), nil
+	case t >= 0xe0:
+		return int64(int8(t)), nil
+	case t&0xf0 == 0x80:
+		return d.decodeMap(int(t&0x0f), level)
+	case t&0xf0 == 0x90:
+		return d.decodeArray(int(t&0x0f), level)
+	case t&0xe0 == 0xa0:
+		return d.decodeString(int(t & 0x1f))
+	}
+	switch t {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xca:
+		v, err := d.readUint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(v))), nil
+	case 0xcb:
+		v, err := d.readUint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(v), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := d.readUint(1 << (t - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if v > math.MaxInt64 {
+			return nil, errors.New("msgpack: uint64 overflows int64")
+		}
+		return int64(v), nil
+	case 0xd0:
+		v, err := d.readUint(1)
+		return int64(int8(v)), err
+	case 0xd1:
+		v, err := d.readUint(2)
+		return int64(int16(v)), err
+	case 0xd2:
+		v, err := d.readUint(4)
+		return int64(int32(v)), err
+	case 0xd3:
+		v, err := d.readUint(8)
+		return int64(v), err
+	case 0xd9, 0xda, 0xdb:
+		l, err := d.readUint(1 << (t - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(int(l))
+	case 0xdc, 0xdd:
+		l, err := d.readUint(2 << (t - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(l), level)
+	case 0xde, 0xdf:
+		l, err := d.readUint(2 << (t - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(l), level)
+	default:
+		return nil, errors.New(fmt.Sprintf("msgpack: unsupported type:0x%x", t))
+	}
+}
+
+func (d *msgPackDecoder) decodeString(l int) (interface{}, error) {
+	b, err := d.read(l)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgPackDecoder) decodeArray(l int, level int) (interface{}, error) {
+	// each element takes at least 1 byte
+	if l > len(d.data)-d.pos {
+		return nil, errMsgPackShortData
+	}
+	arr := make([]interface{}, l)
+	for i := range arr {
+		v, err := d.decode(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+func (d *msgPackDecoder) decodeMap(l int, level int) (interface{}, error) {
+	// each entry takes at least 2 bytes
+	if l > (len(d.data)-d.pos)/2 {
+		return nil, errMsgPackShortData
+	}
+	m := make(map[string]interface{}, l)
+	for i := 0; i < l; i++ {
+		k, err := d.decode(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, errors.New("msgpack: map key is not string")
+		}
+		v, err := d.decode(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
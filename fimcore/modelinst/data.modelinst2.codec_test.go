@@ -0,0 +1,82 @@
+package modelinst
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+)
+
+func TestModelCodecRoundTrip(t *testing.T) {
+	obj := map[string]interface{}{
+		"int":        int64(1),
+		"negative":   int64(-100000),
+		"big":        int64(math.MaxInt64),
+		"float":      1.0,
+		"float2":     -2.5e-10,
+		"string":     "hello, 世界",
+		"bool":       true,
+		"empty":      []interface{}{},
+		"primitives": []interface{}{int64(1), int64(2), int64(300)},
+		"objects": []interface{}{
+			map[string]interface{}{"name": "a"},
+			map[string]interface{}{"name": "b", "tags": []interface{}{"x"}},
+		},
+		"nested": map[string]interface{}{
+			"level": map[string]interface{}{"value": 0.0},
+		},
+	}
+	for _, name := range []string{ModelCodecJson, ModelCodecMsgPack, ModelCodecCbor} {
+		codec, ok := GetModelCodec(name)
+		if !ok {
+			t.Fatal("codec not found:", name)
+		}
+		src, err := newModelInst2FromGeneralObject(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := src.Encode(codec)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		dst := ModelInstHelper{}.NewInst()
+		if err := dst.(pluginapi.ModelEncoding).Decode(codec, data); err != nil {
+			t.Fatal(name, err)
+		}
+		if !reflect.DeepEqual(obj, dst.ToGeneralObject()) {
+			t.Fatal(name, "round trip mismatch:", dst.ToGeneralObject())
+		}
+	}
+}
+
+func TestModelCodecMalformedData(t *testing.T) {
+	inputs := map[string][][]byte{
+		ModelCodecMsgPack: {{0x81}, {0xdf, 0xff, 0xff, 0xff, 0xff}, {0x81, 0x01, 0x01}, {0xc4, 0x01, 0x00}},
+		ModelCodecCbor:    {{0xa1}, {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, {0xa1, 0x01, 0x01}, {0x41, 0x00}},
+		ModelCodecJson:    {[]byte(`{"a":`), []byte(`{"a":99999999999999999999}`)},
+	}
+	for name, list := range inputs {
+		codec, _ := GetModelCodec(name)
+		for _, data := range list {
+			if _, err := codec.Unmarshal(data); err == nil {
+				t.Fatal(name, "malformed data should fail:", data)
+			}
+		}
+	}
+}
+
+func TestModelDecodeEmptyArray(t *testing.T) {
+	codec, _ := GetModelCodec(ModelCodecJson)
+	m := ModelInstHelper{}.NewInst()
+	if err := m.(pluginapi.ModelEncoding).Decode(codec, []byte(`{"list":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	// empty array can be used as object array
+	if err := m.AddOrUpdateField0([]string{"list[0]", "name"}, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if m.GetFieldUnsafe0([]string{"list[0]", "name"}) != "a" {
+		t.Fatal("field should be set on empty array")
+	}
+}
@@ -0,0 +1,838 @@
+package modelinst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimapi/rule"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	slotKindPrimitive      = 1
+	slotKindObject         = 2
+	slotKindObjectArray    = 3
+	slotKindPrimitiveArray = 4
+)
+
+// CompiledSchema resolves field names of a known model shape to integer slots in advance
+// Models created from the schema store fields in slots and fields not in the schema in an overflow map
+// Models are pooled and can be returned by Release when the request finishes
+type CompiledSchema struct {
+	root *compiledObject
+	pool sync.Pool
+}
+
+type compiledObject struct {
+	index  map[string]int
+	fields []compiledField
+}
+
+type compiledField struct {
+	name string
+	kind byte
+	sub  *compiledObject
+}
+
+// CompileSchema compiles schema from leaf paths of definitions, e.g. user/name, user/tags[], items[]/id
+func CompileSchema(leafPaths []string) (*CompiledSchema, error) {
+	root := newCompiledObject()
+	for _, path := range leafPaths {
+		if !rule.ValidateFullPathOfDefinition(path) {
+			return nil, errors.New("invalid path:" + path)
+		}
+		obj := root
+		pathLvs := rule.SplitFullPath(path)
+		for idx, pLv := range pathLvs {
+			name, _ := rule.ExtractArrayPath(pLv)
+			isArray := rule.IsPathArray(pLv)
+			last := idx == len(pathLvs)-1
+			var kind byte
+			switch {
+			case last && isArray:
+				kind = slotKindPrimitiveArray
+			case last:
+				kind = slotKindPrimitive
+			case isArray:
+				kind = slotKindObjectArray
+			default:
+				kind = slotKindObject
+			}
+			field, err := obj.ensureField(name, kind)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("compile path:%s failed:%s", path, err))
+			}
+			obj = field.sub
+		}
+	}
+	s := &CompiledSchema{root: root}
+	s.pool.New = func() interface{} {
+		return s.root.newInst(s)
+	}
+	return s, nil
+}
+
+func newCompiledObject() *compiledObject {
+	return &compiledObject{index: map[string]int{}}
+}
+
+func (c *compiledObject) ensureField(name string, kind byte) (*compiledField, error) {
+	if idx, ok := c.index[name]; ok {
+		field := &c.fields[idx]
+		if field.kind != kind {
+			return nil, errors.New(fmt.Sprintf("field=[%s] is defined with different types", name))
+		}
+		return field, nil
+	}
+	field := compiledField{name: name, kind: kind}
+	if kind == slotKindObject || kind == slotKindObjectArray {
+		field.sub = newCompiledObject()
+	}
+	c.index[name] = len(c.fields)
+	c.fields = append(c.fields, field)
+	return &c.fields[len(c.fields)-1], nil
+}
+
+func (c *compiledObject) newInst(schema *CompiledSchema) *slotModelInst {
+	return &slotModelInst{
+		obj:    c,
+		schema: schema,
+		slots:  make([]slotValue, len(c.fields)),
+	}
+}
+
+// NewInst returns an empty Model from the pool
+func (s *CompiledSchema) NewInst() ModelInst2 {
+	return s.pool.Get().(*slotModelInst)
+}
+
+// Release resets the Model and puts it back to the pool
+// The Model and its sub levels must not be used after released
+func (s *CompiledSchema) Release(m pluginapi.Model) {
+	inst, ok := m.(*slotModelInst)
+	if !ok || inst.schema != s || inst.obj != s.root {
+		return
+	}
+	inst.reset()
+	s.pool.Put(inst)
+}
+
+type slotValue struct {
+	set          bool
+	value        interface{}
+	primitiveArr []interface{}
+	sub          *slotModelInst
+	array        []*slotModelInst
+}
+
+type slotModelInst struct {
+	obj      *compiledObject
+	schema   *CompiledSchema
+	slots    []slotValue
+	overflow *modelInst2MapImpl
+}
+
+func (m *slotModelInst) reset() {
+	for i := range m.slots {
+		v := &m.slots[i]
+		v.set = false
+		v.value = nil
+		for j := range v.primitiveArr {
+			v.primitiveArr[j] = nil
+		}
+		v.primitiveArr = v.primitiveArr[:0]
+		if v.sub != nil {
+			v.sub.reset()
+		}
+		// keep elements for reusing
+		for _, elem := range v.array {
+			elem.reset()
+		}
+		v.array = v.array[:0]
+	}
+	m.overflow = nil
+}
+
+func (m *slotModelInst) ensureOverflow() *modelInst2MapImpl {
+	if m.overflow == nil {
+		m.overflow = &modelInst2MapImpl{
+			data:      map[string]*modelInst2MapImpl{},
+			valueType: valueTypeObject,
+		}
+	}
+	return m.overflow
+}
+
+// field returns slot and its definition, nil if the name is not in the schema
+func (m *slotModelInst) field(name string) (*slotValue, *compiledField) {
+	idx, ok := m.obj.index[name]
+	if !ok {
+		return nil, nil
+	}
+	return &m.slots[idx], &m.obj.fields[idx]
+}
+
+func (m *slotModelInst) kindMismatch(f *compiledField) error {
+	return errors.New(fmt.Sprintf("field=[%s] type mismatches the schema", f.name))
+}
+
+func (m *slotModelInst) ensureSubInst(v *slotValue, f *compiledField) *slotModelInst {
+	if v.sub == nil {
+		v.sub = f.sub.newInst(m.schema)
+	}
+	v.set = true
+	return v.sub
+}
+
+func (m *slotModelInst) ensureElement(v *slotValue, f *compiledField, idx int) *slotModelInst {
+	for len(v.array) <= idx {
+		n := len(v.array)
+		if n < cap(v.array) {
+			// reuse released element
+			v.array = v.array[:n+1]
+			if v.array[n] == nil {
+				v.array[n] = f.sub.newInst(m.schema)
+			}
+		} else {
+			v.array = append(v.array, f.sub.newInst(m.schema))
+		}
+	}
+	v.set = true
+	return v.array[idx]
+}
+
+func (m *slotModelInst) AddOrUpdateField0(pathLvs []string, value interface{}) error {
+	// skip on nil
+	if value == nil {
+		return nil
+	}
+	// make sure value is acceptable
+	value = mustConvertPrimitive(value)
+
+	parent := m
+	for i, path := range pathLvs {
+		name, idx := rule.ExtractArrayPath(path)
+		v, f := parent.field(name)
+		if v == nil {
+			return parent.ensureOverflow().AddOrUpdateField0(pathLvs[i:], value)
+		}
+		last := i == len(pathLvs)-1
+		switch {
+		case last && idx < 0:
+			if f.kind != slotKindPrimitive {
+				return parent.kindMismatch(f)
+			}
+			v.value = value
+			v.set = true
+			return nil
+		case last:
+			if f.kind != slotKindPrimitiveArray {
+				return parent.kindMismatch(f)
+			}
+			return v.setPrimitiveArrayIndex(idx, value)
+		case idx < 0:
+			if f.kind != slotKindObject {
+				return parent.kindMismatch(f)
+			}
+			parent = parent.ensureSubInst(v, f)
+		default:
+			if f.kind != slotKindObjectArray {
+				return parent.kindMismatch(f)
+			}
+			parent = parent.ensureElement(v, f, idx)
+		}
+	}
+	return nil
+}
+
+func (v *slotValue) setPrimitiveArrayIndex(index int, value interface{}) error {
+	if !isPrimitive(value) {
+		return errors.New("value should be primitive")
+	}
+	if index >= len(v.primitiveArr) {
+		defaultValue := defaultValuePrimitive(value)
+		for len(v.primitiveArr) <= index {
+			v.primitiveArr = append(v.primitiveArr, defaultValue)
+		}
+	}
+	v.primitiveArr[index] = value
+	v.set = true
+	return nil
+}
+
+func (m *slotModelInst) GetFieldUnsafe0(pathLvs []string) interface{} {
+	parent := m
+	for i, path := range pathLvs {
+		name, idx := rule.ExtractArrayPath(path)
+		v, f := parent.field(name)
+		if v == nil {
+			if parent.overflow == nil {
+				return nil
+			}
+			return parent.overflow.GetFieldUnsafe0(pathLvs[i:])
+		}
+		if !v.set {
+			return nil
+		}
+		last := i == len(pathLvs)-1
+		switch {
+		case last && idx < 0:
+			if f.kind != slotKindPrimitive {
+				return nil
+			}
+			return v.value
+		case last:
+			if f.kind != slotKindPrimitiveArray || idx >= len(v.primitiveArr) {
+				return nil
+			}
+			return v.primitiveArr[idx]
+		case idx < 0:
+			if f.kind != slotKindObject {
+				return nil
+			}
+			parent = v.sub
+		default:
+			if f.kind != slotKindObjectArray || idx >= len(v.array) {
+				return nil
+			}
+			parent = v.array[idx]
+		}
+	}
+	return nil
+}
+
+func (m *slotModelInst) ToGeneralObject() interface{} {
+	var r map[string]interface{}
+	if m.overflow != nil {
+		r = m.overflow.ToGeneralObject().(map[string]interface{})
+	} else {
+		r = make(map[string]interface{}, len(m.slots))
+	}
+	for i := range m.slots {
+		v := &m.slots[i]
+		if !v.set {
+			continue
+		}
+		f := &m.obj.fields[i]
+		switch f.kind {
+		case slotKindPrimitive:
+			r[f.name] = v.value
+		case slotKindPrimitiveArray:
+			arr := make([]interface{}, len(v.primitiveArr))
+			copy(arr, v.primitiveArr)
+			r[f.name] = arr
+		case slotKindObject:
+			r[f.name] = v.sub.ToGeneralObject()
+		case slotKindObjectArray:
+			arr := make([]interface{}, len(v.array))
+			for j, elem := range v.array {
+				arr[j] = elem.ToGeneralObject()
+			}
+			r[f.name] = arr
+		}
+	}
+	return r
+}
+
+func (m *slotModelInst) Transfer(dst pluginapi.Model) error {
+	return readonlyMapWrapper{m: m.ToGeneralObject().(map[string]interface{})}.Transfer(dst)
+}
+
+func (m *slotModelInst) ToToml() ([]byte, error) {
+	return toml.Marshal(m.ToGeneralObject())
+}
+
+func (m *slotModelInst) FromToml(data []byte) error {
+	var val interface{}
+	if err := toml.NewDecoder(bytes.NewBuffer(data)).DisallowUnknownFields().Decode(&val); err != nil {
+		return err
+	}
+	objMap, ok := val.(map[string]interface{})
+	if !ok {
+		return errors.New("FromToml produces unexpected data type")
+	}
+	return readonlyMapWrapper{m: objMap}.Transfer(m)
+}
+
+func (m *slotModelInst) Encode(codec pluginapi.ModelCodec) ([]byte, error) {
+	return codec.Marshal(m.ToGeneralObject())
+}
+
+func (m *slotModelInst) Decode(codec pluginapi.ModelCodec, data []byte) error {
+	val, err := codec.Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	objMap, ok := val.(map[string]interface{})
+	if !ok {
+		return errors.New("Decode produces unexpected data type")
+	}
+	return readonlyMapWrapper{m: objMap}.Transfer(m)
+}
+
+func (m *slotModelInst) RemoveObjectByPath(paths []string) error {
+	parent := m
+	for i, path := range paths {
+		v, f := parent.field(path)
+		if v == nil {
+			if parent.overflow == nil {
+				return nil
+			}
+			return parent.overflow.RemoveObjectByPath(paths[i:])
+		}
+		if i == len(paths)-1 {
+			if v.set {
+				// detach values instead of resetting since they may be referenced
+				*v = slotValue{}
+			}
+			return nil
+		}
+		if !v.set {
+			return nil
+		}
+		if f.kind != slotKindObject {
+			return errors.New("sub type is not object")
+		}
+		parent = v.sub
+	}
+	return nil
+}
+
+func (m *slotModelInst) transferPrimitiveArray(srcName, dstName string, dst ModelInst2) error {
+	v, f := m.field(srcName)
+	if v == nil {
+		if m.overflow == nil {
+			return nil
+		}
+		return m.overflow.transferPrimitiveArray(srcName, dstName, dst)
+	}
+	if !v.set {
+		return nil
+	}
+	if f.kind != slotKindPrimitiveArray {
+		return errors.New(fmt.Sprintf("sub field=[%s] is not primitive array", srcName))
+	}
+	return dst.putPrimitiveArray(dstName, v.primitiveArr)
+}
+
+func (m *slotModelInst) transferValue(srcName, dstName string, dst ModelInst2) error {
+	v, f := m.field(srcName)
+	if v == nil {
+		if m.overflow == nil {
+			return nil
+		}
+		return m.overflow.transferValue(srcName, dstName, dst)
+	}
+	if !v.set {
+		return nil
+	}
+	if f.kind != slotKindPrimitive {
+		return errors.New(fmt.Sprintf("sub field=[%s] is not primitive", srcName))
+	}
+	return dst.putPrimitiveValue(dstName, v.value)
+}
+
+func (m *slotModelInst) ensureSubObject(name string) (ModelInst2, error) {
+	v, f := m.field(name)
+	if v == nil {
+		return m.ensureOverflow().ensureSubObject(name)
+	}
+	if f.kind != slotKindObject {
+		return nil, m.kindMismatch(f)
+	}
+	return m.ensureSubInst(v, f), nil
+}
+
+func (m *slotModelInst) getSubObject(name string) (ModelInst2, error) {
+	v, f := m.field(name)
+	if v == nil {
+		if m.overflow == nil {
+			return nil, nil
+		}
+		return m.overflow.getSubObject(name)
+	}
+	if !v.set {
+		return nil, nil
+	}
+	if f.kind != slotKindObject {
+		return nil, m.kindMismatch(f)
+	}
+	return v.sub, nil
+}
+
+func (m *slotModelInst) ensureSubArrayWithObjectElem(name string) (ModelInst2, error) {
+	v, f := m.field(name)
+	if v == nil {
+		return m.ensureOverflow().ensureSubArrayWithObjectElem(name)
+	}
+	if f.kind != slotKindObjectArray {
+		return nil, m.kindMismatch(f)
+	}
+	v.set = true
+	return &slotArrayInst{parent: m, v: v, f: f}, nil
+}
+
+func (m *slotModelInst) getSubArrayWithObjectElem(name string) (ModelInst2, error) {
+	v, f := m.field(name)
+	if v == nil {
+		if m.overflow == nil {
+			return nil, nil
+		}
+		return m.overflow.getSubArrayWithObjectElem(name)
+	}
+	if !v.set {
+		return nil, nil
+	}
+	if f.kind != slotKindObjectArray {
+		return nil, m.kindMismatch(f)
+	}
+	return &slotArrayInst{parent: m, v: v, f: f}, nil
+}
+
+func (m *slotModelInst) foreachArrayElement(f func(inst2 ModelInst2) error) error {
+	return errors.New("type is not array")
+}
+
+func (m *slotModelInst) ensureArrayElement() (ModelInst2, error) {
+	return nil, errors.New("type is not array")
+}
+
+func (m *slotModelInst) ensureArrayElementWithIndex(idx int) (ModelInst2, error) {
+	return nil, errors.New("type is not array")
+}
+
+func (m *slotModelInst) putPrimitiveArray(name string, arr []interface{}) error {
+	v, f := m.field(name)
+	if v == nil {
+		return m.ensureOverflow().putPrimitiveArray(name, arr)
+	}
+	if f.kind != slotKindPrimitiveArray {
+		return m.kindMismatch(f)
+	}
+	for _, elem := range arr {
+		if !isPrimitive(elem) {
+			return errors.New("value should be primitive")
+		}
+		break
+	}
+	v.primitiveArr = append(v.primitiveArr[:0], arr...)
+	v.set = true
+	return nil
+}
+
+func (m *slotModelInst) setPrimitiveArrayIndex(name string, index int, value interface{}) error {
+	v, f := m.field(name)
+	if v == nil {
+		return m.ensureOverflow().setPrimitiveArrayIndex(name, index, value)
+	}
+	if f.kind != slotKindPrimitiveArray {
+		return m.kindMismatch(f)
+	}
+	return v.setPrimitiveArrayIndex(index, value)
+}
+
+func (m *slotModelInst) putPrimitiveValue(name string, val interface{}) error {
+	v, f := m.field(name)
+	if v == nil {
+		return m.ensureOverflow().putPrimitiveValue(name, val)
+	}
+	if f.kind != slotKindPrimitive {
+		return m.kindMismatch(f)
+	}
+	if !isPrimitive(val) {
+		return errors.New("value should be primitive")
+	}
+	v.value = val
+	v.set = true
+	return nil
+}
+
+// slotArrayInst is the object array view of a slot
+type slotArrayInst struct {
+	parent *slotModelInst
+	v      *slotValue
+	f      *compiledField
+
+	defaultModelInst2
+}
+
+func (a *slotArrayInst) ToGeneralObject() interface{} {
+	arr := make([]interface{}, len(a.v.array))
+	for i, elem := range a.v.array {
+		arr[i] = elem.ToGeneralObject()
+	}
+	return arr
+}
+
+func (a *slotArrayInst) foreachArrayElement(f func(inst2 ModelInst2) error) error {
+	for _, elem := range a.v.array {
+		if err := f(elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *slotArrayInst) ensureArrayElement() (ModelInst2, error) {
+	return a.parent.ensureElement(a.v, a.f, len(a.v.array)), nil
+}
+
+func (a *slotArrayInst) ensureArrayElementWithIndex(idx int) (ModelInst2, error) {
+	return a.parent.ensureElement(a.v, a.f, idx), nil
+}
+
+func (m *slotModelInst) PathResolverOwner() interface{} {
+	return m.obj
+}
+
+// ResolvePath resolves path of objects and primitive field to slots
+// Array levels are not resolved since index varies
+func (m *slotModelInst) ResolvePath(path []string) (interface{}, bool) {
+	obj := m.obj
+	slots := make([]int, len(path))
+	for i, name := range path {
+		idx, ok := obj.index[name]
+		if !ok {
+			return nil, false
+		}
+		f := &obj.fields[idx]
+		if i == len(path)-1 {
+			if f.kind != slotKindPrimitive {
+				return nil, false
+			}
+		} else if f.kind != slotKindObject {
+			return nil, false
+		}
+		slots[i] = idx
+		obj = f.sub
+	}
+	return slots, true
+}
+
+func (m *slotModelInst) GetResolvedField(resolved interface{}) interface{} {
+	slots := resolved.([]int)
+	parent := m
+	for _, idx := range slots[:len(slots)-1] {
+		v := &parent.slots[idx]
+		if !v.set {
+			return nil
+		}
+		parent = v.sub
+	}
+	v := &parent.slots[slots[len(slots)-1]]
+	if !v.set {
+		return nil
+	}
+	return v.value
+}
+
+func (m *slotModelInst) AddOrUpdateResolvedField(resolved interface{}, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	value = mustConvertPrimitive(value)
+	slots := resolved.([]int)
+	parent := m
+	for _, idx := range slots[:len(slots)-1] {
+		parent = parent.ensureSubInst(&parent.slots[idx], &parent.obj.fields[idx])
+	}
+	v := &parent.slots[slots[len(slots)-1]]
+	v.value = value
+	v.set = true
+	return nil
+}
+
+// generalObjectOfPath returns general object of the path, nil if not exists
+func (m *slotModelInst) generalObjectOfPath(path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return m.ToGeneralObject(), nil
+	}
+	parent := m
+	for i, p := range path {
+		name, idx := rule.ExtractArrayPath(p)
+		v, f := parent.field(name)
+		if v == nil {
+			if parent.overflow == nil {
+				return nil, nil
+			}
+			node, elem, err := parent.overflow.lookup(path[i:])
+			if err != nil || node == nil {
+				return elem, err
+			}
+			return node.ToGeneralObject(), nil
+		}
+		if !v.set {
+			return nil, nil
+		}
+		last := i == len(path)-1
+		switch {
+		case f.kind == slotKindPrimitive && last && idx < 0:
+			return v.value, nil
+		case f.kind == slotKindPrimitiveArray && last && idx < 0:
+			arr := make([]interface{}, len(v.primitiveArr))
+			copy(arr, v.primitiveArr)
+			return arr, nil
+		case f.kind == slotKindPrimitiveArray && last:
+			if idx >= len(v.primitiveArr) {
+				return nil, nil
+			}
+			return v.primitiveArr[idx], nil
+		case f.kind == slotKindObject && idx < 0:
+			parent = v.sub
+		case f.kind == slotKindObjectArray && last && idx < 0:
+			arr := make([]interface{}, len(v.array))
+			for j, elem := range v.array {
+				arr[j] = elem.ToGeneralObject()
+			}
+			return arr, nil
+		case f.kind == slotKindObjectArray && idx >= 0:
+			if idx >= len(v.array) {
+				return nil, nil
+			}
+			parent = v.array[idx]
+		default:
+			return nil, fieldError(basicapi.ErrFieldTypeMismatch, path[:i+1], "type mismatches the schema")
+		}
+	}
+	return parent.ToGeneralObject(), nil
+}
+
+func (m *slotModelInst) GetField(path []string) (interface{}, error) {
+	v, err := m.generalObjectOfPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return generalGetField(v, path)
+}
+
+func (m *slotModelInst) FieldType(path []string) (pluginapi.DataType, error) {
+	v, err := m.generalObjectOfPath(path)
+	if err != nil {
+		return pluginapi.DataTypeUnavailable, err
+	}
+	return dataTypeOfGeneralObject(v), nil
+}
+
+func (m *slotModelInst) Keys(path []string) ([]string, error) {
+	v, err := m.generalObjectOfPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return generalKeys(v, path)
+}
+
+func (m *slotModelInst) ArrayLength(path []string) (int, error) {
+	v, err := m.generalObjectOfPath(path)
+	if err != nil {
+		return 0, err
+	}
+	return generalArrayLength(v, path)
+}
+
+func (m *slotModelInst) CopySubModel(path []string) (pluginapi.Model, error) {
+	v, err := m.generalObjectOfPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return generalCopySubModel(v, path)
+}
+
+func (m *slotModelInst) AppendArrayElement(path []string, value interface{}) (int, error) {
+	parent := m
+	for i, p := range path {
+		name, idx := rule.ExtractArrayPath(p)
+		v, f := parent.field(name)
+		if v == nil {
+			return parent.ensureOverflow().AppendArrayElement(path[i:], value)
+		}
+		if i < len(path)-1 {
+			switch {
+			case f.kind == slotKindObject && idx < 0:
+				parent = parent.ensureSubInst(v, f)
+			case f.kind == slotKindObjectArray && idx >= 0:
+				parent = parent.ensureElement(v, f, idx)
+			default:
+				return -1, parent.kindMismatch(f)
+			}
+			continue
+		}
+		if idx >= 0 {
+			return -1, errors.New("last level should be array field without index")
+		}
+		if value == nil {
+			if f.kind != slotKindObjectArray {
+				return -1, parent.kindMismatch(f)
+			}
+			length := len(v.array)
+			parent.ensureElement(v, f, length)
+			return length, nil
+		}
+		if f.kind != slotKindPrimitiveArray {
+			return -1, parent.kindMismatch(f)
+		}
+		value, err := convertPrimitive(value)
+		if err != nil {
+			return -1, err
+		}
+		length := len(v.primitiveArr)
+		return length, v.setPrimitiveArrayIndex(length, value)
+	}
+	return -1, errors.New("empty path")
+}
+
+func (m *slotModelInst) DeleteField(path []string) error {
+	parent := m
+	for i, p := range path {
+		name, idx := rule.ExtractArrayPath(p)
+		v, f := parent.field(name)
+		if v == nil {
+			if parent.overflow == nil {
+				return nil
+			}
+			return parent.overflow.DeleteField(path[i:])
+		}
+		if !v.set {
+			return nil
+		}
+		if i < len(path)-1 {
+			switch {
+			case f.kind == slotKindObject && idx < 0:
+				parent = v.sub
+			case f.kind == slotKindObjectArray && idx >= 0:
+				if idx >= len(v.array) {
+					return nil
+				}
+				parent = v.array[idx]
+			default:
+				return parent.kindMismatch(f)
+			}
+			continue
+		}
+		switch {
+		case idx < 0:
+			// detach values instead of resetting since they may be referenced
+			*v = slotValue{}
+		case f.kind == slotKindObjectArray:
+			if idx < len(v.array) {
+				n := len(v.array) - 1
+				copy(v.array[idx:], v.array[idx+1:])
+				// clear the tail so that the removed position is not reused by ensureElement
+				v.array[n] = nil
+				v.array = v.array[:n]
+			}
+		case f.kind == slotKindPrimitiveArray:
+			if idx < len(v.primitiveArr) {
+				v.primitiveArr = append(v.primitiveArr[:idx], v.primitiveArr[idx+1:]...)
+			}
+		default:
+			return parent.kindMismatch(f)
+		}
+		return nil
+	}
+	return errors.New("empty path")
+}
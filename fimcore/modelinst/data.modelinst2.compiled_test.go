@@ -0,0 +1,115 @@
+package modelinst
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/FimGroup/fim/fimapi/basicapi"
+)
+
+var compiledSchemaPaths = []string{
+	"user/name",
+	"user/age",
+	"user/tags[]",
+	"posts[]/post_id",
+	"posts[]/title",
+}
+
+func TestCompiledSchema(t *testing.T) {
+	schema, err := CompileSchema(compiledSchemaPaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CompileSchema([]string{"a/b", "a"}); err == nil {
+		t.Fatal("conflicting paths should fail")
+	}
+
+	m := schema.NewInst()
+	if err := m.AddOrUpdateField0([]string{"user", "name"}, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddOrUpdateField0([]string{"user", "tags[1]"}, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddOrUpdateField0([]string{"posts[1]", "post_id"}, 2); err != nil {
+		t.Fatal(err)
+	}
+	// overflow field
+	if err := m.AddOrUpdateField0([]string{"user", "extra", "value"}, 1.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddOrUpdateField0([]string{"user", "name", "sub"}, "x"); err == nil {
+		t.Fatal("type mismatch should fail")
+	}
+
+	expected := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name":  "alice",
+			"tags":  []interface{}{"", "b"},
+			"extra": map[string]interface{}{"value": 1.5},
+		},
+		"posts": []interface{}{
+			map[string]interface{}{},
+			map[string]interface{}{"post_id": int64(2)},
+		},
+	}
+	if !reflect.DeepEqual(expected, m.ToGeneralObject()) {
+		t.Fatal("unexpected general object:", m.ToGeneralObject())
+	}
+
+	// transfer between implementations
+	dst := ModelInstHelper{}.NewInst()
+	if err := m.(interface {
+		Transfer(dst basicapi.Model) error
+	}).Transfer(dst); err != nil {
+		t.Fatal(err)
+	}
+	back := schema.NewInst()
+	if err := dst.(*modelInst2MapImpl).Transfer(back); err != nil {
+		t.Fatal(err)
+	}
+	if back.GetFieldUnsafe0([]string{"posts[1]", "post_id"}) != int64(2) {
+		t.Fatal("transfer failed")
+	}
+
+	// accessor
+	accessor := basicapi.NewFieldAccessor([]string{"user", "age"})
+	if err := accessor.Set(m, 20); err != nil {
+		t.Fatal(err)
+	}
+	if accessor.Get(m) != int64(20) || accessor.Get(dst) != nil {
+		t.Fatal("accessor failed")
+	}
+
+	// release and reuse
+	schema.Release(m)
+	reused := schema.NewInst()
+	if len(reused.ToGeneralObject().(map[string]interface{})) != 0 {
+		t.Fatal("released model should be empty")
+	}
+}
+
+func BenchmarkCompiledModel(b *testing.B) {
+	schema, err := CompileSchema(compiledSchemaPaths)
+	if err != nil {
+		b.Fatal(err)
+	}
+	accessor := basicapi.NewFieldAccessor([]string{"user", "name"})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := schema.NewInst()
+		_ = accessor.Set(m, "alice")
+		_ = accessor.Get(m)
+		schema.Release(m)
+	}
+}
+
+func BenchmarkMapModel(b *testing.B) {
+	path := []string{"user", "name"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := ModelInstHelper{}.NewInst()
+		_ = m.AddOrUpdateField0(path, "alice")
+		_ = m.GetFieldUnsafe0(path)
+	}
+}
@@ -0,0 +1,245 @@
+package modelinst
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+)
+
+type transferFn func(src, dst ModelInst2) error
+
+// arrayReserver is implemented by arrays which are able to pre-size elements
+type arrayReserver interface {
+	reserveArrayElements(n int)
+}
+
+type levelKind int
+
+const (
+	levelKindPrimitive levelKind = iota
+	levelKindPrimitiveArray
+	levelKindObject
+	levelKindObjectArray
+)
+
+func (k levelKind) String() string {
+	switch k {
+	case levelKindPrimitive:
+		return "primitive"
+	case levelKindPrimitiveArray:
+		return "primitive array"
+	case levelKindObject:
+		return "object"
+	default:
+		return "object array"
+	}
+}
+
+// Compile checks levels of the mapping against data type definitions and compiles the converter into closures
+// Resolver of the side without definitions should be nil, e.g. local Model of flows or Model of connectors
+// Paths not defined are not checked
+func (m *ModelConverter) Compile(src, dst pluginapi.PathTypeResolver) error {
+	// non-nil for empty mappings, nil means not compiled
+	fns := make([]transferFn, 0, len(m.LevelPair))
+	for _, lp := range m.LevelPair {
+		fn, err := compileLevelPair(lp, src, dst)
+		if err != nil {
+			return err
+		}
+		fns = append(fns, fn)
+	}
+	m.compiled = fns
+	return nil
+}
+
+// checkLevelKind checks the level and returns its data types, DataTypeUnavailable if not checked
+func checkLevelKind(resolver pluginapi.PathTypeResolver, name, path string, kind levelKind) (pluginapi.DataType, pluginapi.DataType, error) {
+	if resolver == nil || len(name) == 0 {
+		return pluginapi.DataTypeUnavailable, pluginapi.DataTypeUnavailable, nil
+	}
+	dt, elemDt, err := resolver.TypeOfDefinitionPath(path)
+	if err != nil {
+		return pluginapi.DataTypeUnavailable, pluginapi.DataTypeUnavailable, err
+	}
+	var actual levelKind
+	switch dt {
+	case pluginapi.DataTypeUnavailable:
+		return dt, elemDt, nil
+	case pluginapi.DataTypeObject:
+		actual = levelKindObject
+	case pluginapi.DataTypeArray:
+		if elemDt == pluginapi.DataTypeUnavailable {
+			actual = levelKindObjectArray
+		} else {
+			actual = levelKindPrimitiveArray
+		}
+	default:
+		actual = levelKindPrimitive
+	}
+	if actual != kind {
+		return pluginapi.DataTypeUnavailable, pluginapi.DataTypeUnavailable, errors.New(fmt.Sprintf("mapping of path=[%s] requires %s but defined as %s", path, kind, actual))
+	}
+	return dt, elemDt, nil
+}
+
+// checkLevelPair checks both sides of the level, primitive data types should be the same if both sides are defined
+func checkLevelPair(lp levelPair, src, dst pluginapi.PathTypeResolver, kind levelKind) error {
+	srcDt, srcElemDt, err := checkLevelKind(src, lp.Src, lp.SrcPath, kind)
+	if err != nil {
+		return err
+	}
+	dstDt, dstElemDt, err := checkLevelKind(dst, lp.Dst, lp.DstPath, kind)
+	if err != nil {
+		return err
+	}
+	if srcDt == pluginapi.DataTypeUnavailable || dstDt == pluginapi.DataTypeUnavailable {
+		return nil
+	}
+	if srcDt != dstDt || srcElemDt != dstElemDt {
+		return errors.New(fmt.Sprintf("mapping of path=[%s] to path=[%s] data types are not the same", lp.SrcPath, lp.DstPath))
+	}
+	return nil
+}
+
+func compileSubs(lp levelPair, src, dst pluginapi.PathTypeResolver) (transferFn, error) {
+	var fns []transferFn
+	for _, sub := range lp.Subs {
+		fn, err := compileLevelPair(sub, src, dst)
+		if err != nil {
+			return nil, err
+		}
+		fns = append(fns, fn)
+	}
+	return func(srcParent, dstParent ModelInst2) error {
+		for _, fn := range fns {
+			if err := fn(srcParent, dstParent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+func compileLevelPair(lp levelPair, src, dst pluginapi.PathTypeResolver) (transferFn, error) {
+	srcName, dstName := lp.SrcName, lp.DstName
+	// leaf, do value assignment
+	if lp.Leaf {
+		if lp.isPrimitiveArray() {
+			if err := checkLevelPair(lp, src, dst, levelKindPrimitiveArray); err != nil {
+				return nil, err
+			}
+			return func(srcParent, dstParent ModelInst2) error {
+				return srcParent.transferPrimitiveArray(srcName, dstName, dstParent)
+			}, nil
+		}
+		if err := checkLevelPair(lp, src, dst, levelKindPrimitive); err != nil {
+			return nil, err
+		}
+		return func(srcParent, dstParent ModelInst2) error {
+			return srcParent.transferValue(srcName, dstName, dstParent)
+		}, nil
+	}
+
+	subs, err := compileSubs(lp, src, dst)
+	if err != nil {
+		return nil, err
+	}
+	// if one side of each is empty, do recursive Transfer
+	// otherwise do level preparation
+	switch {
+	case len(lp.Src) == 0:
+		// only object allowed, array is not allowed
+		if _, _, err := checkLevelKind(dst, lp.Dst, lp.DstPath, levelKindObject); err != nil {
+			return nil, err
+		}
+		return func(srcParent, dstParent ModelInst2) error {
+			newDst, err := dstParent.ensureSubObject(dstName)
+			if err != nil {
+				return err
+			}
+			return subs(srcParent, newDst)
+		}, nil
+	case len(lp.Dst) == 0:
+		// only object allowed, array is not allowed
+		if _, _, err := checkLevelKind(src, lp.Src, lp.SrcPath, levelKindObject); err != nil {
+			return nil, err
+		}
+		return func(srcParent, dstParent ModelInst2) error {
+			subSrc, err := srcParent.getSubObject(srcName)
+			if err != nil {
+				return err
+			} else if subSrc == nil {
+				// no src sub, just break deeper mapping
+				return nil
+			}
+			return subs(subSrc, dstParent)
+		}, nil
+	case lp.SrcArray && lp.DstArray:
+		// array to array
+		if err := checkLevelPair(lp, src, dst, levelKindObjectArray); err != nil {
+			return nil, err
+		}
+		srcPath := []string{srcName}
+		return func(srcParent, dstParent ModelInst2) error {
+			srcArr, err := srcParent.getSubArrayWithObjectElem(srcName)
+			if err != nil {
+				return err
+			} else if srcArr == nil {
+				return nil
+			}
+			dstArr, err := dstParent.ensureSubArrayWithObjectElem(dstName)
+			if err != nil {
+				return err
+			}
+			// pre-size destination array, skipped if the length is not available
+			if r, ok := dstArr.(arrayReserver); ok {
+				if n, err := srcParent.ArrayLength(srcPath); err == nil {
+					r.reserveArrayElements(n)
+				}
+			}
+			// foreach items and trigger sub mappings, then fill items back in the array
+			return srcArr.foreachArrayElement(func(srcObj ModelInst2) error {
+				dstObj, err := dstArr.ensureArrayElement()
+				if err != nil {
+					return err
+				}
+				return subs(srcObj, dstObj)
+			})
+		}, nil
+	default:
+		// object to object
+		if err := checkLevelPair(lp, src, dst, levelKindObject); err != nil {
+			return nil, err
+		}
+		return func(srcParent, dstParent ModelInst2) error {
+			srcObj, err := srcParent.getSubObject(srcName)
+			if err != nil {
+				return err
+			} else if srcObj == nil {
+				return nil
+			}
+			dstObj, err := dstParent.ensureSubObject(dstName)
+			if err != nil {
+				return err
+			}
+			return subs(srcObj, dstObj)
+		}, nil
+	}
+}
+
+func (m *modelInst2MapImpl) reserveArrayElements(n int) {
+	if m.valueType == valueTypeArray && cap(m.array)-len(m.array) < n {
+		arr := make([]*modelInst2MapImpl, len(m.array), len(m.array)+n)
+		copy(arr, m.array)
+		m.array = arr
+	}
+}
+
+func (a *slotArrayInst) reserveArrayElements(n int) {
+	if cap(a.v.array)-len(a.v.array) < n {
+		arr := make([]*slotModelInst, len(a.v.array), len(a.v.array)+n)
+		copy(arr, a.v.array)
+		a.v.array = arr
+	}
+}
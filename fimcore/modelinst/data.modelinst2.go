@@ -30,8 +30,10 @@ func (m MappingRuleRaw) ToConverter() (*ModelConverter, error) {
 		}
 	}
 
-	//FIXME need to calculate max level cnt to optimize assignment
-	converter.MaxLevelCnt = 16
+	// compile without data type definitions, Compile again when definitions are available
+	if err := converter.Compile(nil, nil); err != nil {
+		return nil, err
+	}
 
 	return converter, nil
 }
@@ -88,6 +90,8 @@ func (m MappingRuleRaw) processEachLevelOfRule(ruleElem []interface{}, converter
 				lp.DstName = dst
 				lp.DstArray = false
 			}
+			lp.SrcPath = srcPath
+			lp.DstPath = dstPath
 		}
 
 		return lp, nil
@@ -145,6 +149,8 @@ func (m MappingRuleRaw) processEachLevelOfRule(ruleElem []interface{}, converter
 		// generate converter materials
 		{
 			lp.Leaf = false
+			lp.SrcPath = rule.ConcatFullPath(newSrcPaths)
+			lp.DstPath = rule.ConcatFullPath(newDstPaths)
 			{
 				lp.Src = src
 				name, _ := rule.ExtractArrayPath(src)
@@ -171,6 +177,9 @@ func (m MappingRuleRaw) processEachLevelOfRule(ruleElem []interface{}, converter
 		// special case - primitive array(array to array) with empty mapping rules
 		if rule.IsArrayDefinition(src) && rule.IsArrayDefinition(dst) && len(subs) == 0 {
 			lp.Leaf = true
+			// leaf paths are checked against FlowModel and compiled into local Model the same as primitive fields
+			converter.SourceLeafPathList = append(converter.SourceLeafPathList, lp.SrcPath)
+			converter.TargetLeafPathList = append(converter.TargetLeafPathList, lp.DstPath)
 		}
 		// not allow only one side is array
 		if lp.SrcArray != lp.DstArray {
@@ -187,8 +196,9 @@ type ModelConverter struct {
 	SourceLeafPathList []string
 	TargetLeafPathList []string
 
-	LevelPair   []levelPair
-	MaxLevelCnt int
+	LevelPair []levelPair
+
+	compiled []transferFn
 }
 
 // ModelInst2 contains full definition of data object
@@ -219,8 +229,22 @@ type ModelInst2 interface {
 type ModelInstHelper struct {
 }
 
-func (ModelInstHelper) WrapReadonlyMap(m map[string]interface{}) ModelInst2 {
-	return readonlyMapWrapper{m: m}
+// WrapReadonlyMap is the same as WrapReadonlyMapView but panics if the map has unsupported values
+func (h ModelInstHelper) WrapReadonlyMap(m map[string]interface{}) ModelInst2 {
+	r, err := h.WrapReadonlyMapView(m)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// WrapReadonlyMapView wraps the map as readonly Model
+// Values in the map are validated and deep copied, so the caller can still modify the map afterwards
+func (ModelInstHelper) WrapReadonlyMapView(m map[string]interface{}) (ModelInst2, error) {
+	if err := checkGeneralObject(m); err != nil {
+		return nil, err
+	}
+	return readonlyMapWrapper{m: copyGeneralObject(m).(map[string]interface{})}, nil
 }
 
 func (ModelInstHelper) NewInst() ModelInst2 {
@@ -239,115 +263,21 @@ func (m *ModelConverter) GeneralTransfer(src, dst basicapi.Model) error {
 // 1. transfer data
 // 2. do data checks including primitive data type matching
 func (m *ModelConverter) Transfer(src, dst ModelInst2) error {
-	// DFS assignment + data type matching
+	// DFS assignment by compiled closures of each level
 	// rules of a given name
 	// 1. src/dst literal types - field/object name, array def(xxx[]) name
 	// 3. type array def -> object, primitives
 	// 5. one of the two sides is empty - create level but no data assign
-
-	for _, v := range m.LevelPair {
-		if err := m.doTransfer(v, src, dst); err != nil {
-			return err
-		}
+	if m.compiled == nil {
+		return errors.New("converter is not compiled")
 	}
-
-	return nil
-}
-
-func (m *ModelConverter) doTransfer(lvPair levelPair, srcParent, dstParent ModelInst2) error {
-	// leaf, do value assignment
-	if lvPair.Leaf {
-		// primitive array
-		if lvPair.isPrimitiveArray() {
-			return srcParent.transferPrimitiveArray(lvPair.SrcName, lvPair.DstName, dstParent)
-		} else {
-			// Transfer value
-			return srcParent.transferValue(lvPair.SrcName, lvPair.DstName, dstParent)
-		}
-	}
-
-	// if one side of each is empty, do recursive Transfer
-	// otherwise do level preparation
-	if len(lvPair.Src) == 0 {
-		// only object allowed, array is not allowed
-		if newDst, err := dstParent.ensureSubObject(lvPair.DstName); err != nil {
+	for _, fn := range m.compiled {
+		if err := fn(src, dst); err != nil {
 			return err
-		} else {
-			for _, sub := range lvPair.Subs {
-				if err := m.doTransfer(sub, srcParent, newDst); err != nil {
-					return err
-				}
-			}
-			return nil
 		}
-	} else if len(lvPair.Dst) == 0 {
-		// only object allowed, array is not allowed
-		if subSrc, err := srcParent.getSubObject(lvPair.SrcName); err != nil {
-			return err
-		} else if subSrc == nil {
-			// no src sub, just break deeper mapping
-			return nil
-		} else {
-			for _, sub := range lvPair.Subs {
-				if err := m.doTransfer(sub, subSrc, dstParent); err != nil {
-					return err
-				}
-			}
-			return nil
-		}
-	} else {
-		// not leaf, just create levels
-		if lvPair.SrcArray && lvPair.DstArray {
-			// array to array
-			srcArr, err := srcParent.getSubArrayWithObjectElem(lvPair.SrcName)
-			if err != nil {
-				return err
-			} else if srcArr == nil {
-				return nil
-			}
-			dstArr, err := dstParent.ensureSubArrayWithObjectElem(lvPair.DstName)
-			if err != nil {
-				return err
-			}
-			// foreach items and trigger sub mappings, then fill items back in the array
-			if err := srcArr.foreachArrayElement(func(srcObj ModelInst2) error {
-				if dstObj, err := dstArr.ensureArrayElement(); err != nil {
-					return err
-				} else {
-					for _, sub := range lvPair.Subs {
-						if err := m.doTransfer(sub, srcObj, dstObj); err != nil {
-							return err
-						}
-					}
-					return nil
-				}
-			}); err != nil {
-				return err
-			}
-			return nil
-		} else {
-			// object to object
-			srcObj, err := srcParent.getSubObject(lvPair.SrcName)
-			if err != nil {
-				return err
-			} else if srcObj == nil {
-				return nil
-			}
-			dstObj, err := dstParent.ensureSubObject(lvPair.DstName)
-			if err != nil {
-				return err
-			}
-			for _, sub := range lvPair.Subs {
-				if err := m.doTransfer(sub, srcObj, dstObj); err != nil {
-					return err
-				}
-			}
-			return nil
-		} // otherwise not allowed
 	}
 
-	// For this very complex mapping rule, in order to avoid missing branches, 'return nil' will not be used at the bottom
-	// Instead, 'return' will be used in every branchy
+	return nil
 }
 
 type levelPair struct {
@@ -360,6 +290,10 @@ type levelPair struct {
 	Dst      string
 	DstName  string
 	DstArray bool
+
+	// SrcPath and DstPath are full definition paths of the level
+	SrcPath string
+	DstPath string
 }
 
 func (l *levelPair) isPrimitiveArray() bool {
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"sort"
 
+	"github.com/FimGroup/fim/fimapi/basicapi"
 	"github.com/FimGroup/fim/fimapi/pluginapi"
 	"github.com/FimGroup/fim/fimapi/rule"
 
@@ -41,13 +43,17 @@ func (m *modelInst2MapImpl) FromToml(data []byte) error {
 		return errors.New("FromToml produces unexpected data type")
 	}
 
-	return ModelInstHelper{}.WrapReadonlyMap(objMap).(pluginapi.ModelCopy).Transfer(m)
+	return readonlyMapWrapper{m: objMap}.Transfer(m)
 }
 
 func (m *modelInst2MapImpl) Transfer(dst pluginapi.Model) error {
 	dstModelInst2, ok := dst.(*modelInst2MapImpl)
 	if !ok {
-		return errors.New("dst Model should have the same type to src Model")
+		// different implementation, e.g. schema-compiled Model
+		if _, ok := dst.(ModelInst2); !ok {
+			return errors.New("dst Model should be ModelInst2")
+		}
+		return readonlyMapWrapper{m: m.ToGeneralObject().(map[string]interface{})}.Transfer(dst)
 	}
 	m.copy(dstModelInst2)
 	return nil
@@ -127,10 +133,39 @@ func (m *modelInst2MapImpl) AddOrUpdateField0(pathLvs []string, value interface{
 	// make sure value is acceptable
 	value = mustConvertPrimitive(value)
 
+	parent, err := m.ensureParent(pathLvs)
+	if err != nil {
+		return err
+	}
+
+	// last level
+	if parent.valueType != valueTypeObject {
+		return errors.New("type is not object")
+	}
+	lastPath := pathLvs[len(pathLvs)-1]
+	name, idx := rule.ExtractArrayPath(lastPath)
+	if idx < 0 {
+		// handling object field
+		if err := parent.putPrimitiveValue(name, value); err != nil {
+			return err
+		}
+		return nil
+	} else {
+		// handling array access - primitive array
+		// set primitive value with given index
+		if err := parent.setPrimitiveArrayIndex(name, idx, value); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// ensureParent ensures objects of the path except the last level
+func (m *modelInst2MapImpl) ensureParent(pathLvs []string) (*modelInst2MapImpl, error) {
 	parent := m
 	for _, path := range pathLvs[:len(pathLvs)-1] {
-		if m.valueType != valueTypeObject {
-			return errors.New("type is not object")
+		if parent.valueType != valueTypeObject {
+			return nil, errors.New("type is not object")
 		}
 		name, idx := rule.ExtractArrayPath(path)
 		if idx < 0 {
@@ -139,7 +174,7 @@ func (m *modelInst2MapImpl) AddOrUpdateField0(pathLvs []string, value interface{
 			if !ok {
 				newSub, err := parent.ensureSubObject(path)
 				if err != nil {
-					return err
+					return nil, err
 				}
 				sub = newSub.(*modelInst2MapImpl)
 			}
@@ -151,38 +186,20 @@ func (m *modelInst2MapImpl) AddOrUpdateField0(pathLvs []string, value interface{
 			if !ok {
 				newSub, err := parent.ensureSubArrayWithObjectElem(name)
 				if err != nil {
-					return err
+					return nil, err
 				}
 				sub = newSub.(*modelInst2MapImpl)
+			} else {
+				sub.convertEmptyArray()
 			}
 			elem, err := sub.ensureArrayElementWithIndex(idx)
 			if err != nil {
-				return err
+				return nil, err
 			}
 			parent = elem.(*modelInst2MapImpl)
 		}
 	}
-
-	// last level
-	if parent.valueType != valueTypeObject {
-		return errors.New("type is not object")
-	}
-	lastPath := pathLvs[len(pathLvs)-1]
-	name, idx := rule.ExtractArrayPath(lastPath)
-	if idx < 0 {
-		// handling object field
-		if err := parent.putPrimitiveValue(name, value); err != nil {
-			return err
-		}
-		return nil
-	} else {
-		// handling array access - primitive array
-		// set primitive value with given index
-		if err := parent.setPrimitiveArrayIndex(name, idx, value); err != nil {
-			return err
-		}
-		return nil
-	}
+	return parent, nil
 }
 
 func (m *modelInst2MapImpl) GetFieldUnsafe0(pathLvs []string) interface{} {
@@ -422,6 +439,7 @@ func (m *modelInst2MapImpl) ensureSubArrayWithObjectElem(name string) (ModelInst
 
 	sub, ok := m.data[name]
 	if ok {
+		sub.convertEmptyArray()
 		if sub.valueType != valueTypeArray {
 			return nil, errors.New(fmt.Sprintf("sub field=[%s] is not array", name))
 		}
@@ -445,6 +463,7 @@ func (m *modelInst2MapImpl) getSubArrayWithObjectElem(name string) (ModelInst2,
 
 	sub, ok := m.data[name]
 	if ok {
+		sub.convertEmptyArray()
 		if sub.valueType != valueTypeArray {
 			return nil, errors.New(fmt.Sprintf("sub field=[%s] is not array", name))
 		}
@@ -453,6 +472,16 @@ func (m *modelInst2MapImpl) getSubArrayWithObjectElem(name string) (ModelInst2,
 	return nil, nil
 }
 
+// convertEmptyArray converts empty primitive array to object array
+// Element type of empty array is unknown after decoding, so it is determined by the first access
+func (m *modelInst2MapImpl) convertEmptyArray() {
+	if m.valueType == valueTypePrimitiveArray && len(m.primitiveArr) == 0 {
+		m.primitiveArr = nil
+		m.array = []*modelInst2MapImpl{}
+		m.valueType = valueTypeArray
+	}
+}
+
 func (m *modelInst2MapImpl) foreachArrayElement(f func(inst2 ModelInst2) error) error {
 	if m.valueType != valueTypeArray {
 		return errors.New("type is not array")
@@ -505,3 +534,216 @@ func (m *modelInst2MapImpl) ensureArrayElementWithIndex(idx int) (ModelInst2, er
 		}
 	}
 }
+
+// lookup returns node of the path or the element when the path ends with index of primitive array
+// Both are nil if not exists
+func (m *modelInst2MapImpl) lookup(path []string) (*modelInst2MapImpl, interface{}, error) {
+	node := m
+	for i, p := range path {
+		if node.valueType != valueTypeObject {
+			return nil, nil, fieldError(basicapi.ErrFieldTypeMismatch, path[:i], "is not object")
+		}
+		name, idx := rule.ExtractArrayPath(p)
+		sub, ok := node.data[name]
+		if !ok || sub == nil {
+			return nil, nil, nil
+		}
+		if idx >= 0 {
+			switch sub.valueType {
+			case valueTypeArray:
+				if idx >= len(sub.array) {
+					return nil, nil, nil
+				}
+				sub = sub.array[idx]
+			case valueTypePrimitiveArray:
+				if i != len(path)-1 {
+					return nil, nil, fieldError(basicapi.ErrFieldTypeMismatch, path[:i+1], "is not object")
+				}
+				if idx >= len(sub.primitiveArr) {
+					return nil, nil, nil
+				}
+				return nil, sub.primitiveArr[idx], nil
+			default:
+				return nil, nil, fieldError(basicapi.ErrFieldTypeMismatch, path[:i+1], "is not array")
+			}
+		}
+		node = sub
+	}
+	return node, nil, nil
+}
+
+func (m *modelInst2MapImpl) GetField(path []string) (interface{}, error) {
+	node, elem, err := m.lookup(path)
+	if err != nil || elem != nil || node == nil {
+		return elem, err
+	}
+	if node.valueType != valueTypePrimitive {
+		return nil, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not primitive")
+	}
+	return node.value, nil
+}
+
+func (m *modelInst2MapImpl) FieldType(path []string) (pluginapi.DataType, error) {
+	node, elem, err := m.lookup(path)
+	if err != nil {
+		return pluginapi.DataTypeUnavailable, err
+	}
+	if node == nil {
+		return dataTypeOfGeneralObject(elem), nil
+	}
+	switch node.valueType {
+	case valueTypePrimitive:
+		return dataTypeOfGeneralObject(node.value), nil
+	case valueTypeObject:
+		return pluginapi.DataTypeObject, nil
+	default:
+		return pluginapi.DataTypeArray, nil
+	}
+}
+
+func (m *modelInst2MapImpl) Keys(path []string) ([]string, error) {
+	node, elem, err := m.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if elem != nil || (node != nil && node.valueType != valueTypeObject) {
+		return nil, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not object")
+	}
+	if node == nil {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(node.data))
+	for key, val := range node.data {
+		if val != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *modelInst2MapImpl) ArrayLength(path []string) (int, error) {
+	node, elem, err := m.lookup(path)
+	if err != nil {
+		return 0, err
+	}
+	if elem != nil {
+		return 0, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not array")
+	}
+	if node == nil {
+		return 0, nil
+	}
+	switch node.valueType {
+	case valueTypeArray:
+		return len(node.array), nil
+	case valueTypePrimitiveArray:
+		return len(node.primitiveArr), nil
+	default:
+		return 0, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not array")
+	}
+}
+
+func (m *modelInst2MapImpl) AppendArrayElement(path []string, value interface{}) (int, error) {
+	if len(path) == 0 {
+		return -1, errors.New("empty path")
+	}
+	parent, err := m.ensureParent(path)
+	if err != nil {
+		return -1, err
+	}
+	if parent.valueType != valueTypeObject {
+		return -1, fieldError(basicapi.ErrFieldTypeMismatch, path[:len(path)-1], "is not object")
+	}
+	name, idx := rule.ExtractArrayPath(path[len(path)-1])
+	if idx >= 0 {
+		return -1, errors.New("last level should be array field without index")
+	}
+	if value == nil {
+		arr, err := parent.ensureSubArrayWithObjectElem(name)
+		if err != nil {
+			return -1, err
+		}
+		if _, err := arr.ensureArrayElement(); err != nil {
+			return -1, err
+		}
+		return len(arr.(*modelInst2MapImpl).array) - 1, nil
+	}
+	value, err = convertPrimitive(value)
+	if err != nil {
+		return -1, err
+	}
+	var length int
+	if sub, ok := parent.data[name]; ok {
+		if sub.valueType == valueTypeArray && len(sub.array) == 0 {
+			// empty array without known element type
+			sub.array = nil
+			sub.primitiveArr = []interface{}{}
+			sub.valueType = valueTypePrimitiveArray
+		}
+		if sub.valueType != valueTypePrimitiveArray {
+			return -1, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not primitive array")
+		}
+		length = len(sub.primitiveArr)
+	}
+	if err := parent.setPrimitiveArrayIndex(name, length, value); err != nil {
+		return -1, err
+	}
+	return length, nil
+}
+
+func (m *modelInst2MapImpl) DeleteField(path []string) error {
+	if len(path) == 0 {
+		return errors.New("empty path")
+	}
+	parent, _, err := m.lookup(path[:len(path)-1])
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return nil
+	}
+	if parent.valueType != valueTypeObject {
+		return fieldError(basicapi.ErrFieldTypeMismatch, path[:len(path)-1], "is not object")
+	}
+	name, idx := rule.ExtractArrayPath(path[len(path)-1])
+	sub, ok := parent.data[name]
+	if !ok {
+		return nil
+	}
+	if idx < 0 {
+		delete(parent.data, name)
+		return nil
+	}
+	switch sub.valueType {
+	case valueTypeArray:
+		if idx < len(sub.array) {
+			sub.array = append(sub.array[:idx], sub.array[idx+1:]...)
+		}
+	case valueTypePrimitiveArray:
+		if idx < len(sub.primitiveArr) {
+			sub.primitiveArr = append(sub.primitiveArr[:idx], sub.primitiveArr[idx+1:]...)
+		}
+	default:
+		return fieldError(basicapi.ErrFieldTypeMismatch, path, "is not array")
+	}
+	return nil
+}
+
+func (m *modelInst2MapImpl) CopySubModel(path []string) (pluginapi.Model, error) {
+	node, elem, err := m.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if elem != nil || (node != nil && node.valueType != valueTypeObject) {
+		return nil, fieldError(basicapi.ErrFieldTypeMismatch, path, "is not object")
+	}
+	if node == nil {
+		return nil, fieldError(basicapi.ErrFieldNotFound, path, "")
+	}
+	// general object is used so that primitive arrays are not shared
+	inst, err := newModelInst2FromGeneralObject(node.ToGeneralObject())
+	if err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
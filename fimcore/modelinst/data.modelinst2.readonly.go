@@ -0,0 +1,264 @@
+package modelinst
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+)
+
+// readonlyModelInst2 rejects all modifications with pluginapi.ErrModelReadonly
+type readonlyModelInst2 struct {
+	defaultModelInst2
+}
+
+func readonlyError(operation string) error {
+	return fmt.Errorf("%w: %s", pluginapi.ErrModelReadonly, operation)
+}
+
+func (r readonlyModelInst2) ensureSubObject(name string) (ModelInst2, error) {
+	return nil, readonlyError("ensureSubObject")
+}
+
+func (r readonlyModelInst2) ensureSubArrayWithObjectElem(name string) (ModelInst2, error) {
+	return nil, readonlyError("ensureSubArrayWithObjectElem")
+}
+
+func (r readonlyModelInst2) ensureArrayElement() (ModelInst2, error) {
+	return nil, readonlyError("ensureArrayElement")
+}
+
+func (r readonlyModelInst2) ensureArrayElementWithIndex(idx int) (ModelInst2, error) {
+	return nil, readonlyError("ensureArrayElementWithIndex")
+}
+
+func (r readonlyModelInst2) putPrimitiveArray(name string, arr []interface{}) error {
+	return readonlyError("putPrimitiveArray")
+}
+
+func (r readonlyModelInst2) setPrimitiveArrayIndex(name string, index int, value interface{}) error {
+	return readonlyError("setPrimitiveArrayIndex")
+}
+
+func (r readonlyModelInst2) putPrimitiveValue(name string, val interface{}) error {
+	return readonlyError("putPrimitiveValue")
+}
+
+func (r readonlyModelInst2) RemoveObjectByPath(paths []string) error {
+	return readonlyError("RemoveObjectByPath")
+}
+
+func (r readonlyModelInst2) AddOrUpdateField0(path []string, value interface{}) error {
+	return readonlyError("AddOrUpdateField0")
+}
+
+func (r readonlyModelInst2) FromToml(data []byte) error {
+	return readonlyError("FromToml")
+}
+
+func (r readonlyModelInst2) Decode(codec pluginapi.ModelCodec, data []byte) error {
+	return readonlyError("Decode")
+}
+
+// checkGeneralObject validates values of general object
+func checkGeneralObject(obj interface{}) error {
+	switch v := obj.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		for key, val := range v {
+			if err := checkGeneralObject(val); err != nil {
+				return errors.New(fmt.Sprintf("field=[%s] %s", key, err))
+			}
+		}
+		return nil
+	case []interface{}:
+		for _, val := range v {
+			if err := checkGeneralObject(val); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if isPrimitive(v) {
+			return nil
+		}
+		_, err := convertCodecPrimitive(v)
+		return err
+	}
+}
+
+// copyGeneralObject deep copies general object and converts primitive values
+func copyGeneralObject(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		r := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			r[key] = copyGeneralObject(val)
+		}
+		return r
+	case []interface{}:
+		r := make([]interface{}, len(v))
+		for i, val := range v {
+			r[i] = copyGeneralObject(val)
+		}
+		return r
+	default:
+		return mustConvertPrimitive(v)
+	}
+}
+
+// copyOnWriteModelInst2 reads from readonly data until the first modification
+// On the first modification, readonly data is copied into a writable Model
+type copyOnWriteModelInst2 struct {
+	readonly readonlyMapWrapper
+	writable *modelInst2MapImpl
+}
+
+func (c *copyOnWriteModelInst2) current() ModelInst2 {
+	if c.writable != nil {
+		return c.writable
+	}
+	return c.readonly
+}
+
+func (c *copyOnWriteModelInst2) write() (*modelInst2MapImpl, error) {
+	if c.writable == nil {
+		inst, err := newModelInst2FromGeneralObject(c.readonly.m)
+		if err != nil {
+			return nil, err
+		}
+		c.writable = inst
+	}
+	return c.writable, nil
+}
+
+func (c *copyOnWriteModelInst2) AddOrUpdateField0(path []string, value interface{}) error {
+	w, err := c.write()
+	if err != nil {
+		return err
+	}
+	return w.AddOrUpdateField0(path, value)
+}
+
+func (c *copyOnWriteModelInst2) GetFieldUnsafe0(path []string) interface{} {
+	return c.current().GetFieldUnsafe0(path)
+}
+
+func (c *copyOnWriteModelInst2) ToGeneralObject() interface{} {
+	return c.current().ToGeneralObject()
+}
+
+func (c *copyOnWriteModelInst2) Transfer(dst pluginapi.Model) error {
+	return c.current().(pluginapi.ModelCopy).Transfer(dst)
+}
+
+func (c *copyOnWriteModelInst2) ToToml() ([]byte, error) {
+	return c.current().(pluginapi.ModelEncoding).ToToml()
+}
+
+func (c *copyOnWriteModelInst2) FromToml(data []byte) error {
+	w, err := c.write()
+	if err != nil {
+		return err
+	}
+	return w.FromToml(data)
+}
+
+func (c *copyOnWriteModelInst2) Encode(codec pluginapi.ModelCodec) ([]byte, error) {
+	return c.current().(pluginapi.ModelEncoding).Encode(codec)
+}
+
+func (c *copyOnWriteModelInst2) Decode(codec pluginapi.ModelCodec, data []byte) error {
+	w, err := c.write()
+	if err != nil {
+		return err
+	}
+	return w.Decode(codec, data)
+}
+
+func (c *copyOnWriteModelInst2) transferPrimitiveArray(srcName, dstName string, dst ModelInst2) error {
+	return c.current().transferPrimitiveArray(srcName, dstName, dst)
+}
+
+func (c *copyOnWriteModelInst2) transferValue(srcName, dstName string, dst ModelInst2) error {
+	return c.current().transferValue(srcName, dstName, dst)
+}
+
+func (c *copyOnWriteModelInst2) ensureSubObject(name string) (ModelInst2, error) {
+	w, err := c.write()
+	if err != nil {
+		return nil, err
+	}
+	return w.ensureSubObject(name)
+}
+
+func (c *copyOnWriteModelInst2) getSubObject(name string) (ModelInst2, error) {
+	return c.current().getSubObject(name)
+}
+
+func (c *copyOnWriteModelInst2) ensureSubArrayWithObjectElem(name string) (ModelInst2, error) {
+	w, err := c.write()
+	if err != nil {
+		return nil, err
+	}
+	return w.ensureSubArrayWithObjectElem(name)
+}
+
+func (c *copyOnWriteModelInst2) getSubArrayWithObjectElem(name string) (ModelInst2, error) {
+	return c.current().getSubArrayWithObjectElem(name)
+}
+
+func (c *copyOnWriteModelInst2) foreachArrayElement(f func(inst2 ModelInst2) error) error {
+	return c.current().foreachArrayElement(f)
+}
+
+func (c *copyOnWriteModelInst2) ensureArrayElement() (ModelInst2, error) {
+	w, err := c.write()
+	if err != nil {
+		return nil, err
+	}
+	return w.ensureArrayElement()
+}
+
+func (c *copyOnWriteModelInst2) ensureArrayElementWithIndex(idx int) (ModelInst2, error) {
+	w, err := c.write()
+	if err != nil {
+		return nil, err
+	}
+	return w.ensureArrayElementWithIndex(idx)
+}
+
+func (c *copyOnWriteModelInst2) putPrimitiveArray(name string, arr []interface{}) error {
+	w, err := c.write()
+	if err != nil {
+		return err
+	}
+	return w.putPrimitiveArray(name, arr)
+}
+
+func (c *copyOnWriteModelInst2) setPrimitiveArrayIndex(name string, index int, value interface{}) error {
+	w, err := c.write()
+	if err != nil {
+		return err
+	}
+	return w.setPrimitiveArrayIndex(name, index, value)
+}
+
+func (c *copyOnWriteModelInst2) putPrimitiveValue(name string, val interface{}) error {
+	w, err := c.write()
+	if err != nil {
+		return err
+	}
+	return w.putPrimitiveValue(name, val)
+}
+
+func (c *copyOnWriteModelInst2) RemoveObjectByPath(paths []string) error {
+	w, err := c.write()
+	if err != nil {
+		return err
+	}
+	return w.RemoveObjectByPath(paths)
+}
@@ -14,7 +14,7 @@ import (
 type readonlyMapWrapper struct {
 	m map[string]interface{}
 
-	defaultModelInst2
+	readonlyModelInst2
 }
 
 func (m readonlyMapWrapper) Transfer(dst pluginapi.Model) error {
@@ -79,49 +79,21 @@ func (m readonlyMapWrapper) ToToml() ([]byte, error) {
 	return toml.Marshal(m.m)
 }
 
-func (m readonlyMapWrapper) FromToml(data []byte) error {
-	return errors.New("FromToml is not supported")
+// ToGeneralObject returns a copy of the readonly data
+func (m readonlyMapWrapper) ToGeneralObject() interface{} {
+	return copyGeneralObject(m.m)
 }
 
-func (m readonlyMapWrapper) ToGeneralObject() interface{} {
-	return m.m
+// CopyOnWrite returns a writable Model which copies the readonly data on the first modification
+func (m readonlyMapWrapper) CopyOnWrite() pluginapi.Model {
+	return &copyOnWriteModelInst2{readonly: m}
 }
 
 func (m readonlyMapWrapper) GetFieldUnsafe0(paths []string) interface{} {
-	var parent interface{} = m.m
-	for _, path := range paths[:len(paths)-1] {
+	var current interface{} = m.m
+	for _, path := range paths {
 		name, idx := rule.ExtractArrayPath(path)
-		if idx < 0 {
-			// handling object
-			obj, ok := parent.(map[string]interface{})
-			if !ok {
-				return nil //FIXME should raise error?
-			}
-			elem, ok := obj[name]
-			if !ok {
-				return nil
-			}
-			parent = elem
-			continue
-		} else {
-			// handling array
-			arr, ok := parent.([]interface{})
-			if !ok {
-				return nil //FIXME should raise error?
-			}
-			if len(arr) > idx {
-				parent = arr[idx]
-				continue
-			} else {
-				return nil
-			}
-		}
-	}
-	// last level
-	name, idx := rule.ExtractArrayPath(paths[len(paths)-1])
-	if idx < 0 {
-		// handling object
-		obj, ok := parent.(map[string]interface{})
+		obj, ok := current.(map[string]interface{})
 		if !ok {
 			return nil //FIXME should raise error?
 		}
@@ -129,27 +101,23 @@ func (m readonlyMapWrapper) GetFieldUnsafe0(paths []string) interface{} {
 		if !ok {
 			return nil
 		}
-		if isPrimitive(elem) {
-			return elem
-		} else {
-			return nil //FIXME should raise error?
-		}
-	} else {
-		// handling array
-		arr, ok := parent.([]interface{})
-		if !ok {
-			return nil //FIXME should raise error?
-		}
-		if len(arr) > idx {
-			elem := arr[idx]
-			if isPrimitive(elem) {
-				return elem
-			} else {
+		if idx >= 0 {
+			// handling array
+			arr, ok := elem.([]interface{})
+			if !ok {
 				return nil //FIXME should raise error?
 			}
-		} else {
-			return nil
+			if idx >= len(arr) {
+				return nil
+			}
+			elem = arr[idx]
 		}
+		current = elem
+	}
+	if isPrimitive(current) {
+		return current
+	} else {
+		return nil //FIXME should raise error?
 	}
 }
 
@@ -234,11 +202,11 @@ func (m readonlyMapWrapper) foreachArrayElement(f func(inst2 ModelInst2) error)
 type readonlyArrayWrapper struct {
 	data []interface{}
 
-	defaultModelInst2
+	readonlyModelInst2
 }
 
 func (m readonlyArrayWrapper) ToGeneralObject() interface{} {
-	return m.data
+	return copyGeneralObject(m.data)
 }
 
 func (m readonlyArrayWrapper) GetFieldUnsafe0(path []string) interface{} {
@@ -277,11 +245,11 @@ func (m readonlyArrayWrapper) foreachArrayElement(f func(inst2 ModelInst2) error
 type readonlyPrimitiveArrayWrapper struct {
 	data []interface{}
 
-	defaultModelInst2
+	readonlyModelInst2
 }
 
 func (m readonlyPrimitiveArrayWrapper) ToGeneralObject() interface{} {
-	return m.data
+	return copyGeneralObject(m.data)
 }
 
 func (m readonlyPrimitiveArrayWrapper) GetFieldUnsafe0(path []string) interface{} {
@@ -311,11 +279,11 @@ func (m readonlyPrimitiveArrayWrapper) foreachArrayElement(f func(inst2 ModelIns
 type readonlyElementWrapper struct {
 	data interface{}
 
-	defaultModelInst2
+	readonlyModelInst2
 }
 
 func (m readonlyElementWrapper) ToGeneralObject() interface{} {
-	return m.data
+	return copyGeneralObject(m.data)
 }
 
 func (m readonlyElementWrapper) GetFieldUnsafe0(path []string) interface{} {
@@ -2,8 +2,13 @@ package modelinst
 
 import (
 	"encoding/json"
+	"errors"
+	"reflect"
 	"testing"
 
+	"github.com/FimGroup/fim/fimapi/basicapi"
+	"github.com/FimGroup/fim/fimapi/pluginapi"
+
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -317,3 +322,192 @@ func TestReadonlyMapWrapperModelCopy2(t *testing.T) {
 		t.Fatal("array primitive value field not match")
 	}
 }
+
+func TestReadonlyModel(t *testing.T) {
+	src := map[string]interface{}{
+		"user": map[string]interface{}{
+			"name": "alice",
+			"tags": []interface{}{"a"},
+		},
+	}
+	m, err := ModelInstHelper{}.WrapReadonlyMapView(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the map is copied when wrapping, modifications of the map or general object are not visible
+	src["user"].(map[string]interface{})["tags"].([]interface{})[0] = "b"
+	m.ToGeneralObject().(map[string]interface{})["user"].(map[string]interface{})["name"] = "bob"
+	if m.GetFieldUnsafe0([]string{"user", "name"}) != "alice" || m.GetFieldUnsafe0([]string{"user", "tags[0]"}) != "a" {
+		t.Fatal("readonly model should not be modified")
+	}
+	// values of non-Model primitive types are converted into a copy
+	c, err := ModelInstHelper{}.WrapReadonlyMapView(map[string]interface{}{"user": map[string]interface{}{"age": 18}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetFieldUnsafe0([]string{"user", "age"}) != int64(18) {
+		t.Fatal("value should be converted")
+	}
+	if err := m.AddOrUpdateField0([]string{"user", "name"}, "bob"); !errors.Is(err, pluginapi.ErrModelReadonly) {
+		t.Fatal("modification should fail with readonly error:", err)
+	}
+	if err := m.RemoveObjectByPath([]string{"user"}); !errors.Is(err, pluginapi.ErrModelReadonly) {
+		t.Fatal("modification should fail with readonly error:", err)
+	}
+	if _, err := (ModelInstHelper{}).WrapReadonlyMapView(map[string]interface{}{"a": struct{}{}}); err == nil {
+		t.Fatal("unsupported value type should fail")
+	}
+
+	// copy on write
+	w := m.(pluginapi.ModelReadonly).CopyOnWrite()
+	if w.GetFieldUnsafe0([]string{"user", "tags[0]"}) != "a" {
+		t.Fatal("copy on write model should read readonly data")
+	}
+	if err := w.AddOrUpdateField0([]string{"user", "name"}, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if w.GetFieldUnsafe0([]string{"user", "name"}) != "bob" || m.GetFieldUnsafe0([]string{"user", "name"}) != "alice" {
+		t.Fatal("copy on write failed")
+	}
+	if src["user"].(map[string]interface{})["name"] != "alice" {
+		t.Fatal("copy on write should not modify the readonly map")
+	}
+}
+
+func TestModelApi(t *testing.T) {
+	schema, err := CompileSchema([]string{"user/name", "user/tags[]", "posts[]/title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []pluginapi.Model{ModelInstHelper{}.NewInst(), schema.NewInst()} {
+		if err := m.AddOrUpdateField0([]string{"user", "name"}, "alice"); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.AddOrUpdateField0([]string{"user", "age"}, 18); err != nil {
+			t.Fatal(err)
+		}
+		for _, tag := range []string{"a", "b", "c"} {
+			if _, err := m.AppendArrayElement([]string{"user", "tags"}, tag); err != nil {
+				t.Fatal(err)
+			}
+		}
+		idx, err := m.AppendArrayElement([]string{"posts"}, nil)
+		if err != nil || idx != 0 {
+			t.Fatal("append object element failed:", idx, err)
+		}
+		if err := m.AddOrUpdateField0([]string{"posts[0]", "title"}, "hello"); err != nil {
+			t.Fatal(err)
+		}
+
+		if keys, err := m.Keys([]string{"user"}); err != nil || !reflect.DeepEqual(keys, []string{"age", "name", "tags"}) {
+			t.Fatal("unexpected keys:", keys, err)
+		}
+		if n, err := m.ArrayLength([]string{"user", "tags"}); err != nil || n != 3 {
+			t.Fatal("unexpected array length:", n, err)
+		}
+		if dt, err := m.FieldType([]string{"posts"}); err != nil || dt != pluginapi.DataTypeArray {
+			t.Fatal("unexpected field type:", dt, err)
+		}
+		if dt, err := m.FieldType([]string{"user", "missing"}); err != nil || dt != pluginapi.DataTypeUnavailable {
+			t.Fatal("unexpected field type:", dt, err)
+		}
+		if v, err := basicapi.GetInt(m, []string{"user", "age"}); err != nil || v != 18 {
+			t.Fatal("unexpected int value:", v, err)
+		}
+		if _, err := basicapi.GetString(m, []string{"user", "age"}); !errors.Is(err, pluginapi.ErrFieldTypeMismatch) {
+			t.Fatal("type mismatch expected:", err)
+		}
+		if _, err := basicapi.GetString(m, []string{"user", "missing"}); !errors.Is(err, pluginapi.ErrFieldNotFound) {
+			t.Fatal("field not found expected:", err)
+		}
+		if _, err := m.GetField([]string{"user"}); !errors.Is(err, pluginapi.ErrFieldTypeMismatch) {
+			t.Fatal("type mismatch expected:", err)
+		}
+
+		if err := m.DeleteField([]string{"user", "tags[1]"}); err != nil {
+			t.Fatal(err)
+		}
+		if v, err := basicapi.GetString(m, []string{"user", "tags[1]"}); err != nil || v != "c" {
+			t.Fatal("unexpected element after deletion:", v, err)
+		}
+		sub, err := m.CopySubModel([]string{"user"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sub.AddOrUpdateField0([]string{"name"}, "bob"); err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := basicapi.GetString(m, []string{"user", "name"}); v != "alice" {
+			t.Fatal("sub model should be a copy")
+		}
+		if err := m.DeleteField([]string{"user"}); err != nil {
+			t.Fatal(err)
+		}
+		if dt, _ := m.FieldType([]string{"user"}); dt != pluginapi.DataTypeUnavailable {
+			t.Fatal("field should be deleted")
+		}
+	}
+
+	ro, err := ModelInstHelper{}.WrapReadonlyMapView(map[string]interface{}{"tags": []interface{}{"a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ro.ArrayLength([]string{"tags"}); err != nil || n != 1 {
+		t.Fatal("unexpected array length:", n, err)
+	}
+	if _, err := ro.AppendArrayElement([]string{"tags"}, "b"); !errors.Is(err, pluginapi.ErrModelReadonly) {
+		t.Fatal("modification should fail with readonly error:", err)
+	}
+}
+
+func TestModelQuery(t *testing.T) {
+	m, err := ModelInstHelper{}.WrapReadonlyMapView(map[string]interface{}{
+		"order": map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"price": 10, "status": "active"},
+				map[string]interface{}{"price": 25.5, "status": "closed"},
+				map[string]interface{}{"price": 30, "status": "active", "detail": map[string]interface{}{"price": 1}},
+			},
+			"tags": []interface{}{"a", "b"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		query    string
+		expected []interface{}
+	}{
+		{"order/items[*]/price", []interface{}{int64(10), 25.5, int64(30)}},
+		{"order/items[1]/price", []interface{}{25.5}},
+		{"order/items[?status=='active']/price", []interface{}{int64(10), int64(30)}},
+		{"order/items[?price>=25.5]/status", []interface{}{"closed", "active"}},
+		{"order/items[?detail]/price", []interface{}{int64(30)}},
+		{`order/items[?status!="a==active"]/price`, []interface{}{int64(10), 25.5, int64(30)}},
+		{"order/items[?status=='a>b']/price", nil},
+		{"order/tags[*]", []interface{}{"a", "b"}},
+		{"**/price", []interface{}{int64(10), 25.5, int64(30), int64(1)}},
+		{"*/tags[0]", []interface{}{"a"}},
+	}
+	for _, c := range cases {
+		q, err := basicapi.ParseQuery(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := q.Values(m)
+		if err != nil {
+			t.Fatal(c.query, err)
+		}
+		if len(values) == 0 && len(c.expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(values, c.expected) {
+			t.Fatal("query:", c.query, "unexpected values:", values)
+		}
+	}
+	for _, invalid := range []string{"order/items[?]", "order//items", "order/items[*", "**"} {
+		if _, err := basicapi.ParseQuery(invalid); err == nil {
+			t.Fatal("query should be invalid:", invalid)
+		}
+	}
+}
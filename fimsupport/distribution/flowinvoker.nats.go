@@ -3,9 +3,12 @@ package distribution
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
+	"github.com/FimGroup/fim/fimapi/modelpatch"
 	"github.com/FimGroup/fim/fimapi/pluginapi"
+	"github.com/FimGroup/fim/fimcore/modelinst"
 
 	"github.com/FimGroup/logging"
 
@@ -14,8 +17,21 @@ import (
 )
 
 const (
-	NatsFlowStopStatusCode  = "9998"
-	NatsFlowErrorStatusCode = "9999"
+	NatsUnsupportedCodecStatusCode = "9997"
+	NatsFlowStopStatusCode         = "9998"
+	NatsFlowErrorStatusCode        = "9999"
+
+	// NatsModelCodecHeader carries the codec name of the Model in request/reply
+	// Missing header means toml for compatibility
+	NatsModelCodecHeader = "Fim-Model-Codec"
+	// NatsModelPatchHeader in request means the requester accepts JSON Patch of the Model as reply
+	// The same header in reply means the data is JSON Patch(RFC 6902) against the request Model
+	NatsModelPatchHeader = "Fim-Model-Patch"
+	NatsModelPatchJson   = "json-patch"
+
+	// natsDeserializeFailed is the error description when request data cannot be decoded
+	// Nodes without codec support ignore the codec header and reply it for non-toml data
+	natsDeserializeFailed = "deserialize request failed"
 )
 
 type NatsFlowInvoker struct {
@@ -23,6 +39,9 @@ type NatsFlowInvoker struct {
 	addr            string
 	pipelineMapping map[string]pluginapi.PipelineProcess
 	reqTimeoutInSec int
+	codec           string
+	// pipelineCodecs remembers pipelines not supporting the codec: pipeline full name -> codec actually used
+	pipelineCodecs sync.Map
 
 	conn *nats.Conn
 	srv  micro.Service
@@ -43,17 +62,13 @@ func (n *NatsFlowInvoker) AddPipeline(pipelineName string, process pluginapi.Pip
 }
 
 func (n *NatsFlowInvoker) Invoke(pipelineFullName string, model pluginapi.Model) error {
-	data, err := ModelToData(model)
-	if err != nil {
-		return err
-	}
-	reply, err := n.conn.Request(pipelineFullName, data, time.Duration(n.reqTimeoutInSec)*time.Second)
+	reply, err := n.negotiatedRequest(pipelineFullName, model)
 	if err != nil {
 		return err
 	}
+	errorCode := reply.Header.Get(micro.ErrorCodeHeader)
 
 	// handling FlowError/FlowStop/General error
-	errorCode := reply.Header.Get(micro.ErrorCodeHeader)
 	if errorCode != "" {
 		errorCodeDescription := reply.Header.Get(micro.ErrorHeader)
 		switch errorCode {
@@ -74,7 +89,19 @@ func (n *NatsFlowInvoker) Invoke(pipelineFullName string, model pluginapi.Model)
 		}
 	}
 
-	replyModel, err := DataToModel(reply.Data)
+	if reply.Header.Get(NatsModelPatchHeader) == NatsModelPatchJson {
+		patch, err := modelpatch.DecodePatch(reply.Data)
+		if err != nil {
+			return err
+		}
+		return patch.Apply(model)
+	}
+
+	replyCodec := reply.Header.Get(NatsModelCodecHeader)
+	if replyCodec == "" {
+		replyCodec = modelinst.ModelCodecToml
+	}
+	replyModel, err := DataToModelWithCodec(reply.Data, replyCodec)
 	if err != nil {
 		return err
 	}
@@ -84,6 +111,49 @@ func (n *NatsFlowInvoker) Invoke(pipelineFullName string, model pluginapi.Model)
 	return nil
 }
 
+// negotiatedRequest sends request with the codec negotiated with the remote pipeline
+// Requests act as probes: if the remote node rejects the codec, toml which is supported by all nodes is used and
+// remembered for the pipeline so that later requests cost a single round trip
+func (n *NatsFlowInvoker) negotiatedRequest(pipelineFullName string, model pluginapi.Model) (*nats.Msg, error) {
+	codec := n.codec
+	if v, ok := n.pipelineCodecs.Load(pipelineFullName); ok {
+		codec = v.(string)
+	}
+	reply, err := n.request(pipelineFullName, model, codec)
+	if err != nil || codec == modelinst.ModelCodecToml || !codecUnsupported(reply) {
+		return reply, err
+	}
+	n.pipelineCodecs.Store(pipelineFullName, modelinst.ModelCodecToml)
+	n._logger.Info("remote pipeline doesn't support codec:", codec, ", fallback to toml. pipeline:", pipelineFullName)
+	return n.request(pipelineFullName, model, modelinst.ModelCodecToml)
+}
+
+// codecUnsupported checks whether the reply rejects the codec of the request
+// Both replies are sent before the pipeline is executed, so the request can be sent again safely
+func codecUnsupported(reply *nats.Msg) bool {
+	switch reply.Header.Get(micro.ErrorCodeHeader) {
+	case NatsUnsupportedCodecStatusCode:
+		return true
+	case "500":
+		// nodes predating codec support fail to decode non-toml data
+		return reply.Header.Get(micro.ErrorHeader) == natsDeserializeFailed
+	default:
+		return false
+	}
+}
+
+func (n *NatsFlowInvoker) request(pipelineFullName string, model pluginapi.Model, codec string) (*nats.Msg, error) {
+	data, err := ModelToDataWithCodec(model, codec)
+	if err != nil {
+		return nil, err
+	}
+	msg := nats.NewMsg(pipelineFullName)
+	msg.Data = data
+	msg.Header.Set(NatsModelCodecHeader, codec)
+	msg.Header.Set(NatsModelPatchHeader, NatsModelPatchJson)
+	return n.conn.RequestMsg(msg, time.Duration(n.reqTimeoutInSec)*time.Second)
+}
+
 func (n *NatsFlowInvoker) StartFlowInvoker() error {
 	conn, err := nats.Connect(n.addr)
 	if err != nil {
@@ -118,10 +188,20 @@ func (n *NatsFlowInvoker) StartFlowInvoker() error {
 					if n._logger.IsDebugEnabled() {
 						n._logger.Debug("received request by pipeline:", pipelineName)
 					}
-					m, err := DataToModel(request.Data())
+					codec := request.Headers().Get(NatsModelCodecHeader)
+					if codec == "" {
+						codec = modelinst.ModelCodecToml
+					}
+					if _, ok := modelinst.GetModelCodec(codec); !ok {
+						if err := request.Error(NatsUnsupportedCodecStatusCode, "unsupported codec", nil); err != nil {
+							n._logger.Error("nats micro respond error failed:", err)
+						}
+						return
+					}
+					m, err := DataToModelWithCodec(request.Data(), codec)
 					if err != nil {
 						n._logger.Error("nats micro DataToModel failed:", err)
-						if err := request.Error("500", "deserialize request failed", nil); err != nil {
+						if err := request.Error("500", natsDeserializeFailed, nil); err != nil {
 							n._logger.Error("nats micro respond error failed:", err)
 						}
 						return
@@ -158,8 +238,22 @@ func (n *NatsFlowInvoker) StartFlowInvoker() error {
 							n._logger.Error("nats micro respond error failed:", err)
 						}
 						return
+					} else if request.Headers().Get(NatsModelPatchHeader) == NatsModelPatchJson {
+						// reply changes only
+						data, err := n.diffModel(request.Data(), codec, m)
+						if err != nil {
+							n._logger.Error("nats micro diff model failed:", err)
+							if err := request.Error("500", "serialize response failed", nil); err != nil {
+								n._logger.Error("nats micro respond error failed:", err)
+							}
+							return
+						}
+						if err := request.Respond(data, micro.WithHeaders(micro.Headers{NatsModelPatchHeader: []string{NatsModelPatchJson}})); err != nil {
+							n._logger.Error("nats micro respond result failed:", err)
+						}
+						return
 					} else {
-						data, err := ModelToData(m)
+						data, err := ModelToDataWithCodec(m, codec)
 						if err != nil {
 							n._logger.Error("nats micro ModelToData failed:", err)
 							if err := request.Error("500", "serialize response failed", nil); err != nil {
@@ -167,7 +261,7 @@ func (n *NatsFlowInvoker) StartFlowInvoker() error {
 							}
 							return
 						} else {
-							if err := request.Respond(data); err != nil {
+							if err := request.Respond(data, micro.WithHeaders(micro.Headers{NatsModelCodecHeader: []string{codec}})); err != nil {
 								n._logger.Error("nats micro respond result failed:", err)
 							}
 							return
@@ -186,6 +280,19 @@ func (n *NatsFlowInvoker) StartFlowInvoker() error {
 	return nil
 }
 
+// diffModel generates JSON Patch from the request data to the processed Model
+func (n *NatsFlowInvoker) diffModel(requestData []byte, codec string, m pluginapi.Model) ([]byte, error) {
+	origin, err := DataToModelWithCodec(requestData, codec)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := modelpatch.Diff(origin, m)
+	if err != nil {
+		return nil, err
+	}
+	return patch.Encode()
+}
+
 func (n *NatsFlowInvoker) StopFlowInvoker() error {
 	n.conn.Close()
 	return nil
@@ -197,6 +304,18 @@ func NewNatsFlowInvoker(appName, addresses string) pluginapi.FlowInvoker {
 		addr:            addresses,
 		pipelineMapping: map[string]pluginapi.PipelineProcess{},
 		reqTimeoutInSec: 10,
+		codec:           modelinst.ModelCodecToml,
 		_logger:         logging.GetLoggerManager().GetLogger("FimSupport.Distribution.FlowInvoker.nats"),
 	}
 }
+
+// NewNatsFlowInvokerWithCodec creates NatsFlowInvoker which sends requests using the given model codec
+// Pipelines on remote nodes not supporting the codec, including nodes predating codec support, are invoked with toml
+func NewNatsFlowInvokerWithCodec(appName, addresses, codec string) (pluginapi.FlowInvoker, error) {
+	if _, ok := modelinst.GetModelCodec(codec); !ok {
+		return nil, errors.New("unknown model codec:" + codec)
+	}
+	invoker := NewNatsFlowInvoker(appName, addresses).(*NatsFlowInvoker)
+	invoker.codec = codec
+	return invoker, nil
+}
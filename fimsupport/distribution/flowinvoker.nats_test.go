@@ -0,0 +1,30 @@
+package distribution
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/micro"
+)
+
+func TestNatsCodecUnsupported(t *testing.T) {
+	reply := func(code, description string) *nats.Msg {
+		msg := nats.NewMsg("reply")
+		if code != "" {
+			msg.Header.Set(micro.ErrorCodeHeader, code)
+			msg.Header.Set(micro.ErrorHeader, description)
+		}
+		return msg
+	}
+	for msg, expected := range map[*nats.Msg]bool{
+		reply("", ""): false,
+		reply(NatsUnsupportedCodecStatusCode, "unsupported codec"): true,
+		reply("500", natsDeserializeFailed):                        true,
+		reply("500", "handling request failed"):                    false,
+		reply(NatsFlowErrorStatusCode, "trigger FlowError"):        false,
+	} {
+		if codecUnsupported(msg) != expected {
+			t.Fatal("unexpected result of reply:", msg.Header)
+		}
+	}
+}
@@ -9,20 +9,36 @@ import (
 )
 
 func ModelToData(model pluginapi.Model) ([]byte, error) {
+	return ModelToDataWithCodec(model, modelinst.ModelCodecToml)
+}
+
+func DataToModel(data []byte) (pluginapi.Model, error) {
+	return DataToModelWithCodec(data, modelinst.ModelCodecToml)
+}
+
+func ModelToDataWithCodec(model pluginapi.Model, codecName string) ([]byte, error) {
+	codec, ok := modelinst.GetModelCodec(codecName)
+	if !ok {
+		return nil, errors.New("err: unknown model codec:" + codecName)
+	}
 	modelEnc, ok := model.(pluginapi.ModelEncoding)
 	if !ok {
 		return nil, errors.New("err: Model is not ModelEncoding which cannot be used for ModelToData")
 	}
-	return modelEnc.ToToml()
+	return modelEnc.Encode(codec)
 }
 
-func DataToModel(data []byte) (pluginapi.Model, error) {
+func DataToModelWithCodec(data []byte, codecName string) (pluginapi.Model, error) {
+	codec, ok := modelinst.GetModelCodec(codecName)
+	if !ok {
+		return nil, errors.New("err: unknown model codec:" + codecName)
+	}
 	model := modelinst.ModelInstHelper{}.NewInst()
 	modelEnc, ok := model.(pluginapi.ModelEncoding)
 	if !ok {
 		return nil, errors.New("err: Model is not ModelEncoding which cannot be used for DataToModel")
 	}
-	if err := modelEnc.FromToml(data); err != nil {
+	if err := modelEnc.Decode(codec, data); err != nil {
 		return nil, err
 	}
 	return model, nil
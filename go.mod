@@ -5,9 +5,11 @@ go 1.20
 require (
 	github.com/FimGroup/logging v1.2.0
 	github.com/go-chi/chi/v5 v5.0.10
+	github.com/go-chi/cors v1.2.1
 	github.com/go-chi/jwtauth v1.2.0
 	github.com/gofrs/uuid/v5 v5.0.0
 	github.com/jackc/pgx/v5 v5.4.2
+	github.com/lestrrat-go/jwx v1.1.0
 	github.com/nats-io/nats.go v1.28.0
 	github.com/pelletier/go-toml/v2 v2.0.9
 	github.com/rabbitmq/amqp091-go v1.8.1
@@ -18,7 +20,6 @@ require (
 )
 
 require (
-	github.com/go-chi/cors v1.2.1 // indirect
 	github.com/goccy/go-json v0.3.5 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
@@ -27,7 +28,6 @@ require (
 	github.com/lestrrat-go/backoff/v2 v2.0.7 // indirect
 	github.com/lestrrat-go/httpcc v1.0.0 // indirect
 	github.com/lestrrat-go/iter v1.0.0 // indirect
-	github.com/lestrrat-go/jwx v1.1.0 // indirect
 	github.com/lestrrat-go/option v1.0.0 // indirect
 	github.com/nats-io/nats-server/v2 v2.9.20 // indirect
 	github.com/nats-io/nkeys v0.4.4 // indirect
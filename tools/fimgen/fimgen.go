@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/FimGroup/fim/fimcore"
+)
+
+// fimgen generates Go source from FlowModel definitions
+// e.g. fimgen -package=model -out=model/flowmodel.go flowmodel_user.toml flowmodel_order.toml
+
+var packageName string
+var output string
+
+func init() {
+	flag.StringVar(&packageName, "package", "model", "-package=model")
+	flag.StringVar(&output, "out", "", "-out=model/flowmodel.go, stdout if empty")
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal(errors.New("no FlowModel toml file"))
+	}
+
+	dtd := fimcore.NewDataTypeDefinitions()
+	for _, file := range flag.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := dtd.MergeToml(string(data)); err != nil {
+			log.Fatal(file, ": ", err)
+		}
+	}
+	if err := dtd.ResolveConstraints(); err != nil {
+		log.Fatal(err)
+	}
+	src, err := dtd.ToGoSource(packageName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if output == "" {
+		if _, err := os.Stdout.Write(src); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}